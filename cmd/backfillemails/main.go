@@ -0,0 +1,48 @@
+/*
+backfillemails is a one-time migration for users created before emails were claimed in the "quickmatch_emails" table
+on insert. Every user gets a claim for their email, so signup refuses to register the email again. Emails already
+claimed by another user are reported, since both accounts were registered with the same email and have to be resolved
+by hand. It can safely be run again, for example after a failure part way, including while the service is running.
+*/
+package main
+
+import (
+	"errors"
+	"log"
+	"quick-match/internal/clients"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+)
+
+func main() {
+	dynamoDBClient := clients.NewDynamoDBClient()
+	dc := repository.NewDynamoDBRepository(dynamoDBClient)
+
+	var claimed, conflicts int
+	err := dc.ScanUsers(func(user models.UserDetails) error {
+		if user.Email == "" {
+			return nil
+		}
+
+		err := dc.ClaimEmail(user.UserID, user.Email)
+		if errors.Is(err, repository.ErrEmailTaken) {
+			log.Printf("Email of user %s is already claimed by another user", user.UserID)
+			conflicts++
+			return nil
+		}
+		// Deleted, or their email changed, since the scan read them
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		claimed++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Backfill failed after claiming %d emails: %v", claimed, err)
+	}
+
+	log.Printf("Backfill complete: %d emails claimed, %d already claimed by another user", claimed, conflicts)
+}
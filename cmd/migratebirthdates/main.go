@@ -0,0 +1,62 @@
+/*
+migratebirthdates is a one-time migration for users created while ages were stored instead of birthdates.
+Every stored age is replaced by an approximate birthdate in DynamoDB, and every user is then reindexed into
+Elasticsearch, where discover filters on birthdates. Suspended users stay out of the index. It can safely be run
+again, for example after a failure part way.
+*/
+package main
+
+import (
+	"errors"
+	"log"
+	"quick-match/internal/clients"
+	"quick-match/internal/repository"
+	"time"
+)
+
+const EsDomainName = "quickmatch-discover"
+
+func main() {
+	dynamoDBClient := clients.NewDynamoDBClient()
+	dc := repository.NewDynamoDBRepository(dynamoDBClient)
+
+	esClient := clients.NewElasticsearchClient(EsDomainName)
+	esc := repository.NewElasticSearchClient(esClient)
+
+	// The birthdate mapping has to exist before any document carries one
+	esc.EnsureElasticsearchSetup()
+
+	now := time.Now()
+	var migrated, reindexed int
+	err := dc.ScanUserAges(func(userID string, age int) error {
+		if age > 0 {
+			err := dc.MigrateAgeToBirthdate(userID, age, now)
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			migrated++
+		}
+
+		user, err := dc.GetUserByID(userID)
+		if err != nil {
+			return err
+		}
+		// Suspended users are kept out of Elasticsearch
+		if user == nil || user.Suspended {
+			return nil
+		}
+		if err = esc.ReindexUserES(repository.CreateElasticSearchUser(*user)); err != nil {
+			return err
+		}
+		reindexed++
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Migration failed after migrating %d and reindexing %d users: %v", migrated, reindexed, err)
+	}
+
+	log.Printf("Migration complete: %d ages replaced by birthdates, %d users reindexed", migrated, reindexed)
+}
@@ -1,24 +1,50 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"os"
 	"quick-match/cmd/util"
 	"quick-match/internal/clients"
+	"quick-match/internal/events"
+	"quick-match/internal/handlers/account"
+	"quick-match/internal/handlers/block"
 	"quick-match/internal/handlers/discover"
+	"quick-match/internal/handlers/jwks"
 	"quick-match/internal/handlers/login"
+	"quick-match/internal/handlers/logout"
+	"quick-match/internal/handlers/matches"
+	"quick-match/internal/handlers/messages"
+	"quick-match/internal/handlers/moderation"
+	"quick-match/internal/handlers/photos"
+	"quick-match/internal/handlers/profile"
+	"quick-match/internal/handlers/refresh"
+	"quick-match/internal/handlers/report"
+	"quick-match/internal/handlers/signup"
+	"quick-match/internal/handlers/stream"
 	"quick-match/internal/handlers/swipe"
 	"quick-match/internal/handlers/usercreate"
 	"quick-match/internal/middleware/authentication"
 	"quick-match/internal/repository"
+	"quick-match/internal/services"
 	"time"
 )
 
 const port = "8080"
 const EsDomainName = "quickmatch-discover"
 
+// outboxPollInterval is how often the outbox is checked for users to index into Elasticsearch.
+const outboxPollInterval = time.Second
+
+// erasurePollInterval is how often account erasures that failed part way are resumed.
+const erasurePollInterval = time.Minute
+
+// exportPollInterval is how often requested data exports are built and expired ones deleted.
+const exportPollInterval = 10 * time.Second
+
 func main() {
 	r := mux.NewRouter()
 
@@ -31,17 +57,107 @@ func main() {
 	// Ensuring Elasticsearch index and mappings are correctly set up
 	esc.EnsureElasticsearchSetup()
 
-	ud := util.NewUserCreateService(dc, esc)
-	r.HandleFunc("/user/create", usercreate.CreateUserHandler(ud)).Methods("POST")
+	// DynamoDB is the source of truth; every user change it records in the outbox is indexed into Elasticsearch
+	util.NewOutboxIndexer(dc, esc).Start(context.Background(), outboxPollInterval)
+
+	keys, err := authentication.NewKeyManagerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+	keys.StartReload(context.Background())
+
+	passwords, err := services.NewPasswordServiceFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+
+	auth := authentication.JWTMiddleware(util.NewJWTMiddlewareService(dc, keys))
+	admin := authentication.AdminMiddleware(util.NewAdminMiddlewareService(dc))
 
-	ld := util.NewLoginService(dc)
+	broker := events.NewInMemoryBroker()
+
+	r.HandleFunc("/.well-known/jwks.json", jwks.JWKSHandler(util.NewJWKSService(keys))).Methods("GET")
+
+	sud := util.NewSignupService(dc, passwords)
+	r.HandleFunc("/user/register", signup.SignupHandler(sud)).Methods("POST")
+
+	// Fake user generation is only exposed in development environments
+	if os.Getenv("DEV_ROUTES_ENABLED") == "true" {
+		ud := util.NewUserCreateService(dc, passwords)
+		r.HandleFunc("/dev/user/create", usercreate.CreateUserHandler(ud)).Methods("POST")
+	}
+
+	ld := util.NewLoginService(dc, esc, keys, passwords)
 	r.HandleFunc("/login", login.LoginHandler(ld)).Methods("POST")
 
-	sd := util.NewSwipeService(dc)
-	r.Handle("/swipe", authentication.JWTMiddleware(swipe.SwipeHandler(sd))).Methods("POST")
+	rd := util.NewRefreshService(dc, keys)
+	r.HandleFunc("/token/refresh", refresh.RefreshHandler(rd)).Methods("POST")
+
+	lod := util.NewLogoutService(dc)
+	r.Handle("/logout", auth(logout.LogoutHandler(lod))).Methods("POST")
+
+	sd := util.NewSwipeService(dc, esc, broker)
+	r.Handle("/swipe", auth(swipe.SwipeHandler(sd))).Methods("POST")
+
+	md := util.NewMatchesService(dc, esc)
+	r.Handle("/matches", auth(matches.ListMatchesHandler(md))).Methods("GET")
+
+	umd := util.NewUnmatchService(dc, broker)
+	r.Handle("/matches/{matchId}", auth(matches.UnmatchHandler(umd))).Methods("DELETE")
+
+	pd := util.NewProfileService(dc)
+	r.Handle("/me", auth(profile.GetMeHandler(pd))).Methods("GET")
+	r.Handle("/me", auth(profile.UpdateMeHandler(pd))).Methods("PATCH")
+	r.Handle("/users/{id}", auth(profile.GetUserHandler(pd))).Methods("GET")
+
+	photoStore, photoFiles := util.NewBlobStoreFromEnv()
+	if photoFiles != nil {
+		r.PathPrefix("/photos/").Handler(photoFiles).Methods("GET")
+	}
+	phd := util.NewPhotosService(dc, photoStore)
+	r.Handle("/me/photos", auth(photos.UploadPhotoHandler(phd))).Methods("POST")
+	r.Handle("/me/photos/order", auth(photos.ReorderPhotosHandler(phd))).Methods("PUT")
+	r.Handle("/me/photos/{photoId}", auth(photos.DeletePhotoHandler(phd))).Methods("DELETE")
+
+	archiveStore := util.NewArchiveStoreFromEnv()
+	util.NewExporter(dc, archiveStore).Start(context.Background(), exportPollInterval)
+	exd := util.NewExportService(dc, archiveStore)
+	r.Handle("/me/exports", auth(account.RequestExportHandler(exd))).Methods("POST")
+	r.Handle("/me/exports/{exportId}", auth(account.GetExportHandler(exd))).Methods("GET")
+	r.Handle("/me/exports/{exportId}/download", auth(account.DownloadExportHandler(exd))).Methods("GET")
+
+	eraser := util.NewAccountEraser(dc, esc, photoStore, archiveStore)
+	eraser.Start(context.Background(), erasurePollInterval)
+	acd := util.NewAccountService(dc, eraser, passwords)
+	r.Handle("/me", auth(account.DeleteAccountHandler(acd))).Methods("DELETE")
+
+	lcd := util.NewLocationService(dc)
+	r.Handle("/me/location", auth(profile.UpdateLocationHandler(lcd))).Methods("PUT")
+
+	bd := util.NewBlockService(dc, broker)
+	r.Handle("/users/{id}/block", auth(block.BlockUserHandler(bd))).Methods("POST")
+
+	msd := util.NewMessagesService(dc, broker)
+	r.Handle("/matches/{matchId}/messages", auth(messages.SendMessageHandler(msd))).Methods("POST")
+	r.Handle("/matches/{matchId}/messages", auth(messages.ListMessagesHandler(msd))).Methods("GET")
+
+	std := util.NewStreamService(dc, broker)
+	r.Handle("/events", auth(stream.EventStreamHandler(std))).Methods("GET")
+
+	rpd := util.NewReportService(dc)
+	r.Handle("/reports", auth(report.ReportUserHandler(rpd))).Methods("POST")
+
+	mod := util.NewModerationService(dc)
+	r.Handle("/admin/reports", auth(admin(moderation.ListReportsHandler(mod)))).Methods("GET")
+	r.Handle("/admin/reports/{id}/resolve", auth(admin(moderation.ResolveReportHandler(mod)))).Methods("POST")
+	r.Handle("/admin/users/{id}/suspend", auth(admin(moderation.SuspendUserHandler(mod)))).Methods("POST")
 
 	dd := util.NewDiscoverService(dc, esc)
-	r.Handle("/discover", authentication.JWTMiddleware(discover.DiscoverUserInsert(dd))).Methods("POST")
+	r.Handle("/discover", auth(discover.DiscoverUserInsert(dd))).Methods("GET", "POST")
+
+	dsd := util.NewDiscoverSettingsService(dc)
+	r.Handle("/discover/settings", auth(discover.GetDiscoverSettingsHandler(dsd))).Methods("GET")
+	r.Handle("/discover/settings", auth(discover.UpdateDiscoverSettingsHandler(dsd))).Methods("PUT")
 
 	server := &http.Server{
 		Addr:         ":" + port,
@@ -0,0 +1,114 @@
+/*
+reconcile finds and repairs drift between DynamoDB, the source of truth for users, and the Elasticsearch "users" index
+that discover reads from. It reports users missing from the index, documents that differ from the user in DynamoDB,
+suspended users still in the index and documents for users that no longer exist, and repairs each of them the way the
+outbox indexer would. With -dry-run the drift is only reported. It can safely be run at any time, including while the
+service is running.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"quick-match/cmd/util"
+	"quick-match/internal/clients"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+)
+
+const EsDomainName = "quickmatch-discover"
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report drift without repairing it")
+	flag.Parse()
+
+	dynamoDBClient := clients.NewDynamoDBClient()
+	dc := repository.NewDynamoDBRepository(dynamoDBClient)
+
+	esClient := clients.NewElasticsearchClient(EsDomainName)
+	esc := repository.NewElasticSearchClient(esClient)
+
+	ix := util.NewOutboxIndexer(dc, esc)
+
+	indexed := map[string]models.UserDetailsES{}
+	err := esc.ScanUsersES(func(user models.UserDetailsES) error {
+		indexed[user.UserID] = user
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to scan Elasticsearch: %v", err)
+	}
+
+	var checked, missing, stale, suspended, orphaned, failed int
+	repair := func(userID, drift string) {
+		log.Printf("%s: %s", drift, userID)
+		if *dryRun {
+			return
+		}
+		if err := ix.SyncUser(userID); err != nil {
+			log.Printf("Indexing Failure: %v", err)
+			failed++
+		}
+	}
+
+	err = dc.ScanUsers(func(user models.UserDetails) error {
+		checked++
+		doc, found := indexed[user.UserID]
+		delete(indexed, user.UserID)
+
+		switch {
+		case user.Suspended:
+			if found {
+				suspended++
+				repair(user.UserID, "Suspended user still indexed")
+			}
+		case !found:
+			missing++
+			repair(user.UserID, "Missing from index")
+		default:
+			same, err := sameDocument(repository.CreateElasticSearchUser(user), doc)
+			if err != nil {
+				return err
+			}
+			if !same {
+				stale++
+				repair(user.UserID, "Stale document")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to scan DynamoDB: %v", err)
+	}
+
+	// Whatever is left in the index has no user in DynamoDB
+	for userID := range indexed {
+		orphaned++
+		repair(userID, "Orphaned document")
+	}
+
+	log.Printf("Reconcile complete: %d users checked, %d missing, %d stale, %d suspended but indexed, %d orphaned, %d repairs failed",
+		checked, missing, stale, suspended, orphaned, failed)
+	if failed > 0 {
+		log.Fatalf("Failed to repair %d users, run reconcile again", failed)
+	}
+}
+
+/*
+sameDocument reports whether an indexed document matches the document the user in DynamoDB would be indexed as. Only
+lastActiveAt is ignored, since it is written to Elasticsearch alone. Both are compared as JSON, so a missing list and
+an empty one count as the same.
+*/
+func sameDocument(want, got models.UserDetailsES) (bool, error) {
+	got.LastActiveAt = 0
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		return false, err
+	}
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		return false, err
+	}
+	return string(wantJSON) == string(gotJSON), nil
+}
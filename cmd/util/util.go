@@ -5,43 +5,270 @@ import (
 	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"log"
+	"net/http"
+	"os"
+	"quick-match/internal/clients"
+	"quick-match/internal/erasure"
+	"quick-match/internal/events"
+	"quick-match/internal/export"
+	"quick-match/internal/handlers/account"
+	"quick-match/internal/handlers/block"
 	"quick-match/internal/handlers/discover"
+	"quick-match/internal/handlers/jwks"
 	"quick-match/internal/handlers/login"
+	"quick-match/internal/handlers/logout"
+	"quick-match/internal/handlers/matches"
+	"quick-match/internal/handlers/messages"
+	"quick-match/internal/handlers/moderation"
+	"quick-match/internal/handlers/photos"
+	"quick-match/internal/handlers/profile"
+	"quick-match/internal/handlers/refresh"
+	"quick-match/internal/handlers/report"
+	"quick-match/internal/handlers/signup"
+	"quick-match/internal/handlers/stream"
 	"quick-match/internal/handlers/swipe"
 	"quick-match/internal/handlers/usercreate"
+	"quick-match/internal/indexer"
 	"quick-match/internal/middleware/authentication"
 	"quick-match/internal/repository"
 	"quick-match/internal/services"
 )
 
-func NewLoginService(ddb repository.DynamoDBRepository) *login.LoginDeps {
-	tokenService := authentication.NewJWTTokenService()
-	passwordService := services.NewBcryptPasswordService()
+func NewLoginService(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository, keys *authentication.KeyManager, passwordService services.PasswordService) *login.LoginDeps {
+	tokenService := authentication.NewJWTTokenService(keys)
 
 	return &login.LoginDeps{
 		UserRepo:        &ddb,
 		TokenService:    tokenService,
 		PasswordService: passwordService,
+		SessionRepo:     &ddb,
+		UserRepoES:      &es,
+		ThrottleRepo:    &ddb,
+		// Only set behind a load balancer that appends the client address to X-Forwarded-For
+		TrustForwardedFor: os.Getenv("TRUST_FORWARDED_FOR") == "true",
 	}
 }
 
-func NewUserCreateService(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository) *usercreate.CreateUserDeps {
+func NewRefreshService(ddb repository.DynamoDBRepository, keys *authentication.KeyManager) *refresh.RefreshDeps {
+	return &refresh.RefreshDeps{
+		SessionRepo:  &ddb,
+		TokenService: authentication.NewJWTTokenService(keys),
+	}
+}
+
+func NewLogoutService(ddb repository.DynamoDBRepository) *logout.LogoutDeps {
+	return &logout.LogoutDeps{
+		SessionRepo: &ddb,
+	}
+}
+
+func NewJWTMiddlewareService(ddb repository.DynamoDBRepository, keys *authentication.KeyManager) *authentication.JWTMiddlewareDeps {
+	return &authentication.JWTMiddlewareDeps{
+		SessionRepo: &ddb,
+		UserRepo:    &ddb,
+		Keys:        keys,
+	}
+}
+
+func NewAdminMiddlewareService(ddb repository.DynamoDBRepository) *authentication.AdminMiddlewareDeps {
+	return &authentication.AdminMiddlewareDeps{
+		UserRepo: &ddb,
+	}
+}
+
+func NewJWKSService(keys *authentication.KeyManager) *jwks.JWKSDeps {
+	return &jwks.JWKSDeps{
+		KeySet: keys,
+	}
+}
+
+func NewOutboxIndexer(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository) *indexer.OutboxIndexer {
+	return indexer.NewOutboxIndexer(&ddb, &ddb, &es)
+}
+
+func NewUserCreateService(ddb repository.DynamoDBRepository, passwordService services.PasswordService) *usercreate.CreateUserDeps {
 	return &usercreate.CreateUserDeps{
-		UserRepoES: &es,
-		UserRepo:   &ddb,
+		UserRepo:        &ddb,
+		PasswordService: passwordService,
+	}
+}
+
+func NewSignupService(ddb repository.DynamoDBRepository, passwordService services.PasswordService) *signup.SignupDeps {
+	return &signup.SignupDeps{
+		UserRepo:        &ddb,
+		PasswordService: passwordService,
 	}
 }
 
-func NewSwipeService(ddb repository.DynamoDBRepository) *swipe.SwipeDeps {
+func NewSwipeService(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository, broker events.Broker) *swipe.SwipeDeps {
 	return &swipe.SwipeDeps{
 		SwipeRepo: &ddb,
+		BlockRepo: &ddb,
+		SwipeSets: &es,
+		Events:    broker,
+	}
+}
+
+func NewMatchesService(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository) *matches.MatchesDeps {
+	return &matches.MatchesDeps{
+		MatchRepo:  &ddb,
+		UserRepoES: &es,
+	}
+}
+
+func NewUnmatchService(ddb repository.DynamoDBRepository, broker events.Broker) *matches.UnmatchDeps {
+	return &matches.UnmatchDeps{
+		MatchRepo: &ddb,
+		Events:    broker,
+	}
+}
+
+func NewBlockService(ddb repository.DynamoDBRepository, broker events.Broker) *block.BlockDeps {
+	return &block.BlockDeps{
+		BlockRepo: &ddb,
+		Events:    broker,
+	}
+}
+
+func NewProfileService(ddb repository.DynamoDBRepository) *profile.ProfileDeps {
+	return &profile.ProfileDeps{
+		UserRepo:  &ddb,
+		BlockRepo: &ddb,
+	}
+}
+
+func NewLocationService(ddb repository.DynamoDBRepository) *profile.LocationDeps {
+	return &profile.LocationDeps{
+		UserRepo: &ddb,
+	}
+}
+
+func NewPhotosService(ddb repository.DynamoDBRepository, blobs repository.BlobStore) *photos.PhotosDeps {
+	return &photos.PhotosDeps{
+		UserRepo:     &ddb,
+		Blobs:        blobs,
+		PhotoService: services.NewImagePhotoService(),
+	}
+}
+
+func NewAccountEraser(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository, blobs repository.BlobStore, archives repository.ArchiveStore) *erasure.AccountEraser {
+	return erasure.NewAccountEraser(&ddb, &es, blobs, archives)
+}
+
+func NewAccountService(ddb repository.DynamoDBRepository, eraser erasure.Eraser, passwordService services.PasswordService) *account.AccountDeps {
+	return &account.AccountDeps{
+		UserRepo:        &ddb,
+		PasswordService: passwordService,
+		Eraser:          eraser,
+	}
+}
+
+/*
+NewBlobStoreFromEnv returns the BlobStore selected by PHOTO_STORE. "s3" stores photos in the PHOTO_BUCKET bucket
+(quickmatch-photos by default). Anything else stores them below PHOTO_DIR (./data/photos by default), in which case the
+returned handler serves them and must be mounted at /photos/.
+PHOTO_BASE_URL overrides the public URL photos are served from.
+*/
+func NewBlobStoreFromEnv() (repository.BlobStore, http.Handler) {
+	baseURL := os.Getenv("PHOTO_BASE_URL")
+
+	if os.Getenv("PHOTO_STORE") == "s3" {
+		bucket := os.Getenv("PHOTO_BUCKET")
+		if bucket == "" {
+			bucket = "quickmatch-photos"
+		}
+		if baseURL == "" {
+			baseURL = "http://localhost:4566/" + bucket
+		}
+		store := repository.NewS3BlobStore(clients.NewS3Client(), bucket, baseURL)
+		return &store, nil
+	}
+
+	dir := os.Getenv("PHOTO_DIR")
+	if dir == "" {
+		dir = "./data/photos"
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:8080/photos"
+	}
+	store := repository.NewFileBlobStore(dir, baseURL)
+	return &store, http.StripPrefix("/photos/", store.FileServer())
+}
+
+func NewExporter(ddb repository.DynamoDBRepository, archives repository.ArchiveStore) *export.Exporter {
+	return export.NewExporter(&ddb, archives)
+}
+
+func NewExportService(ddb repository.DynamoDBRepository, archives repository.ArchiveStore) *account.ExportDeps {
+	return &account.ExportDeps{
+		ExportRepo: &ddb,
+		Archives:   archives,
+	}
+}
+
+/*
+NewArchiveStoreFromEnv returns the ArchiveStore selected by EXPORT_STORE. "s3" stores data exports in the EXPORT_BUCKET
+bucket (quickmatch-exports by default). Anything else stores them below EXPORT_DIR (./data/exports by default).
+Either way they are only served through the export download endpoint, so the bucket must not be public.
+*/
+func NewArchiveStoreFromEnv() repository.ArchiveStore {
+	if os.Getenv("EXPORT_STORE") == "s3" {
+		bucket := os.Getenv("EXPORT_BUCKET")
+		if bucket == "" {
+			bucket = "quickmatch-exports"
+		}
+		store := repository.NewS3BlobStore(clients.NewS3Client(), bucket, "")
+		return &store
+	}
+
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = "./data/exports"
+	}
+	store := repository.NewFileBlobStore(dir, "")
+	return &store
+}
+
+func NewReportService(ddb repository.DynamoDBRepository) *report.ReportDeps {
+	return &report.ReportDeps{
+		ReportRepo: &ddb,
+	}
+}
+
+func NewModerationService(ddb repository.DynamoDBRepository) *moderation.ModerationDeps {
+	return &moderation.ModerationDeps{
+		ModerationRepo: &ddb,
+	}
+}
+
+func NewMessagesService(ddb repository.DynamoDBRepository, broker events.Broker) *messages.MessagesDeps {
+	return &messages.MessagesDeps{
+		MatchRepo:   &ddb,
+		MessageRepo: &ddb,
+		BlockRepo:   &ddb,
+		Events:      broker,
+	}
+}
+
+func NewStreamService(ddb repository.DynamoDBRepository, broker events.Broker) *stream.StreamDeps {
+	return &stream.StreamDeps{
+		Broker:      broker,
+		SessionRepo: &ddb,
 	}
 }
 
 func NewDiscoverService(ddb repository.DynamoDBRepository, es repository.ElasticSearchRepository) *discover.DiscoverUserDeps {
 	return &discover.DiscoverUserDeps{
-		UserRepo:   &ddb,
-		UserRepoES: &es,
+		UserRepo:     &ddb,
+		UserRepoES:   &es,
+		BlockRepo:    &ddb,
+		SettingsRepo: &ddb,
+	}
+}
+
+func NewDiscoverSettingsService(ddb repository.DynamoDBRepository) *discover.DiscoverSettingsDeps {
+	return &discover.DiscoverSettingsDeps{
+		SettingsRepo: &ddb,
 	}
 }
 
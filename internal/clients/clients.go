@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/elasticsearchservice"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/elastic/go-elasticsearch/v7"
 	"log"
 )
@@ -24,6 +25,21 @@ func NewDynamoDBClient() *dynamodb.DynamoDB {
 	return dynamodb.New(sess)
 }
 
+// NewS3Client uses path-style addressing, which LocalStack needs to route requests to the bucket.
+func NewS3Client() *s3.S3 {
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String("http://localhost:4566"),
+		Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create session for S3: %v", err)
+	}
+
+	return s3.New(sess)
+}
+
 func NewElasticsearchClient(domainName string) *elasticsearch.Client {
 	sess, err := session.NewSession(&aws.Config{
 		Region:      aws.String("us-east-1"),
@@ -0,0 +1,169 @@
+package erasure
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log"
+	"quick-match/internal/export"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"time"
+)
+
+const (
+	// batchSize is how many pending erasures are resumed per poll.
+	batchSize = 20
+	// resumeAfter is how long an erasure is left to the request that started it before the background job resumes it.
+	resumeAfter = 5 * time.Minute
+)
+
+type Eraser interface {
+	EraseAccount(user models.UserDetails) error
+}
+
+/*
+AccountEraser deletes accounts and erases everything stored about them. The user record goes first, in one transaction
+with their email claim and a tombstone, so the account is gone as soon as EraseAccount returns. Their sessions, swipes
+in both directions, matches, messages, blocks, photos, data exports and Elasticsearch documents are erased next. Whatever fails there
+is picked up again from the tombstone by the background job started with Start, until an audit entry records that the
+erasure is complete.
+*/
+type AccountEraser struct {
+	UserRepo   repository.ErasureRepo
+	UserRepoES repository.EraseUserESRepo
+	Blobs      repository.BlobStore
+	Archives   repository.ArchiveStore
+}
+
+func NewAccountEraser(userRepo repository.ErasureRepo, userRepoES repository.EraseUserESRepo, blobs repository.BlobStore, archives repository.ArchiveStore) *AccountEraser {
+	return &AccountEraser{
+		UserRepo:   userRepo,
+		UserRepoES: userRepoES,
+		Blobs:      blobs,
+		Archives:   archives,
+	}
+}
+
+/*
+EraseAccount deletes the user's account and erases their data. Only a failure to delete the account itself is
+returned, ErrUserNotFound included: once the account is deleted, a failure to erase the rest is logged and left to the
+background job.
+*/
+func (e *AccountEraser) EraseAccount(user models.UserDetails) error {
+	now := time.Now().Unix()
+	tombstone := models.Tombstone{
+		UserID:       user.UserID,
+		DeletedAt:    now,
+		PendingSince: now,
+	}
+	for _, photo := range user.Photos {
+		tombstone.PhotoKeys = append(tombstone.PhotoKeys, photo.Key, photo.ThumbnailKey)
+	}
+
+	if err := e.UserRepo.DeleteUser(user, tombstone); err != nil {
+		return err
+	}
+
+	if err := e.Complete(tombstone); err != nil {
+		log.Printf("Erasure Failure: user %s, left to the background job: %v", user.UserID, err)
+	}
+	return nil
+}
+
+/*
+Complete erases everything left of a deleted account and records the audit entry. Every step can be repeated, so an
+erasure that failed part way is completed by running it again.
+*/
+func (e *AccountEraser) Complete(tombstone models.Tombstone) error {
+	userID := tombstone.UserID
+
+	if err := e.UserRepo.DeleteUserSessions(userID); err != nil {
+		return err
+	}
+	swiperIDs, err := e.UserRepo.DeleteUserSwipes(userID)
+	if err != nil {
+		return err
+	}
+	if err = e.UserRepo.DeleteUserMatches(userID); err != nil {
+		return err
+	}
+	if err = e.UserRepo.DeleteUserBlocks(userID); err != nil {
+		return err
+	}
+
+	for _, key := range tombstone.PhotoKeys {
+		if err = e.Blobs.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	// Archives are only found through their jobs, so the jobs go last. Jobs still being built are covered too, in
+	// case one completes in the meantime
+	jobs, err := e.UserRepo.ListUserExportJobs(userID)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if err = e.Archives.Delete(export.ArchiveKey(job)); err != nil {
+			return err
+		}
+	}
+	if err = e.UserRepo.DeleteExportJobs(jobs); err != nil {
+		return err
+	}
+
+	if err = e.UserRepoES.DeleteUserES(userID); err != nil {
+		return err
+	}
+	// Swipe sets are rebuilt from the swipe history on the next discover request, which no longer holds the user
+	for _, id := range append(swiperIDs, userID) {
+		if err = e.UserRepoES.DeleteSwipeSet(id); err != nil {
+			return err
+		}
+	}
+
+	return e.UserRepo.CompleteErasure(tombstone, models.AuditEntry{
+		AuditID:   uuid.New().String(),
+		Action:    models.AuditActionAccountErased,
+		SubjectID: userID,
+		ActorID:   userID,
+		CreatedAt: time.Now().Unix(),
+	})
+}
+
+// ResumePending completes the erasures that are still pending resumeAfter after they started, and returns how many.
+func (e *AccountEraser) ResumePending(now time.Time) (int, error) {
+	tombstones, err := e.UserRepo.ListPendingErasures(now.Add(-resumeAfter).Unix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, tombstone := range tombstones {
+		if err = e.Complete(tombstone); err != nil {
+			log.Printf("Erasure Failure: user %s, retrying later: %v", tombstone.UserID, err)
+			continue
+		}
+		completed++
+	}
+
+	return completed, nil
+}
+
+// Start resumes pending erasures every interval until ctx is cancelled.
+func (e *AccountEraser) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := e.ResumePending(now); err != nil {
+					log.Printf("Query Failure: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+const (
+	TypeMatchCreated = "match_created"
+	TypeNewMessage   = "new_message"
+	TypeUnmatched    = "unmatched"
+)
+
+// subscriberBuffer is how many events a slow subscriber can fall behind before further events to it are dropped.
+const subscriberBuffer = 32
+
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+type MatchData struct {
+	MatchID string `json:"matchId"`
+	UserID  string `json:"UserID"`
+}
+
+type Publisher interface {
+	Publish(userID string, event Event)
+}
+
+type Subscriber interface {
+	Subscribe(userID string) (<-chan Event, func())
+}
+
+/*
+Broker fans events out to every live subscription of a user. InMemoryBroker only reaches subscribers connected to the
+same instance. A broker backed by an external bus can implement the same interface to reach users connected elsewhere.
+*/
+type Broker interface {
+	Publisher
+	Subscriber
+}
+
+type InMemoryBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan Event]struct{}
+}
+
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{
+		subs: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Publish delivers the event to every subscription of the user without blocking. Subscribers that are too far behind miss it.
+func (b *InMemoryBroker) Publish(userID string, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs[userID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropping %s event for slow subscriber of user %s", event.Type, userID)
+		}
+	}
+}
+
+// Subscribe registers a new subscription for the user. The returned function cancels it and closes the channel.
+func (b *InMemoryBroker) Subscribe(userID string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan Event]struct{})
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
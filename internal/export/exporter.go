@@ -0,0 +1,231 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"time"
+)
+
+const (
+	// batchSize is how many export jobs are built, and how many expired archives deleted, per poll.
+	batchSize = 10
+	// pageSize is how many matches or messages are read per query while collecting a user's data.
+	pageSize = 100
+	// maxAttempts is how often building an archive is tried before the job is given up on.
+	maxAttempts = 5
+	// ArchiveTTL is how long an archive can be downloaded once it is built.
+	ArchiveTTL = 48 * time.Hour
+)
+
+/*
+Exporter builds the archives requested through export jobs. Each archive is a ZIP file holding the user's profile,
+their swipe history, their matches and the messages of those conversations as JSON files. It is stored in the
+ArchiveStore until ArchiveTTL has passed, after which it is deleted and the job marked as expired.
+Jobs are stored in DynamoDB and picked up by polling, so a job requested just before a restart is still built. Failed
+attempts are retried with exponential backoff, up to maxAttempts.
+*/
+type Exporter struct {
+	UserRepo repository.ExporterRepo
+	Archives repository.ArchiveStore
+}
+
+func NewExporter(userRepo repository.ExporterRepo, archives repository.ArchiveStore) *Exporter {
+	return &Exporter{
+		UserRepo: userRepo,
+		Archives: archives,
+	}
+}
+
+// ArchiveKey is where the archive of an export job is stored.
+func ArchiveKey(job models.ExportJob) string {
+	return "exports/" + job.UserID + "/" + job.ExportID + ".zip"
+}
+
+// ProcessDue builds the archives of the export jobs that are due at now, and returns how many jobs were finished.
+func (ex *Exporter) ProcessDue(now time.Time) (int, error) {
+	jobs, err := ex.UserRepo.ListDueExportJobs(now.Unix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	finished := 0
+	for _, job := range jobs {
+		if err = ex.build(job, now); err != nil {
+			ex.retry(job, now, err)
+			continue
+		}
+		finished++
+	}
+
+	return finished, nil
+}
+
+// build collects the user's data into an archive, stores it and completes the job.
+func (ex *Exporter) build(job models.ExportJob, now time.Time) error {
+	user, err := ex.UserRepo.GetUserByID(job.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		// The account was deleted since the export was requested
+		return ex.UserRepo.FailExportJob(job, now.Unix())
+	}
+
+	archive, err := ex.Archive(*user)
+	if err != nil {
+		return err
+	}
+
+	job.ArchiveKey = ArchiveKey(job)
+	if err = ex.Archives.Put(job.ArchiveKey, archive, "application/zip"); err != nil {
+		return err
+	}
+
+	job.CompletedAt = now.Unix()
+	job.ExpiresAt = now.Add(ArchiveTTL).Unix()
+	err = ex.UserRepo.CompleteExportJob(job)
+	if errors.Is(err, repository.ErrExportNotPending) {
+		// Another instance completed it first, or the account was deleted; this copy is not referenced
+		return ex.Archives.Delete(job.ArchiveKey)
+	}
+	return err
+}
+
+// retry reschedules a job whose archive could not be built, or gives up on it after maxAttempts.
+func (ex *Exporter) retry(job models.ExportJob, now time.Time, cause error) {
+	if job.Attempts+1 >= maxAttempts {
+		log.Printf("Export Failure: job %s, giving up after %d attempts: %v", job.ExportID, job.Attempts+1, cause)
+		if err := ex.UserRepo.FailExportJob(job, now.Unix()); err != nil {
+			log.Printf("Query Failure: %v", err)
+		}
+		return
+	}
+
+	next := now.Add(time.Minute << job.Attempts)
+	log.Printf("Export Failure: job %s, attempt %d, retrying at %s: %v", job.ExportID, job.Attempts+1, next.Format(time.RFC3339), cause)
+	if err := ex.UserRepo.RescheduleExportJob(job, next.Unix()); err != nil {
+		log.Printf("Query Failure: %v", err)
+	}
+}
+
+// Archive collects everything stored about the user into a ZIP file of JSON documents.
+func (ex *Exporter) Archive(user models.UserDetails) ([]byte, error) {
+	swipes, err := ex.UserRepo.ListSwipes(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.Match
+	cursor := ""
+	for {
+		page, err := ex.UserRepo.ListMatches(user.UserID, pageSize, cursor)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, page.Matches...)
+		if cursor = page.NextCursor; cursor == "" {
+			break
+		}
+	}
+
+	var messages []models.Message
+	for _, match := range matches {
+		cursor = ""
+		for {
+			page, err := ex.UserRepo.ListMessages(match.MatchID, pageSize, cursor)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, page.Messages...)
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		content any
+	}{
+		{"profile.json", user.ExportedProfile()},
+		{"swipes.json", nonNil(swipes)},
+		{"matches.json", nonNil(matches)},
+		{"messages.json", nonNil(messages)},
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ExpireArchives deletes the archives whose download window closed at now, and returns how many were deleted.
+func (ex *Exporter) ExpireArchives(now time.Time) (int, error) {
+	jobs, err := ex.UserRepo.ListExpiredExportJobs(now.Unix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, job := range jobs {
+		if err = ex.Archives.Delete(job.ArchiveKey); err != nil {
+			log.Printf("Blob Store Failure: failed to delete %s: %v", job.ArchiveKey, err)
+			continue
+		}
+		if err = ex.UserRepo.ExpireExportJob(job); err != nil {
+			log.Printf("Query Failure: %v", err)
+			continue
+		}
+		expired++
+	}
+
+	return expired, nil
+}
+
+// Start builds due archives and deletes expired ones every interval until ctx is cancelled.
+func (ex *Exporter) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := ex.ProcessDue(now); err != nil {
+					log.Printf("Query Failure: %v", err)
+				}
+				if _, err := ex.ExpireArchives(now); err != nil {
+					log.Printf("Query Failure: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// nonNil turns a nil slice into an empty one, so it is written as [] rather than null.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"quick-match/internal/erasure"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"quick-match/internal/services"
+)
+
+type AccountDeps struct {
+	UserRepo        repository.GetUserByIDRepo
+	PasswordService services.PasswordService
+	Eraser          erasure.Eraser
+}
+
+/*
+DeleteAccountHandler deletes the authenticated user's account and erases their data.
+Extracts the UserID from the request context. The user has to confirm with their password, so a stolen access token
+alone cannot delete an account.
+The account is gone once the handler answers: the user can no longer log in, their sessions stop working and their
+email can be registered again. Erasing the rest of their data is retried in the background if it fails here, see
+erasure.AccountEraser.
+*/
+func DeleteAccountHandler(deps *AccountDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var dr models.DeleteAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&dr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateDeleteAccount(dr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Password is required", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := deps.UserRepo.GetUserByID(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if err = deps.PasswordService.CompareHashAndPassword(user.PasswordHashed, dr.Password); err != nil {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+
+		err = deps.Eraser.EraseAccount(*user)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to delete account", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
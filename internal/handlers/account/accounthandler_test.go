@@ -0,0 +1,171 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+)
+
+type MockUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockUserRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+type MockPasswordService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordService) CompareHashAndPassword(hashedPassword, password string) error {
+	args := m.Called(hashedPassword, password)
+	return args.Error(0)
+}
+
+func (m *MockPasswordService) GenerateHashedPassword(unhashedPassword string) (string, error) {
+	args := m.Called(unhashedPassword)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPasswordService) NeedsRehash(hashedPassword string) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
+
+type MockEraser struct {
+	mock.Mock
+}
+
+func (m *MockEraser) EraseAccount(user models.UserDetails) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func TestDeleteAccountHandler(t *testing.T) {
+	user := &models.UserDetails{UserID: "user1", PasswordHashed: "hashed"}
+
+	tests := []struct {
+		name             string
+		body             any
+		setupMocks       func(*MockUserRepo, *MockPasswordService, *MockEraser)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful deletion",
+			body: models.DeleteAccountRequest{Password: "correcthorse"},
+			setupMocks: func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {
+				mr.On("GetUserByID", "user1").Return(user, nil)
+				mp.On("CompareHashAndPassword", "hashed", "correcthorse").Return(nil)
+				me.On("EraseAccount", *user).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:             "invalid body",
+			body:             "not an object",
+			setupMocks:       func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid request body",
+		},
+		{
+			name:             "missing password",
+			body:             models.DeleteAccountRequest{},
+			setupMocks:       func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Password is required",
+		},
+		{
+			name: "wrong password",
+			body: models.DeleteAccountRequest{Password: "wrong"},
+			setupMocks: func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {
+				mr.On("GetUserByID", "user1").Return(user, nil)
+				mp.On("CompareHashAndPassword", "hashed", "wrong").Return(errors.New("mismatch"))
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid credentials",
+		},
+		{
+			name: "user not found",
+			body: models.DeleteAccountRequest{Password: "correcthorse"},
+			setupMocks: func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {
+				mr.On("GetUserByID", "user1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "deleted by a concurrent request",
+			body: models.DeleteAccountRequest{Password: "correcthorse"},
+			setupMocks: func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {
+				mr.On("GetUserByID", "user1").Return(user, nil)
+				mp.On("CompareHashAndPassword", "hashed", "correcthorse").Return(nil)
+				me.On("EraseAccount", *user).Return(repository.ErrUserNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "error deleting account",
+			body: models.DeleteAccountRequest{Password: "correcthorse"},
+			setupMocks: func(mr *MockUserRepo, mp *MockPasswordService, me *MockEraser) {
+				mr.On("GetUserByID", "user1").Return(user, nil)
+				mp.On("CompareHashAndPassword", "hashed", "correcthorse").Return(nil)
+				me.On("EraseAccount", *user).Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to delete account",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(MockUserRepo)
+			mockPasswordService := new(MockPasswordService)
+			mockEraser := new(MockEraser)
+			tt.setupMocks(mockUserRepo, mockPasswordService, mockEraser)
+
+			deps := AccountDeps{
+				UserRepo:        mockUserRepo,
+				PasswordService: mockPasswordService,
+				Eraser:          mockEraser,
+			}
+
+			handler := DeleteAccountHandler(&deps)
+
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(tt.body)
+			req, err := http.NewRequest("DELETE", "/me", &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockUserRepo.AssertExpectations(t)
+			mockPasswordService.AssertExpectations(t)
+			mockEraser.AssertExpectations(t)
+		})
+	}
+}
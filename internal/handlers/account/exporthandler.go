@@ -0,0 +1,173 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/export"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+	"time"
+)
+
+type ExportDeps struct {
+	ExportRepo repository.ExportJobRepo
+	Archives   repository.ArchiveStore
+}
+
+/*
+RequestExportHandler starts an export of the authenticated user's personal data.
+Extracts the UserID from the request context. The archive is built in the background by export.Exporter, so the handler
+answers with the pending job straight away; its status is polled through GetExportHandler. While an export is still
+pending, requesting another one returns that same job instead of queueing a second one. The repository refuses to
+queue a second pending job, so concurrent requests get the same job as well.
+*/
+func RequestExportHandler(deps *ExportDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		job, err := requestExport(deps, UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to request export", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/me/exports/"+job.ExportID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// maxRequestExportAttempts is how often requestExport looks for the pending job again after a concurrent request queued it.
+const maxRequestExportAttempts = 3
+
+// requestExport returns the user's pending export job, and queues a new one if there is none.
+func requestExport(deps *ExportDeps, userID string) (*models.ExportJob, error) {
+	for attempt := 0; attempt < maxRequestExportAttempts; attempt++ {
+		job, err := deps.ExportRepo.GetPendingExportJob(userID)
+		if err != nil || job != nil {
+			return job, err
+		}
+
+		now := time.Now().Unix()
+		job = &models.ExportJob{
+			UserID:        userID,
+			ExportID:      uuid.New().String(),
+			Status:        models.ExportStatusPending,
+			RequestedAt:   now,
+			NextAttemptAt: now,
+		}
+		err = deps.ExportRepo.InsertExportJob(*job)
+		// A concurrent request queued a job first, which is looked up again
+		if !errors.Is(err, repository.ErrExportPending) {
+			return job, err
+		}
+	}
+
+	return nil, errors.New("pending export job kept changing")
+}
+
+/*
+GetExportHandler returns the status of one of the authenticated user's exports.
+Extracts the UserID from the request context. Once the archive is ready the response holds the URL to download it from,
+until it expires.
+*/
+func GetExportHandler(deps *ExportDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getExportJob(deps, w, r)
+		if !ok {
+			return
+		}
+
+		if job.Status == models.ExportStatusReady {
+			if expired(*job) {
+				// The archive is only deleted on the exporter's next poll
+				job.Status = models.ExportStatusExpired
+			} else {
+				job.DownloadURL = "/me/exports/" + job.ExportID + "/download"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+/*
+DownloadExportHandler serves the archive of one of the authenticated user's exports, as a ZIP file of JSON documents.
+Extracts the UserID from the request context. Archives are only served through this handler, never from a public URL,
+so only the user who requested an export can download it.
+*/
+func DownloadExportHandler(deps *ExportDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, ok := getExportJob(deps, w, r)
+		if !ok {
+			return
+		}
+
+		if job.Status == models.ExportStatusExpired || (job.Status == models.ExportStatusReady && expired(*job)) {
+			http.Error(w, "Export has expired", http.StatusGone)
+			return
+		}
+		if job.Status != models.ExportStatusReady {
+			http.Error(w, "Export is not ready", http.StatusConflict)
+			return
+		}
+
+		archive, err := deps.Archives.Get(export.ArchiveKey(*job))
+		if errors.Is(err, repository.ErrBlobNotFound) {
+			http.Error(w, "Export has expired", http.StatusGone)
+			return
+		}
+		if err != nil {
+			log.Printf("Blob Store Failure: %v", err)
+			http.Error(w, "Failed to fetch export", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="quickmatch-export-`+job.ExportID+`.zip"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+		w.Write(archive)
+	}
+}
+
+// getExportJob looks up the export job named in the path for the authenticated user, and answers the request if it fails.
+func getExportJob(deps *ExportDeps, w http.ResponseWriter, r *http.Request) (*models.ExportJob, bool) {
+	// Extract UserID from context, set by JWTMiddleware
+	UserID, ok := r.Context().Value("UserID").(string)
+	if !ok {
+		log.Println("Could not extract UserID from token")
+		http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	job, err := deps.ExportRepo.GetExportJob(UserID, mux.Vars(r)["exportId"])
+	if err != nil {
+		log.Printf("Query Failure: %v", err)
+		http.Error(w, "Failed to fetch export", http.StatusInternalServerError)
+		return nil, false
+	}
+	if job == nil {
+		http.Error(w, "Export not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return job, true
+}
+
+func expired(job models.ExportJob) bool {
+	return time.Now().Unix() >= job.ExpiresAt
+}
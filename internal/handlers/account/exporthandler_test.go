@@ -0,0 +1,381 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+	"time"
+)
+
+type MockExportRepo struct {
+	mock.Mock
+}
+
+func (m *MockExportRepo) InsertExportJob(job models.ExportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockExportRepo) GetExportJob(userID, exportID string) (*models.ExportJob, error) {
+	args := m.Called(userID, exportID)
+	job := args.Get(0)
+	if job == nil {
+		return nil, args.Error(1)
+	}
+	return job.(*models.ExportJob), args.Error(1)
+}
+
+func (m *MockExportRepo) GetPendingExportJob(userID string) (*models.ExportJob, error) {
+	args := m.Called(userID)
+	job := args.Get(0)
+	if job == nil {
+		return nil, args.Error(1)
+	}
+	return job.(*models.ExportJob), args.Error(1)
+}
+
+type MockArchiveStore struct {
+	mock.Mock
+}
+
+func (m *MockArchiveStore) Put(key string, data []byte, contentType string) error {
+	args := m.Called(key, data, contentType)
+	return args.Error(0)
+}
+
+func (m *MockArchiveStore) Get(key string) ([]byte, error) {
+	args := m.Called(key)
+	data := args.Get(0)
+	if data == nil {
+		return nil, args.Error(1)
+	}
+	return data.([]byte), args.Error(1)
+}
+
+func (m *MockArchiveStore) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func TestRequestExportHandler(t *testing.T) {
+	pending := &models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusPending, RequestedAt: 100}
+
+	tests := []struct {
+		name             string
+		setupMocks       func(*MockExportRepo)
+		expectedStatus   int
+		expectedExportID string
+		expectedErrorMsg string
+	}{
+		{
+			name: "new export",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(nil, nil)
+				mr.On("InsertExportJob", mock.MatchedBy(func(job models.ExportJob) bool {
+					return job.UserID == "user1" && job.ExportID != "" && job.Status == models.ExportStatusPending &&
+						job.NextAttemptAt == job.RequestedAt
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "export already pending",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(pending, nil)
+			},
+			expectedStatus:   http.StatusAccepted,
+			expectedExportID: "export1",
+		},
+		{
+			name: "export requested concurrently returns the job queued first",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(nil, nil).Once()
+				mr.On("InsertExportJob", mock.Anything).Return(repository.ErrExportPending)
+				mr.On("GetPendingExportJob", "user1").Return(pending, nil).Once()
+			},
+			expectedStatus:   http.StatusAccepted,
+			expectedExportID: "export1",
+		},
+		{
+			name: "pending export that keeps changing",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(nil, nil).Times(maxRequestExportAttempts)
+				mr.On("InsertExportJob", mock.Anything).Return(repository.ErrExportPending).Times(maxRequestExportAttempts)
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to request export",
+		},
+		{
+			name: "error looking up pending export",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(nil, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to request export",
+		},
+		{
+			name: "error inserting export",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetPendingExportJob", "user1").Return(nil, nil)
+				mr.On("InsertExportJob", mock.Anything).Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to request export",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExportRepo := new(MockExportRepo)
+			tt.setupMocks(mockExportRepo)
+
+			deps := ExportDeps{
+				ExportRepo: mockExportRepo,
+				Archives:   new(MockArchiveStore),
+			}
+
+			handler := RequestExportHandler(&deps)
+
+			req, err := http.NewRequest("POST", "/me/exports", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+			if tt.expectedStatus == http.StatusAccepted {
+				var job models.ExportJob
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&job))
+				assert.Equal(t, models.ExportStatusPending, job.Status)
+				assert.Equal(t, "/me/exports/"+job.ExportID, rr.Header().Get("Location"))
+				if tt.expectedExportID != "" {
+					assert.Equal(t, tt.expectedExportID, job.ExportID)
+				}
+			}
+
+			mockExportRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetExportHandler(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name             string
+		setupMocks       func(*MockExportRepo)
+		expectedStatus   int
+		expectedJob      *models.ExportJob
+		expectedErrorMsg string
+	}{
+		{
+			name: "pending export",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusPending}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedJob:    &models.ExportJob{ExportID: "export1", Status: models.ExportStatusPending},
+		},
+		{
+			name: "ready export has a download URL",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusReady, ExpiresAt: future}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedJob:    &models.ExportJob{ExportID: "export1", Status: models.ExportStatusReady, ExpiresAt: future, DownloadURL: "/me/exports/export1/download"},
+		},
+		{
+			name: "ready export past its expiry",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusReady, ExpiresAt: past}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedJob:    &models.ExportJob{ExportID: "export1", Status: models.ExportStatusExpired, ExpiresAt: past},
+		},
+		{
+			name: "export not found",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetExportJob", "user1", "export1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Export not found",
+		},
+		{
+			name: "error fetching export",
+			setupMocks: func(mr *MockExportRepo) {
+				mr.On("GetExportJob", "user1", "export1").Return(nil, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch export",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExportRepo := new(MockExportRepo)
+			tt.setupMocks(mockExportRepo)
+
+			deps := ExportDeps{
+				ExportRepo: mockExportRepo,
+				Archives:   new(MockArchiveStore),
+			}
+
+			handler := GetExportHandler(&deps)
+
+			req, err := http.NewRequest("GET", "/me/exports/export1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"exportId": "export1"})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+			if tt.expectedJob != nil {
+				var job models.ExportJob
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&job))
+				assert.Equal(t, *tt.expectedJob, job)
+			}
+
+			mockExportRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDownloadExportHandler(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	ready := &models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusReady, ExpiresAt: future}
+	archive := []byte("PK archive")
+
+	tests := []struct {
+		name             string
+		setupMocks       func(*MockExportRepo, *MockArchiveStore)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful download",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(ready, nil)
+				ma.On("Get", "exports/user1/export1.zip").Return(archive, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "export not found",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Export not found",
+		},
+		{
+			name: "export still pending",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusPending}, nil)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Export is not ready",
+		},
+		{
+			name: "export failed",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusFailed}, nil)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Export is not ready",
+		},
+		{
+			name: "export expired",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusExpired}, nil)
+			},
+			expectedStatus:   http.StatusGone,
+			expectedErrorMsg: "Export has expired",
+		},
+		{
+			name: "ready export past its expiry",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(&models.ExportJob{UserID: "user1", ExportID: "export1", Status: models.ExportStatusReady, ExpiresAt: past}, nil)
+			},
+			expectedStatus:   http.StatusGone,
+			expectedErrorMsg: "Export has expired",
+		},
+		{
+			name: "archive already deleted",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(ready, nil)
+				ma.On("Get", "exports/user1/export1.zip").Return(nil, repository.ErrBlobNotFound)
+			},
+			expectedStatus:   http.StatusGone,
+			expectedErrorMsg: "Export has expired",
+		},
+		{
+			name: "error reading archive",
+			setupMocks: func(mr *MockExportRepo, ma *MockArchiveStore) {
+				mr.On("GetExportJob", "user1", "export1").Return(ready, nil)
+				ma.On("Get", "exports/user1/export1.zip").Return(nil, errors.New("s3 error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch export",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExportRepo := new(MockExportRepo)
+			mockArchives := new(MockArchiveStore)
+			tt.setupMocks(mockExportRepo, mockArchives)
+
+			deps := ExportDeps{
+				ExportRepo: mockExportRepo,
+				Archives:   mockArchives,
+			}
+
+			handler := DownloadExportHandler(&deps)
+
+			req, err := http.NewRequest("GET", "/me/exports/export1/download", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = mux.SetURLVars(req, map[string]string{"exportId": "export1"})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+				assert.Equal(t, archive, rr.Body.Bytes())
+			}
+
+			mockExportRepo.AssertExpectations(t)
+			mockArchives.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package block
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/events"
+	"quick-match/internal/repository"
+	"time"
+)
+
+type BlockDeps struct {
+	BlockRepo repository.BlockUserRepo
+	Events    events.Publisher
+}
+
+/*
+BlockUserHandler lets the authenticated user block another user.
+Extracts the UserID from the request context and the blocked user's ID from the URL.
+Once blocked, the two users are hidden from each other's discover results in both directions, cannot swipe on each
+other and cannot read or write their conversation.
+If the two users were matched, the match is removed and both users are sent an "unmatched" event. The blocked user is
+not told that they were blocked.
+Blocking a user twice is not an error.
+*/
+func BlockUserHandler(deps *BlockDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		blockedUserID := mux.Vars(r)["id"]
+
+		if blockedUserID == UserID {
+			http.Error(w, "Cannot block yourself", http.StatusBadRequest)
+			return
+		}
+
+		matchID, err := deps.BlockRepo.BlockUser(UserID, blockedUserID, time.Now().Unix())
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to block user", http.StatusInternalServerError)
+			return
+		}
+
+		if matchID != "" {
+			deps.Events.Publish(UserID, events.Event{Type: events.TypeUnmatched, Data: events.MatchData{MatchID: matchID, UserID: blockedUserID}})
+			deps.Events.Publish(blockedUserID, events.Event{Type: events.TypeUnmatched, Data: events.MatchData{MatchID: matchID, UserID: UserID}})
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
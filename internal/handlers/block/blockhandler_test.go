@@ -0,0 +1,109 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/events"
+	"testing"
+)
+
+type MockBlockUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockUserRepo) BlockUser(userID, blockedUserID string, blockedAt int64) (string, error) {
+	args := m.Called(userID, blockedUserID, blockedAt)
+	return args.String(0), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(userID string, event events.Event) {
+	m.Called(userID, event)
+}
+
+func TestBlockUserHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		blockedUserID    string
+		setupMocks       func(*MockBlockUserRepo, *MockPublisher)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:          "block an unmatched user",
+			blockedUserID: "user2",
+			setupMocks: func(mb *MockBlockUserRepo, mp *MockPublisher) {
+				mb.On("BlockUser", "user1", "user2", mock.AnythingOfType("int64")).Return("", nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:          "block a matched user removes the match",
+			blockedUserID: "user2",
+			setupMocks: func(mb *MockBlockUserRepo, mp *MockPublisher) {
+				mb.On("BlockUser", "user1", "user2", mock.AnythingOfType("int64")).Return("match1", nil)
+				mp.On("Publish", "user1", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeUnmatched && e.Data.(events.MatchData).MatchID == "match1"
+				})).Return()
+				mp.On("Publish", "user2", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeUnmatched && e.Data.(events.MatchData).UserID == "user1"
+				})).Return()
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:             "block yourself",
+			blockedUserID:    "user1",
+			setupMocks:       func(mb *MockBlockUserRepo, mp *MockPublisher) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Cannot block yourself",
+		},
+		{
+			name:          "error storing block",
+			blockedUserID: "user2",
+			setupMocks: func(mb *MockBlockUserRepo, mp *MockPublisher) {
+				mb.On("BlockUser", "user1", "user2", mock.AnythingOfType("int64")).Return("", errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to block user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockBlockRepo := new(MockBlockUserRepo)
+			mockPublisher := new(MockPublisher)
+			tt.setupMocks(mockBlockRepo, mockPublisher)
+
+			deps := BlockDeps{
+				BlockRepo: mockBlockRepo,
+				Events:    mockPublisher,
+			}
+
+			handler := BlockUserHandler(&deps)
+
+			req, _ := http.NewRequest("POST", "/users/"+tt.blockedUserID+"/block", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.blockedUserID})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockBlockRepo.AssertExpectations(t)
+			mockPublisher.AssertExpectations(t)
+		})
+	}
+}
@@ -2,32 +2,64 @@ package discover
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"quick-match/internal/middleware/validation"
 	"quick-match/internal/models"
 	"quick-match/internal/repository"
+	"strconv"
 )
 
 type DiscoverUserDeps struct {
-	UserRepo   repository.GetSwipedUserRepo
-	UserRepoES repository.DiscoverRepo
+	UserRepo     repository.GetSwipedUserRepo
+	UserRepoES   repository.DiscoverRepo
+	BlockRepo    repository.BlockedUsersRepo
+	SettingsRepo repository.GetUserByIDRepo
 }
 
 /*
 DiscoverUserInsert processes user discovery requests.
-User IDs that the authenticated user has already swiped on to exclude them from the discovery results.
+Users the authenticated user has already swiped on are excluded from the discovery results through their swipe set in
+Elasticsearch. If the user has no swipe set yet, it is first built from their full swipe history in DynamoDB. The
+history is read again once the set exists, so a swipe made while it was being built is not left out.
+The authenticated user never shows up in their own results. Users the authenticated user blocked, or was blocked by, are excluded as well.
 Authenticated user's details are fetched from Elasticsearch, including their location, to be used in filtering compatible users.
 Searches for compatible users based on the discovery filters provided and the authenticated user's location, excluding previously swiped users.
-Any combination of filters can be provided. Non are mandatory.
+Only candidates that the authenticated user's stored preferences accept, and whose own stored preferences accept the
+authenticated user, are returned.
+Any combination of filters can be provided. Non are mandatory. Filters are read from the JSON body of a POST, or from
+the query string of a GET. Every filter the request leaves out falls back to the user's saved discover settings.
+Results come back one page at a time, sorted by relevance (the default: shared interests first, then distance),
+distance, recent activity or age. The response carries the total number of matching users and a cursor that fetches the
+next page when sent back with the same filters.
+Each result carries its distance from the authenticated user as a range such as "<5" or "10-25", in kilometers or miles
+as requested by `unit`, which also applies to `maxLocation`. Coordinates are never returned.
+Distances are measured from the user's stored location, unless `latitude` and `longitude` are sent for a one-off
+search from somewhere else ("travel mode"). Travel mode snaps them to a grid of 0.1 degrees, so candidates cannot be
+located by searching from many points, and never changes the stored location.
 */
 func DiscoverUserInsert(deps *DiscoverUserDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var df models.DiscoverFilters
-		if err := json.NewDecoder(r.Body).Decode(&df); err != nil {
+		if r.Method == http.MethodGet {
+			var err error
+			if df, err = discoverFiltersFromQuery(r.URL.Query()); err != nil {
+				http.Error(w, "Invalid discover filters", http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&df); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
+		if err := validation.ValidateDiscover(df); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid discover filters", http.StatusBadRequest)
+			return
+		}
+
 		// Extract UserID from context, set by JWTMiddleware
 		UserID, ok := r.Context().Value("UserID").(string)
 		if !ok {
@@ -37,10 +69,51 @@ func DiscoverUserInsert(deps *DiscoverUserDeps) http.HandlerFunc {
 		}
 		df.UserID = UserID
 
-		swipedIDs, err := deps.UserRepo.GetSwipedUserIDs(UserID)
+		stored, err := deps.SettingsRepo.GetUserByID(UserID)
 		if err != nil {
 			log.Printf("Query Failure: %v", err)
-			http.Error(w, "Failed to find Swiped IDs", http.StatusInternalServerError)
+			http.Error(w, "Failed to fetch discover settings", http.StatusInternalServerError)
+			return
+		}
+		if stored != nil && stored.DiscoverSettings != nil {
+			df = df.WithDefaults(*stored.DiscoverSettings)
+		}
+
+		hasSwipeSet, err := deps.UserRepoES.SwipeSetExists(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to check swipe set", http.StatusInternalServerError)
+			return
+		}
+		if !hasSwipeSet {
+			swipedIDs, err := deps.UserRepo.GetSwipedUserIDs(UserID)
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to find Swiped IDs", http.StatusInternalServerError)
+				return
+			}
+			if err = deps.UserRepoES.ReplaceSwipeSet(UserID, swipedIDs); err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to build swipe set", http.StatusInternalServerError)
+				return
+			}
+			// A swipe stored since the first read found no set to add itself to, so the history is read once more
+			if swipedIDs, err = deps.UserRepo.GetSwipedUserIDs(UserID); err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to find Swiped IDs", http.StatusInternalServerError)
+				return
+			}
+			if err = deps.UserRepoES.MergeIntoSwipeSet(UserID, swipedIDs); err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to build swipe set", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		blockedIDs, err := deps.BlockRepo.GetBlockedUserIDs(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to find blocked IDs", http.StatusInternalServerError)
 			return
 		}
 
@@ -51,18 +124,87 @@ func DiscoverUserInsert(deps *DiscoverUserDeps) http.HandlerFunc {
 			return
 		}
 
+		// Travel mode searches around the given location instead of the user's own
+		if df.Latitude != nil && df.Longitude != nil {
+			user.Location = models.SnapToTravelGrid(models.UserLocationES{Lat: *df.Latitude, Lon: *df.Longitude})
+		}
+
 		currentUserLocation := user.Location
-		filteredUsers, err := deps.UserRepoES.SearchUsers(currentUserLocation, swipedIDs, df)
+		page, err := deps.UserRepoES.SearchUsers(user, blockedIDs, df)
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Printf("Query Failure: %v", err)
 			http.Error(w, "Failed to search users with df", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		unit := df.Unit
+		if unit == "" {
+			unit = models.UnitKilometers
+		}
+		response := models.DiscoverReturn{
+			Users:      make([]models.DiscoverUser, 0, len(page.Users)),
+			Unit:       unit,
+			NextCursor: page.NextCursor,
+			Total:      page.Total,
+		}
+		for _, candidate := range page.Users {
+			response.Users = append(response.Users, candidate.DiscoverUser(currentUserLocation, unit))
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		if err = json.NewEncoder(w).Encode(filteredUsers); err != nil {
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(response); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
 }
+
+// discoverFiltersFromQuery reads the discover filters of a GET request from its query string.
+func discoverFiltersFromQuery(query url.Values) (models.DiscoverFilters, error) {
+	df := models.DiscoverFilters{
+		Gender: query.Get("gender"),
+		Unit:   query.Get("unit"),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+
+	numbers := map[string]*int{
+		"minAge":      &df.MinAge,
+		"maxAge":      &df.MaxAge,
+		"maxLocation": &df.MaxLocation,
+		"pageSize":    &df.PageSize,
+	}
+	for name, target := range numbers {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return models.DiscoverFilters{}, err
+		}
+		*target = parsed
+	}
+
+	coordinates := map[string]**float64{
+		"latitude":  &df.Latitude,
+		"longitude": &df.Longitude,
+	}
+	for name, target := range coordinates {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return models.DiscoverFilters{}, err
+		}
+		*target = &parsed
+	}
+
+	return df, nil
+}
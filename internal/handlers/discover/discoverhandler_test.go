@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"quick-match/internal/models"
+	"quick-match/internal/repository"
 	"testing"
 )
 
@@ -32,17 +33,61 @@ func (m *MockDiscoverRepo) GetUserByID(userID string) (models.UserDetailsES, err
 	return args.Get(0).(models.UserDetailsES), args.Error(1)
 }
 
-func (m *MockDiscoverRepo) SearchUsers(currentUserLocation models.UserLocationES, swipedUserIDs []string, df models.DiscoverFilters) ([]models.UserDetailsES, error) {
-	args := m.Called(currentUserLocation, swipedUserIDs, df)
-	return args.Get(0).([]models.UserDetailsES), args.Error(1)
+func (m *MockDiscoverRepo) SearchUsers(searcher models.UserDetailsES, blockedUserIDs []string, df models.DiscoverFilters) (models.DiscoverPage, error) {
+	args := m.Called(searcher, blockedUserIDs, df)
+	return args.Get(0).(models.DiscoverPage), args.Error(1)
 }
 
+func (m *MockDiscoverRepo) SwipeSetExists(userID string) (bool, error) {
+	args := m.Called(userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDiscoverRepo) ReplaceSwipeSet(userID string, swipedUserIDs []string) error {
+	args := m.Called(userID, swipedUserIDs)
+	return args.Error(0)
+}
+
+func (m *MockDiscoverRepo) MergeIntoSwipeSet(userID string, swipedUserIDs []string) error {
+	args := m.Called(userID, swipedUserIDs)
+	return args.Error(0)
+}
+
+type MockSettingsRepo struct {
+	mock.Mock
+}
+
+func (m *MockSettingsRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+type MockBlockedUsersRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockedUsersRepo) GetBlockedUserIDs(userID string) ([]string, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+var (
+	berlin  = models.UserLocationES{Lat: 52.52, Lon: 13.405}
+	potsdam = models.UserLocationES{Lat: 52.3906, Lon: 13.0645}
+)
+
 func TestDiscoverUserInsert(t *testing.T) {
 	tests := []struct {
 		name             string
 		body             models.DiscoverFilters
-		setupMocks       func(*MockGetSwipedUserRepo, *MockDiscoverRepo)
+		query            string
+		setupMocks       func(*MockGetSwipedUserRepo, *MockDiscoverRepo, *MockBlockedUsersRepo, *MockSettingsRepo)
 		expectedStatus   int
+		expectedResponse *models.DiscoverReturn
 		expectError      bool
 		expectedErrorMsg string
 		userIDInContext  string
@@ -50,18 +95,324 @@ func TestDiscoverUserInsert(t *testing.T) {
 		{
 			name: "successful discovery",
 			body: models.DiscoverFilters{},
-			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo) {
-				mg.On("GetSwipedUserIDs", "userID").Return([]string{}, nil)
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, mock.Anything).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			userIDInContext: "userID",
+		},
+		{
+			name: "missing swipe set is built from the swipe history",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, nil)
+				mg.On("GetSwipedUserIDs", "userID").Return([]string{"swipedID1", "swipedID2"}, nil)
+				md.On("ReplaceSwipeSet", "userID", []string{"swipedID1", "swipedID2"}).Return(nil)
+				md.On("MergeIntoSwipeSet", "userID", []string{"swipedID1", "swipedID2"}).Return(nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, mock.Anything).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			userIDInContext: "userID",
+		},
+		{
+			// The swipe's own AddToSwipeSet ran before the set existed, so it is only found in the history
+			name: "swipe made while the swipe set is built is merged into it",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, nil)
+				mg.On("GetSwipedUserIDs", "userID").Return([]string{"swipedID1"}, nil).Once()
+				md.On("ReplaceSwipeSet", "userID", []string{"swipedID1"}).Return(nil)
+				mg.On("GetSwipedUserIDs", "userID").Return([]string{"swipedID1", "swipedID2"}, nil).Once()
+				md.On("MergeIntoSwipeSet", "userID", []string{"swipedID1", "swipedID2"}).Return(nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, mock.Anything).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			userIDInContext: "userID",
+		},
+		{
+			name: "error merging late swipes into the swipe set",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, nil)
+				mg.On("GetSwipedUserIDs", "userID").Return([]string{"swipedID1"}, nil)
+				md.On("ReplaceSwipeSet", "userID", []string{"swipedID1"}).Return(nil)
+				md.On("MergeIntoSwipeSet", "userID", []string{"swipedID1"}).Return(errors.New("es error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Failed to build swipe set",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "error checking swipe set",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, errors.New("es error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Failed to check swipe set",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "error building swipe set",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, nil)
+				mg.On("GetSwipedUserIDs", "userID").Return([]string{"swipedID1"}, nil)
+				md.On("ReplaceSwipeSet", "userID", []string{"swipedID1"}).Return(errors.New("es error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Failed to build swipe set",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "blocked users are excluded from the search",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{"blockedID", "blockerID"}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{}, nil)
+				md.On("SearchUsers", mock.Anything, []string{"blockedID", "blockerID"}, mock.Anything).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			userIDInContext: "userID",
+		},
+		{
+			name: "query failure on fetching blocked user IDs",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return(([]string)(nil), errors.New("database query error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Failed to find blocked IDs",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "page of results with next cursor",
+			body: models.DiscoverFilters{PageSize: 1, Sort: models.SortRecent},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{Location: berlin}, nil)
+				md.On("SearchUsers", models.UserDetailsES{Location: berlin}, mock.Anything, mock.MatchedBy(func(df models.DiscoverFilters) bool {
+					return df.PageSize == 1 && df.Sort == models.SortRecent && df.UserID == "userID"
+				})).Return(models.DiscoverPage{Users: []models.UserDetailsES{{UserID: "other", Location: potsdam}}, NextCursor: "next", Total: 3}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.DiscoverReturn{Users: []models.DiscoverUser{{UserID: "other", Distance: "25-50"}}, Unit: "km", NextCursor: "next", Total: 3},
+			userIDInContext:  "userID",
+		},
+		{
+			name: "ranked by shared interests, with bio and interests returned",
+			body: models.DiscoverFilters{Sort: models.SortRelevance},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				searcher := models.UserDetailsES{Location: berlin, Interests: []string{"climbing", "coffee"}}
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(searcher, nil)
+				md.On("SearchUsers", searcher, mock.Anything, mock.MatchedBy(func(df models.DiscoverFilters) bool {
+					return df.Sort == models.SortRelevance
+				})).Return(models.DiscoverPage{Users: []models.UserDetailsES{
+					{UserID: "other", Location: potsdam, Bio: "Weekend climber", Interests: []string{"climbing", "coffee"}},
+				}, Total: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &models.DiscoverReturn{Users: []models.DiscoverUser{
+				{UserID: "other", Distance: "25-50", Bio: "Weekend climber", Interests: []string{"climbing", "coffee"}},
+			}, Unit: "km", Total: 1},
+			userIDInContext: "userID",
+		},
+		{
+			name: "distances in miles, reported as ranges",
+			body: models.DiscoverFilters{Unit: models.UnitMiles, MaxLocation: 20},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{Location: berlin}, nil)
+				md.On("SearchUsers", models.UserDetailsES{Location: berlin}, mock.Anything, mock.MatchedBy(func(df models.DiscoverFilters) bool {
+					return df.Unit == models.UnitMiles && df.MaxLocation == 20
+				})).Return(models.DiscoverPage{Users: []models.UserDetailsES{
+					{UserID: "neighbour", Location: models.UserLocationES{Lat: 52.5201, Lon: 13.4051}},
+					{UserID: "other", Location: potsdam},
+				}, Total: 2}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &models.DiscoverReturn{Users: []models.DiscoverUser{
+				{UserID: "neighbour", Distance: "<5"},
+				{UserID: "other", Distance: "10-25"},
+			}, Unit: "mi", Total: 2},
+			userIDInContext: "userID",
+		},
+		{
+			name: "travel mode searches from the given location, snapped to the grid",
+			body: models.DiscoverFilters{Latitude: &potsdam.Lat, Longitude: &potsdam.Lon},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{UserID: "userID", Location: berlin}, nil)
+				md.On("SearchUsers", models.UserDetailsES{UserID: "userID", Location: models.UserLocationES{Lat: 52.4, Lon: 13.1}}, mock.Anything, mock.Anything).
+					Return(models.DiscoverPage{Users: []models.UserDetailsES{{UserID: "berliner", Location: berlin}}, Total: 1}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &models.DiscoverReturn{Users: []models.DiscoverUser{
+				{UserID: "berliner", Distance: "10-25"},
+			}, Unit: "km", Total: 1},
+			userIDInContext: "userID",
+		},
+		{
+			name: "travel mode without longitude",
+			body: models.DiscoverFilters{Latitude: &potsdam.Lat},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid discover filters",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "searcher's preferences are passed to the search",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				searcher := models.UserDetailsES{UserID: "userID", Gender: "female", Birthdate: "1995-04-12", Preferences: models.Preferences{InterestedIn: []string{"male"}, PreferredMinAge: 28}}
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(searcher, nil)
+				md.On("SearchUsers", searcher, mock.Anything, mock.Anything).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			userIDInContext: "userID",
+		},
+		{
+			name: "invalid unit",
+			body: models.DiscoverFilters{Unit: "yd"},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid discover filters",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "invalid sort",
+			body: models.DiscoverFilters{Sort: "name"},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid discover filters",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "page size too large",
+			body: models.DiscoverFilters{PageSize: 101},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid discover filters",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "invalid cursor",
+			body: models.DiscoverFilters{Cursor: "garbage"},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
 				md.On("GetUserByID", "userID").Return(models.UserDetailsES{}, nil)
-				md.On("SearchUsers", mock.Anything, mock.Anything, mock.Anything).Return([]models.UserDetailsES{}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, mock.Anything).Return(models.DiscoverPage{}, repository.ErrInvalidCursor)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid cursor",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "saved settings fill in the filters the request leaves out",
+			body: models.DiscoverFilters{MaxLocation: 10},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{
+					UserID:           "userID",
+					DiscoverSettings: &models.DiscoverSettings{Gender: "female", MinAge: 25, MaxLocation: 50, Unit: models.UnitMiles},
+				}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{UserID: "userID"}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, models.DiscoverFilters{
+					UserID: "userID", Gender: "female", MinAge: 25, MaxLocation: 10, Unit: models.UnitMiles,
+				}).Return(models.DiscoverPage{}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.DiscoverReturn{Users: []models.DiscoverUser{}, Unit: models.UnitMiles},
+			userIDInContext:  "userID",
+		},
+		{
+			name:  "filters from the query string of a GET",
+			query: "gender=male&minAge=30&maxAge=40&maxLocation=5&unit=km&sort=age&pageSize=10&cursor=abc",
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(true, nil)
+				mb.On("GetBlockedUserIDs", "userID").Return([]string{}, nil)
+				md.On("GetUserByID", "userID").Return(models.UserDetailsES{UserID: "userID"}, nil)
+				md.On("SearchUsers", mock.Anything, mock.Anything, models.DiscoverFilters{
+					UserID: "userID", Gender: "male", MinAge: 30, MaxAge: 40, MaxLocation: 5, Unit: models.UnitKilometers,
+					PageSize: 10, Sort: models.SortAge, Cursor: "abc",
+				}).Return(models.DiscoverPage{}, nil)
 			},
 			expectedStatus:  http.StatusOK,
 			userIDInContext: "userID",
 		},
+		{
+			name:  "non-numeric age in the query string",
+			query: "minAge=old",
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectError:      true,
+			expectedErrorMsg: "Invalid discover filters",
+			userIDInContext:  "userID",
+		},
+		{
+			name: "query failure on fetching discover settings",
+			body: models.DiscoverFilters{},
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(nil, errors.New("database query error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Failed to fetch discover settings",
+			userIDInContext:  "userID",
+		},
 		{
 			name: "query failure on fetching swiped user IDs",
 			body: models.DiscoverFilters{},
-			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo) {
+			setupMocks: func(mg *MockGetSwipedUserRepo, md *MockDiscoverRepo, mb *MockBlockedUsersRepo, ms *MockSettingsRepo) {
+				ms.On("GetUserByID", "userID").Return(&models.UserDetails{UserID: "userID"}, nil)
+				md.On("SwipeSetExists", "userID").Return(false, nil)
 				mg.On("GetSwipedUserIDs", "userID").Return(([]string)(nil), errors.New("database query error"))
 			},
 			expectedStatus:   http.StatusInternalServerError,
@@ -75,17 +426,24 @@ func TestDiscoverUserInsert(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockGetSwipedUserRepo := new(MockGetSwipedUserRepo)
 			mockDiscoverRepo := new(MockDiscoverRepo)
-			tt.setupMocks(mockGetSwipedUserRepo, mockDiscoverRepo)
+			mockBlockedUsersRepo := new(MockBlockedUsersRepo)
+			mockSettingsRepo := new(MockSettingsRepo)
+			tt.setupMocks(mockGetSwipedUserRepo, mockDiscoverRepo, mockBlockedUsersRepo, mockSettingsRepo)
 
 			deps := DiscoverUserDeps{
-				UserRepo:   mockGetSwipedUserRepo,
-				UserRepoES: mockDiscoverRepo,
+				UserRepo:     mockGetSwipedUserRepo,
+				UserRepoES:   mockDiscoverRepo,
+				BlockRepo:    mockBlockedUsersRepo,
+				SettingsRepo: mockSettingsRepo,
 			}
 
 			handler := DiscoverUserInsert(&deps)
 
 			bodyBytes, _ := json.Marshal(tt.body)
 			req, _ := http.NewRequest("POST", "/discover", bytes.NewBuffer(bodyBytes))
+			if tt.query != "" {
+				req, _ = http.NewRequest("GET", "/discover?"+tt.query, nil)
+			}
 			ctx := context.WithValue(req.Context(), "UserID", tt.userIDInContext)
 			req = req.WithContext(ctx)
 			rr := httptest.NewRecorder()
@@ -97,10 +455,18 @@ func TestDiscoverUserInsert(t *testing.T) {
 			if tt.expectError {
 				responseBody := rr.Body.String()
 				assert.Contains(t, responseBody, tt.expectedErrorMsg, "Error message does not match")
+			} else if tt.expectedResponse != nil {
+				assert.NotContains(t, rr.Body.String(), "lat", "Coordinates must not be returned")
+				var response models.DiscoverReturn
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, *tt.expectedResponse, response)
 			}
 
 			mockGetSwipedUserRepo.AssertExpectations(t)
 			mockDiscoverRepo.AssertExpectations(t)
+			mockBlockedUsersRepo.AssertExpectations(t)
+			mockSettingsRepo.AssertExpectations(t)
 		})
 	}
 }
@@ -0,0 +1,100 @@
+package discover
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+)
+
+type DiscoverSettingsDeps struct {
+	SettingsRepo repository.DiscoverSettingsRepo
+}
+
+/*
+GetDiscoverSettingsHandler returns the authenticated user's saved discover settings.
+Extracts the UserID from the request context and reads the user from DynamoDB.
+A user who never saved any settings gets an empty object.
+*/
+func GetDiscoverSettingsHandler(deps *DiscoverSettingsDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := deps.SettingsRepo.GetUserByID(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch discover settings", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		settings := models.DiscoverSettings{}
+		if user.DiscoverSettings != nil {
+			settings = *user.DiscoverSettings
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+UpdateDiscoverSettingsHandler replaces the authenticated user's saved discover settings.
+Extracts the UserID from the request context and validates the settings the same way as the matching discover filters.
+Fields left out are cleared, so an empty object removes all saved settings.
+*/
+func UpdateDiscoverSettingsHandler(deps *DiscoverSettingsDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var settings models.DiscoverSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateDiscoverSettings(settings); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid discover settings", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		err := deps.SettingsRepo.UpdateDiscoverSettings(UserID, settings)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to save discover settings", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package discover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+)
+
+type MockDiscoverSettingsRepo struct {
+	mock.Mock
+}
+
+func (m *MockDiscoverSettingsRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockDiscoverSettingsRepo) UpdateDiscoverSettings(userID string, settings models.DiscoverSettings) error {
+	args := m.Called(userID, settings)
+	return args.Error(0)
+}
+
+func TestGetDiscoverSettingsHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		setupMocks       func(*MockDiscoverSettingsRepo)
+		expectedStatus   int
+		expectedSettings *models.DiscoverSettings
+		expectedErrorMsg string
+	}{
+		{
+			name: "saved settings",
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("GetUserByID", "user1").Return(&models.UserDetails{
+					UserID:           "user1",
+					DiscoverSettings: &models.DiscoverSettings{Gender: "female", MaxAge: 35, Unit: models.UnitMiles},
+				}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedSettings: &models.DiscoverSettings{Gender: "female", MaxAge: 35, Unit: models.UnitMiles},
+		},
+		{
+			name: "no settings saved yet",
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("GetUserByID", "user1").Return(&models.UserDetails{UserID: "user1"}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedSettings: &models.DiscoverSettings{},
+		},
+		{
+			name: "user not found",
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("GetUserByID", "user1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "query failure",
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("GetUserByID", "user1").Return(nil, errors.New("database query error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch discover settings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSettingsRepo := new(MockDiscoverSettingsRepo)
+			tt.setupMocks(mockSettingsRepo)
+
+			handler := GetDiscoverSettingsHandler(&DiscoverSettingsDeps{SettingsRepo: mockSettingsRepo})
+
+			req, _ := http.NewRequest("GET", "/discover/settings", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedSettings != nil {
+				var settings models.DiscoverSettings
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&settings))
+				assert.Equal(t, *tt.expectedSettings, settings)
+			} else {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+
+			mockSettingsRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateDiscoverSettingsHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		setupMocks       func(*MockDiscoverSettingsRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "settings saved",
+			body: `{"gender":"male","minAge":25,"maxAge":40,"maxLocation":30,"unit":"mi"}`,
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("UpdateDiscoverSettings", "user1", models.DiscoverSettings{
+					Gender: "male", MinAge: 25, MaxAge: 40, MaxLocation: 30, Unit: models.UnitMiles,
+				}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "empty settings clear the saved ones",
+			body: `{}`,
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("UpdateDiscoverSettings", "user1", models.DiscoverSettings{}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "invalid request body",
+			body:             `{"minAge":"young"}`,
+			setupMocks:       func(ms *MockDiscoverSettingsRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid request body",
+		},
+		{
+			name:             "age range inverted",
+			body:             `{"minAge":40,"maxAge":30}`,
+			setupMocks:       func(ms *MockDiscoverSettingsRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid discover settings",
+		},
+		{
+			name:             "unknown unit",
+			body:             `{"unit":"parsec"}`,
+			setupMocks:       func(ms *MockDiscoverSettingsRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid discover settings",
+		},
+		{
+			name: "user not found",
+			body: `{"gender":"female"}`,
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("UpdateDiscoverSettings", "user1", models.DiscoverSettings{Gender: "female"}).Return(repository.ErrUserNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "query failure",
+			body: `{"gender":"female"}`,
+			setupMocks: func(ms *MockDiscoverSettingsRepo) {
+				ms.On("UpdateDiscoverSettings", "user1", models.DiscoverSettings{Gender: "female"}).Return(errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to save discover settings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSettingsRepo := new(MockDiscoverSettingsRepo)
+			tt.setupMocks(mockSettingsRepo)
+
+			handler := UpdateDiscoverSettingsHandler(&DiscoverSettingsDeps{SettingsRepo: mockSettingsRepo})
+
+			req, _ := http.NewRequest("PUT", "/discover/settings", bytes.NewBufferString(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+
+			mockSettingsRepo.AssertExpectations(t)
+		})
+	}
+}
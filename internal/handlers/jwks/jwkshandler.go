@@ -0,0 +1,26 @@
+package jwks
+
+import (
+	"encoding/json"
+	"net/http"
+	"quick-match/internal/middleware/authentication"
+)
+
+type JWKSDeps struct {
+	KeySet authentication.KeySet
+}
+
+/*
+JWKSHandler publishes the public keys that can verify quick-match tokens as a JSON Web Key Set.
+Keys that were rotated out stay listed until their grace window ends, so other services can keep verifying tokens
+signed just before a rotation. HS256 secrets are never published.
+*/
+func JWKSHandler(deps *JWKSDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(deps.KeySet.JWKS()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
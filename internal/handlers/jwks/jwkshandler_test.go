@@ -0,0 +1,71 @@
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"testing"
+)
+
+type MockKeySet struct {
+	mock.Mock
+}
+
+func (m *MockKeySet) JWKS() models.JWKS {
+	args := m.Called()
+	return args.Get(0).(models.JWKS)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		keys         models.JWKS
+		expectedKids []string
+	}{
+		{
+			name: "publishes every key",
+			keys: models.JWKS{Keys: []models.JSONWebKey{
+				{Kty: "RSA", Use: "sig", Kid: "old", Alg: "RS256", N: "n", E: "AQAB"},
+				{Kty: "OKP", Use: "sig", Kid: "new", Alg: "EdDSA", Crv: "Ed25519", X: "x"},
+			}},
+			expectedKids: []string{"old", "new"},
+		},
+		{
+			name:         "no public keys",
+			keys:         models.JWKS{Keys: []models.JSONWebKey{}},
+			expectedKids: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKeySet := new(MockKeySet)
+			mockKeySet.On("JWKS").Return(tt.keys)
+
+			handler := JWKSHandler(&JWKSDeps{KeySet: mockKeySet})
+
+			req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var response models.JWKS
+			err := json.NewDecoder(rr.Body).Decode(&response)
+			assert.NoError(t, err)
+
+			kids := []string{}
+			for _, key := range response.Keys {
+				kids = append(kids, key.Kid)
+			}
+			assert.Equal(t, tt.expectedKids, kids)
+
+			mockKeySet.AssertExpectations(t)
+		})
+	}
+}
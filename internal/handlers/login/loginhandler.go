@@ -2,29 +2,55 @@ package login
 
 import (
 	"encoding/json"
+	"github.com/google/uuid"
 	"log"
+	"math"
 	"net/http"
 	"quick-match/internal/middleware/authentication"
 	"quick-match/internal/middleware/validation"
 	"quick-match/internal/models"
 	"quick-match/internal/repository"
 	"quick-match/internal/services"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type LoginDeps struct {
 	UserRepo        repository.LoginUserRepo
 	TokenService    authentication.TokenService
 	PasswordService services.PasswordService
+	SessionRepo     repository.CreateSessionRepo
+	UserRepoES      repository.LastActiveRepo
+	ThrottleRepo    repository.LoginThrottleRepo
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, which is only safe behind a load balancer that sets it
+	TrustForwardedFor bool
 }
 
 /*
 LoginHandler processes login requests.
 Validates the provided login credentials.
-Attempts to retrieve the user by email from the repository.
+Counts the attempt against the client IP and the email before anything else, and refuses it with 429 Too Many Requests
+and a Retry-After header while either is locked out, without counting it. Once there were too many failures, each
+further one locks them for twice as long as the one before, see throttlePolicy. Counting first means concurrent
+attempts cannot all pass the lockout check before any of them failed. A successful login resets the email's count and
+takes its attempt back from the IP's.
+Attempts to retrieve the user by email from the repository. An unknown email gets the same answer as a wrong password,
+and counts as a failure all the same. Its password is still compared, against a dummy hash, so the response time does
+not tell which emails are registered.
 Compares the provided password with the user's stored hashed password using the PasswordService.
-Generates an authentication token for the user using the TokenService.
+Suspended users are refused once their password has been checked, so the response does not reveal suspended accounts
+to anyone without the password.
+A password hash made with an outdated algorithm or parameters is replaced while the password is at hand. A failure
+there is only logged, and the old hash keeps working until the next login.
+Starts a new server-side session and issues a refresh token for it, storing only the token's hash.
+Generates a short-lived access token bound to that session using the TokenService.
+Records the login as the user's last activity in Elasticsearch for the "recent" discover sort. A failure there is only
+logged, since it must not keep the user from logging in.
 */
 func LoginHandler(deps *LoginDeps) http.HandlerFunc {
+	var dummy dummyHash
 	return func(w http.ResponseWriter, r *http.Request) {
 		var lc models.LoginCredentials
 		if err := json.NewDecoder(r.Body).Decode(&lc); err != nil {
@@ -32,18 +58,45 @@ func LoginHandler(deps *LoginDeps) http.HandlerFunc {
 			return
 		}
 
+		// Emails are stored in lower case by signup
+		lc.Email = strings.ToLower(strings.TrimSpace(lc.Email))
+
 		if err := validation.ValidateLogin(lc); err != nil {
 			log.Printf("Validation Failure: %v", err)
 			http.Error(w, "Invalid email or password", http.StatusBadRequest)
 			return
 		}
 
+		emailKey := emailThrottle.key(lc.Email)
+		ipKey := ipThrottle.key(clientIP(r, deps.TrustForwardedFor))
+
+		lockedUntil, err := reserveAttempt(deps, emailKey, ipKey)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if retryAfter := time.Until(lockedUntil); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many failed login attempts", http.StatusTooManyRequests)
+			return
+		}
+
 		user, err := deps.UserRepo.GetUserByEmail(lc.Email)
 		if err != nil {
 			log.Printf("Query Failure: %v", err)
 			http.Error(w, "Server error", http.StatusInternalServerError)
 			return
 		}
+		if user == nil {
+			if hashed, err := dummy.get(deps.PasswordService); err != nil {
+				log.Printf("Password Hashing Failure: %v", err)
+			} else {
+				deps.PasswordService.CompareHashAndPassword(hashed, lc.Password)
+			}
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
 
 		if err = deps.PasswordService.CompareHashAndPassword(user.PasswordHashed, lc.Password); err != nil {
 			log.Printf("Password Dycrption Failure: %v", err)
@@ -51,14 +104,58 @@ func LoginHandler(deps *LoginDeps) http.HandlerFunc {
 			return
 		}
 
-		token, err := deps.TokenService.GenerateToken(user.UserID)
+		releaseAttempt(deps, emailKey, ipKey)
+
+		if user.Suspended {
+			http.Error(w, "Account suspended", http.StatusForbidden)
+			return
+		}
+
+		if deps.PasswordService.NeedsRehash(user.PasswordHashed) {
+			if hashedPassword, err := deps.PasswordService.GenerateHashedPassword(lc.Password); err != nil {
+				log.Printf("Password Hashing Failure: %v", err)
+			} else if err = deps.UserRepo.UpdatePasswordHash(user.UserID, user.PasswordHashed, hashedPassword); err != nil {
+				log.Printf("Query Failure: %v", err)
+			}
+		}
+
+		sessionID := uuid.New().String()
+		refreshToken, err := deps.TokenService.GenerateRefreshToken(sessionID)
 		if err != nil {
 			log.Printf("Token Generation Failure: %v", err)
 			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 			return
 		}
 
-		response := models.LoginResponse{Token: token}
+		session := models.Session{
+			SessionID:        sessionID,
+			UserID:           user.UserID,
+			RefreshTokenHash: refreshToken.Hash,
+			CreatedAt:        time.Now().Unix(),
+			ExpiresAt:        refreshToken.ExpiresAt.Unix(),
+		}
+		if err = deps.SessionRepo.CreateSession(session); err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		token, err := deps.TokenService.GenerateToken(user.UserID, sessionID)
+		if err != nil {
+			log.Printf("Token Generation Failure: %v", err)
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		if err = deps.UserRepoES.UpdateLastActive(user.UserID, time.Now().Unix()); err != nil {
+			log.Printf("Query Failure: %v", err)
+		}
+
+		response := models.LoginResponse{
+			Token:        token,
+			RefreshToken: refreshToken.Token,
+			ExpiresIn:    int64(authentication.AccessTokenTTL.Seconds()),
+		}
 		w.Header().Set("Content-Type", "application/json")
 		if err = json.NewEncoder(w).Encode(response); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -66,3 +163,46 @@ func LoginHandler(deps *LoginDeps) http.HandlerFunc {
 		}
 	}
 }
+
+/*
+reserveAttempt counts the attempt against the client IP and the email, and returns when the lockout of the first of
+them that is locked ends. The IP goes first, so an address that is locked out cannot add to the failures of the emails
+it tries. The attempt stays counted as a failure unless releaseAttempt takes it back.
+*/
+func reserveAttempt(deps *LoginDeps, emailKey, ipKey string) (time.Time, error) {
+	until, err := ipThrottle.reserve(deps.ThrottleRepo, ipKey)
+	if err != nil || !until.IsZero() {
+		return until, err
+	}
+	return emailThrottle.reserve(deps.ThrottleRepo, emailKey)
+}
+
+// releaseAttempt resets the email's failures after a successful login and takes its attempt back from the client IP. A
+// failure there is only logged.
+func releaseAttempt(deps *LoginDeps, emailKey, ipKey string) {
+	if err := deps.ThrottleRepo.ClearLoginFailures(emailKey); err != nil {
+		log.Printf("Query Failure: %v", err)
+	}
+	if err := deps.ThrottleRepo.ReleaseLoginAttempt(ipKey, time.Now().Unix()); err != nil {
+		log.Printf("Query Failure: %v", err)
+	}
+}
+
+// dummyHash is a hash of a random password, made once by the configured PasswordService.
+type dummyHash struct {
+	mu     sync.Mutex
+	hashed string
+}
+
+func (d *dummyHash) get(passwordService services.PasswordService) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hashed == "" {
+		hashed, err := passwordService.GenerateHashedPassword(uuid.New().String())
+		if err != nil {
+			return "", err
+		}
+		d.hashed = hashed
+	}
+	return d.hashed, nil
+}
@@ -8,8 +8,12 @@ import (
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"quick-match/internal/middleware/authentication"
 	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type MockLoginUserRepo struct {
@@ -25,6 +29,11 @@ func (m *MockLoginUserRepo) GetUserByEmail(email string) (*models.UserDetails, e
 	return user.(*models.UserDetails), args.Error(1)
 }
 
+func (m *MockLoginUserRepo) UpdatePasswordHash(userID, oldHash, newHash string) error {
+	args := m.Called(userID, oldHash, newHash)
+	return args.Error(0)
+}
+
 func (m *MockLoginUserRepo) InsertUser(user models.UserDetails) error {
 	args := m.Called(user)
 	return args.Error(0)
@@ -34,11 +43,34 @@ type MockTokenService struct {
 	mock.Mock
 }
 
-func (m *MockTokenService) GenerateToken(userID string) (string, error) {
-	args := m.Called(userID)
+func (m *MockTokenService) GenerateToken(userID, sessionID string) (string, error) {
+	args := m.Called(userID, sessionID)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockTokenService) GenerateRefreshToken(sessionID string) (authentication.RefreshToken, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).(authentication.RefreshToken), args.Error(1)
+}
+
+type MockSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepo) CreateSession(session models.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+type MockLastActiveRepo struct {
+	mock.Mock
+}
+
+func (m *MockLastActiveRepo) UpdateLastActive(userID string, lastActiveAt int64) error {
+	args := m.Called(userID, lastActiveAt)
+	return args.Error(0)
+}
+
 type MockPasswordService struct {
 	mock.Mock
 }
@@ -53,11 +85,44 @@ func (m *MockPasswordService) GenerateHashedPassword(password string) (string, e
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockPasswordService) NeedsRehash(hashedPassword string) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
+
+type MockLoginThrottleRepo struct {
+	mock.Mock
+}
+
+func (m *MockLoginThrottleRepo) GetLoginThrottle(key string) (*models.LoginThrottle, error) {
+	args := m.Called(key)
+	throttle := args.Get(0)
+	if throttle == nil {
+		return nil, args.Error(1)
+	}
+	return throttle.(*models.LoginThrottle), args.Error(1)
+}
+
+func (m *MockLoginThrottleRepo) RecordLoginAttempt(key string, seen *models.LoginThrottle, now int64, window time.Duration) error {
+	args := m.Called(key, seen, now, window)
+	return args.Error(0)
+}
+
+func (m *MockLoginThrottleRepo) ReleaseLoginAttempt(key string, now int64) error {
+	args := m.Called(key, now)
+	return args.Error(0)
+}
+
+func (m *MockLoginThrottleRepo) ClearLoginFailures(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
 func TestLoginHandler(t *testing.T) {
 	tests := []struct {
 		name             string
 		body             models.LoginCredentials
-		setupMocks       func(*MockLoginUserRepo, *MockTokenService, *MockPasswordService)
+		setupMocks       func(*MockLoginUserRepo, *MockTokenService, *MockPasswordService, *MockSessionRepo, *MockLastActiveRepo)
 		expectedStatus   int
 		expectedResponse *models.LoginResponse
 		expectError      bool
@@ -66,18 +131,88 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "successful login",
 			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
-			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService) {
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
 				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword"}, nil)
 				mp.On("CompareHashAndPassword", "hashedpassword", "password").Return(nil)
-				mt.On("GenerateToken", "123").Return("token123", nil)
+				mp.On("NeedsRehash", "hashedpassword").Return(false)
+				mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+				ms.On("CreateSession", mock.MatchedBy(func(s models.Session) bool {
+					return s.UserID == "123" && s.RefreshTokenHash == "hash123"
+				})).Return(nil)
+				mt.On("GenerateToken", "123", mock.AnythingOfType("string")).Return("token123", nil)
+				ma.On("UpdateLastActive", "123", mock.AnythingOfType("int64")).Return(nil)
 			},
 			expectedStatus:   http.StatusOK,
-			expectedResponse: &models.LoginResponse{Token: "token123"},
+			expectedResponse: &models.LoginResponse{Token: "token123", RefreshToken: "session.refresh123"},
 		},
 		{
-			name:             "validation failure",
-			body:             models.LoginCredentials{Email: "invalidemail", Password: "password"},
-			setupMocks:       func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService) {},
+			name: "last activity update failure does not block login",
+			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword"}, nil)
+				mp.On("CompareHashAndPassword", "hashedpassword", "password").Return(nil)
+				mp.On("NeedsRehash", "hashedpassword").Return(false)
+				mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+				ms.On("CreateSession", mock.AnythingOfType("models.Session")).Return(nil)
+				mt.On("GenerateToken", "123", mock.AnythingOfType("string")).Return("token123", nil)
+				ma.On("UpdateLastActive", "123", mock.AnythingOfType("int64")).Return(errors.New("es error"))
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.LoginResponse{Token: "token123", RefreshToken: "session.refresh123"},
+		},
+		{
+			name: "session store failure",
+			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword"}, nil)
+				mp.On("CompareHashAndPassword", "hashedpassword", "password").Return(nil)
+				mp.On("NeedsRehash", "hashedpassword").Return(false)
+				mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+				ms.On("CreateSession", mock.AnythingOfType("models.Session")).Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectError:      true,
+			expectedErrorMsg: "Server error",
+		},
+		{
+			name: "outdated hash is rehashed",
+			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "$2a$10$bcrypthash"}, nil)
+				mp.On("CompareHashAndPassword", "$2a$10$bcrypthash", "password").Return(nil)
+				mp.On("NeedsRehash", "$2a$10$bcrypthash").Return(true)
+				mp.On("GenerateHashedPassword", "password").Return("$argon2id$newhash", nil)
+				mr.On("UpdatePasswordHash", "123", "$2a$10$bcrypthash", "$argon2id$newhash").Return(nil)
+				mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+				ms.On("CreateSession", mock.AnythingOfType("models.Session")).Return(nil)
+				mt.On("GenerateToken", "123", mock.AnythingOfType("string")).Return("token123", nil)
+				ma.On("UpdateLastActive", "123", mock.AnythingOfType("int64")).Return(nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.LoginResponse{Token: "token123", RefreshToken: "session.refresh123"},
+		},
+		{
+			name: "rehash failure does not block login",
+			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "$2a$10$bcrypthash"}, nil)
+				mp.On("CompareHashAndPassword", "$2a$10$bcrypthash", "password").Return(nil)
+				mp.On("NeedsRehash", "$2a$10$bcrypthash").Return(true)
+				mp.On("GenerateHashedPassword", "password").Return("$argon2id$newhash", nil)
+				mr.On("UpdatePasswordHash", "123", "$2a$10$bcrypthash", "$argon2id$newhash").Return(errors.New("db error"))
+				mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+				ms.On("CreateSession", mock.AnythingOfType("models.Session")).Return(nil)
+				mt.On("GenerateToken", "123", mock.AnythingOfType("string")).Return("token123", nil)
+				ma.On("UpdateLastActive", "123", mock.AnythingOfType("int64")).Return(nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.LoginResponse{Token: "token123", RefreshToken: "session.refresh123"},
+		},
+		{
+			name: "validation failure",
+			body: models.LoginCredentials{Email: "invalidemail", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+			},
 			expectedStatus:   http.StatusBadRequest,
 			expectError:      true,
 			expectedErrorMsg: "Invalid email or password",
@@ -85,17 +220,29 @@ func TestLoginHandler(t *testing.T) {
 		{
 			name: "user not found",
 			body: models.LoginCredentials{Email: "missing@example.com", Password: "password"},
-			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService) {
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
 				mr.On("GetUserByEmail", "missing@example.com").Return(nil, errors.New("user not found"))
 			},
 			expectedStatus:   http.StatusInternalServerError,
 			expectError:      true,
 			expectedErrorMsg: "Server error",
 		},
+		{
+			name: "unknown email",
+			body: models.LoginCredentials{Email: "Nobody@Example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "nobody@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("dummyhash", nil)
+				mp.On("CompareHashAndPassword", "dummyhash", "password").Return(errors.New("incorrect password"))
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectError:      true,
+			expectedErrorMsg: "Invalid credentials",
+		},
 		{
 			name: "incorrect password",
 			body: models.LoginCredentials{Email: "user@example.com", Password: "wrongpassword"},
-			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService) {
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
 				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword"}, nil)
 				mp.On("CompareHashAndPassword", "hashedpassword", "wrongpassword").Return(errors.New("incorrect password"))
 			},
@@ -103,6 +250,17 @@ func TestLoginHandler(t *testing.T) {
 			expectError:      true,
 			expectedErrorMsg: "Invalid credentials",
 		},
+		{
+			name: "suspended user",
+			body: models.LoginCredentials{Email: "user@example.com", Password: "password"},
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(&models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword", Suspended: true}, nil)
+				mp.On("CompareHashAndPassword", "hashedpassword", "password").Return(nil)
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectError:      true,
+			expectedErrorMsg: "Account suspended",
+		},
 	}
 
 	for _, tt := range tests {
@@ -110,12 +268,24 @@ func TestLoginHandler(t *testing.T) {
 			mockRepo := new(MockLoginUserRepo)
 			mockTokenService := new(MockTokenService)
 			mockPasswordService := new(MockPasswordService)
-			tt.setupMocks(mockRepo, mockTokenService, mockPasswordService)
+			mockSessionRepo := new(MockSessionRepo)
+			mockLastActiveRepo := new(MockLastActiveRepo)
+			tt.setupMocks(mockRepo, mockTokenService, mockPasswordService, mockSessionRepo, mockLastActiveRepo)
+
+			// Lockouts are covered by TestLoginHandlerThrottling
+			mockThrottleRepo := new(MockLoginThrottleRepo)
+			mockThrottleRepo.On("GetLoginThrottle", mock.Anything).Return(nil, nil).Maybe()
+			mockThrottleRepo.On("RecordLoginAttempt", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+			mockThrottleRepo.On("ReleaseLoginAttempt", mock.Anything, mock.Anything).Return(nil).Maybe()
+			mockThrottleRepo.On("ClearLoginFailures", mock.Anything).Return(nil).Maybe()
 
 			deps := LoginDeps{
 				UserRepo:        mockRepo,
 				TokenService:    mockTokenService,
 				PasswordService: mockPasswordService,
+				SessionRepo:     mockSessionRepo,
+				UserRepoES:      mockLastActiveRepo,
+				ThrottleRepo:    mockThrottleRepo,
 			}
 
 			handler := LoginHandler(&deps)
@@ -136,11 +306,394 @@ func TestLoginHandler(t *testing.T) {
 				err := json.NewDecoder(rr.Body).Decode(&response)
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedResponse.Token, response.Token)
+				assert.Equal(t, tt.expectedResponse.RefreshToken, response.RefreshToken)
 			}
 
 			mockRepo.AssertExpectations(t)
 			mockTokenService.AssertExpectations(t)
 			mockPasswordService.AssertExpectations(t)
+			mockSessionRepo.AssertExpectations(t)
+			mockLastActiveRepo.AssertExpectations(t)
 		})
 	}
 }
+
+func TestLoginHandlerThrottling(t *testing.T) {
+	user := &models.UserDetails{UserID: "123", Email: "user@example.com", PasswordHashed: "hashedpassword"}
+	now := time.Now().Unix()
+
+	failures := func(count int, lastFailureAt int64) *models.LoginThrottle {
+		return &models.LoginThrottle{Failures: count, LastFailureAt: lastFailureAt, ExpiresAt: lastFailureAt + int64(failureWindow.Seconds())}
+	}
+	// counted sets up the counters of the client IP and the email, expects the attempt to be counted against both, and
+	// a successful login to take it back
+	counted := func(ipKey string, ip, email *models.LoginThrottle, released bool) func(*MockLoginThrottleRepo) {
+		return func(mth *MockLoginThrottleRepo) {
+			mth.On("GetLoginThrottle", ipKey).Return(ip, nil)
+			mth.On("RecordLoginAttempt", ipKey, ip, mock.AnythingOfType("int64"), failureWindow).Return(nil)
+			mth.On("GetLoginThrottle", "email#user@example.com").Return(email, nil)
+			mth.On("RecordLoginAttempt", "email#user@example.com", email, mock.AnythingOfType("int64"), failureWindow).Return(nil)
+			if released {
+				mth.On("ClearLoginFailures", "email#user@example.com").Return(nil)
+				mth.On("ReleaseLoginAttempt", ipKey, mock.AnythingOfType("int64")).Return(nil)
+			}
+		}
+	}
+
+	// successfulLogin sets up everything after the throttle check for a login that goes through
+	successfulLogin := func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+		mr.On("GetUserByEmail", "user@example.com").Return(user, nil)
+		mp.On("CompareHashAndPassword", "hashedpassword", "password").Return(nil)
+		mp.On("NeedsRehash", "hashedpassword").Return(false)
+		mt.On("GenerateRefreshToken", mock.AnythingOfType("string")).Return(authentication.RefreshToken{Token: "session.refresh123", Hash: "hash123"}, nil)
+		ms.On("CreateSession", mock.AnythingOfType("models.Session")).Return(nil)
+		mt.On("GenerateToken", "123", mock.AnythingOfType("string")).Return("token123", nil)
+		ma.On("UpdateLastActive", "123", mock.AnythingOfType("int64")).Return(nil)
+	}
+	refused := func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+	}
+
+	tests := []struct {
+		name               string
+		password           string
+		remoteAddr         string
+		forwardedFor       string
+		trustForwardedFor  bool
+		setupThrottle      func(*MockLoginThrottleRepo)
+		setupMocks         func(*MockLoginUserRepo, *MockTokenService, *MockPasswordService, *MockSessionRepo, *MockLastActiveRepo)
+		expectedStatus     int
+		expectedRetryAfter int
+		expectedErrorMsg   string
+	}{
+		{
+			name:           "successful login clears the email's failures and takes its attempt back from the IP",
+			password:       "password",
+			setupThrottle:  counted("ip#192.0.2.1", failures(2, now), failures(2, now), true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:          "wrong password stays counted against the IP and the email",
+			password:      "wrongpassword",
+			setupThrottle: counted("ip#192.0.2.1", nil, nil, false),
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(user, nil)
+				mp.On("CompareHashAndPassword", "hashedpassword", "wrongpassword").Return(errors.New("incorrect password"))
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid credentials",
+		},
+		{
+			name:          "unknown email stays counted as a failure and is checked against a dummy hash",
+			password:      "password",
+			setupThrottle: counted("ip#192.0.2.1", nil, nil, false),
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("dummyhash", nil).Once()
+				mp.On("CompareHashAndPassword", "dummyhash", "password").Return(errors.New("incorrect password"))
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid credentials",
+		},
+		{
+			name:          "unknown email without a dummy hash gets the same answer",
+			password:      "password",
+			setupThrottle: counted("ip#192.0.2.1", nil, nil, false),
+			setupMocks: func(mr *MockLoginUserRepo, mt *MockTokenService, mp *MockPasswordService, ms *MockSessionRepo, ma *MockLastActiveRepo) {
+				mr.On("GetUserByEmail", "user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("", errors.New("hash error"))
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid credentials",
+		},
+		{
+			name:     "failure to release an attempt does not change the answer",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", mock.Anything).Return(nil, nil)
+				mth.On("RecordLoginAttempt", mock.Anything, (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(nil)
+				mth.On("ClearLoginFailures", "email#user@example.com").Return(errors.New("db error"))
+				mth.On("ReleaseLoginAttempt", "ip#192.0.2.1", mock.AnythingOfType("int64")).Return(errors.New("db error"))
+			},
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "free failures do not lock the email",
+			password:       "password",
+			setupThrottle:  counted("ip#192.0.2.1", nil, failures(emailThrottle.freeFailures, now), true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "email locked after too many failures, without counting the refused attempt",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(nil)
+				mth.On("GetLoginThrottle", "email#user@example.com").Return(failures(emailThrottle.freeFailures+1, now+60), nil)
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: 61,
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:     "lockout doubles with every further failure",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(nil)
+				mth.On("GetLoginThrottle", "email#user@example.com").Return(failures(emailThrottle.freeFailures+6, now), nil)
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: 32,
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:     "lockout is capped",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(nil)
+				mth.On("GetLoginThrottle", "email#user@example.com").Return(failures(500, now), nil)
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: int(emailThrottle.maxLockout.Seconds()),
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:           "lockout over",
+			password:       "password",
+			setupThrottle:  counted("ip#192.0.2.1", nil, failures(emailThrottle.freeFailures+6, now-60), true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "IP locked after too many failures, without counting against the email",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(failures(ipThrottle.freeFailures+3, now), nil)
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: 4,
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:           "IP below its limit does not lock",
+			password:       "password",
+			setupThrottle:  counted("ip#192.0.2.1", failures(ipThrottle.freeFailures, now), nil, true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "concurrent attempt is decided on the counter it changed",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				seen := failures(emailThrottle.freeFailures, now)
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(nil)
+				mth.On("GetLoginThrottle", "email#user@example.com").Return(seen, nil).Once()
+				mth.On("RecordLoginAttempt", "email#user@example.com", seen, mock.AnythingOfType("int64"), failureWindow).Return(repository.ErrLoginThrottleChanged)
+				mth.On("GetLoginThrottle", "email#user@example.com").Return(failures(emailThrottle.freeFailures+1, now), nil).Once()
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: 1,
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:     "attempts that keep losing the race for the counter are held back",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil).Times(maxReserveAttempts)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).
+					Return(repository.ErrLoginThrottleChanged).Times(maxReserveAttempts)
+			},
+			setupMocks:         refused,
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: 1,
+			expectedErrorMsg:   "Too many failed login attempts",
+		},
+		{
+			name:              "forwarded address is used behind a trusted proxy",
+			password:          "password",
+			remoteAddr:        "10.0.0.5:41000",
+			forwardedFor:      "203.0.113.9, 198.51.100.7",
+			trustForwardedFor: true,
+			setupThrottle:     counted("ip#198.51.100.7", nil, nil, true),
+			setupMocks:        successfulLogin,
+			expectedStatus:    http.StatusOK,
+		},
+		{
+			name:           "forwarded address is ignored without a trusted proxy",
+			password:       "password",
+			forwardedFor:   "198.51.100.7",
+			setupThrottle:  counted("ip#192.0.2.1", nil, nil, true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "IPv6 addresses are throttled by their /64",
+			password:       "password",
+			remoteAddr:     "[2001:db8:1:2:aaaa:bbbb:cccc:dddd]:41000",
+			setupThrottle:  counted("ip#2001:db8:1:2::", nil, nil, true),
+			setupMocks:     successfulLogin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:     "throttle lookup failure",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, errors.New("db error"))
+			},
+			setupMocks:       refused,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Server error",
+		},
+		{
+			name:     "failure to count the attempt",
+			password: "password",
+			setupThrottle: func(mth *MockLoginThrottleRepo) {
+				mth.On("GetLoginThrottle", "ip#192.0.2.1").Return(nil, nil)
+				mth.On("RecordLoginAttempt", "ip#192.0.2.1", (*models.LoginThrottle)(nil), mock.AnythingOfType("int64"), failureWindow).Return(errors.New("db error"))
+			},
+			setupMocks:       refused,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockLoginUserRepo)
+			mockTokenService := new(MockTokenService)
+			mockPasswordService := new(MockPasswordService)
+			mockSessionRepo := new(MockSessionRepo)
+			mockLastActiveRepo := new(MockLastActiveRepo)
+			mockThrottleRepo := new(MockLoginThrottleRepo)
+			tt.setupMocks(mockRepo, mockTokenService, mockPasswordService, mockSessionRepo, mockLastActiveRepo)
+			tt.setupThrottle(mockThrottleRepo)
+
+			deps := LoginDeps{
+				UserRepo:          mockRepo,
+				TokenService:      mockTokenService,
+				PasswordService:   mockPasswordService,
+				SessionRepo:       mockSessionRepo,
+				UserRepoES:        mockLastActiveRepo,
+				ThrottleRepo:      mockThrottleRepo,
+				TrustForwardedFor: tt.trustForwardedFor,
+			}
+
+			handler := LoginHandler(&deps)
+
+			bodyBytes, _ := json.Marshal(models.LoginCredentials{Email: "user@example.com", Password: tt.password})
+			req := httptest.NewRequest("POST", "/login", bytes.NewBuffer(bodyBytes))
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+			if tt.expectedRetryAfter > 0 {
+				// The lockout is counted from whole seconds, so it can end up to a second earlier than expected
+				retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+				assert.NoError(t, err)
+				assert.InDelta(t, tt.expectedRetryAfter, retryAfter, 1)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockTokenService.AssertExpectations(t)
+			mockPasswordService.AssertExpectations(t)
+			mockSessionRepo.AssertExpectations(t)
+			mockLastActiveRepo.AssertExpectations(t)
+			mockThrottleRepo.AssertExpectations(t)
+		})
+	}
+}
+
+// fakeLoginThrottleRepo keeps counters in memory and only writes them if they did not change since they were read
+type fakeLoginThrottleRepo struct {
+	throttles map[string]models.LoginThrottle
+}
+
+func (f *fakeLoginThrottleRepo) GetLoginThrottle(key string) (*models.LoginThrottle, error) {
+	throttle, ok := f.throttles[key]
+	if !ok {
+		return nil, nil
+	}
+	return &throttle, nil
+}
+
+func (f *fakeLoginThrottleRepo) RecordLoginAttempt(key string, seen *models.LoginThrottle, now int64, window time.Duration) error {
+	current, ok := f.throttles[key]
+	if (seen == nil) == ok || (seen != nil && *seen != current) {
+		return repository.ErrLoginThrottleChanged
+	}
+	current.Key = key
+	current.Failures++
+	current.LastFailureAt = now
+	current.ExpiresAt = now + int64(window.Seconds())
+	f.throttles[key] = current
+	return nil
+}
+
+func (f *fakeLoginThrottleRepo) ReleaseLoginAttempt(key string, now int64) error {
+	if current, ok := f.throttles[key]; ok && current.Failures > 0 {
+		current.Failures--
+		f.throttles[key] = current
+	}
+	return nil
+}
+
+func (f *fakeLoginThrottleRepo) ClearLoginFailures(key string) error {
+	delete(f.throttles, key)
+	return nil
+}
+
+// Attempts refused during a lockout are not counted, so retrying does not make the lockout any longer
+func TestLoginHandlerRetriesDuringLockout(t *testing.T) {
+	mockRepo := new(MockLoginUserRepo)
+	mockPasswordService := new(MockPasswordService)
+	// The email is locked for 8 seconds from now
+	now := time.Now().Unix()
+	locked := models.LoginThrottle{Key: emailThrottle.key("user@example.com"), Failures: emailThrottle.freeFailures + 4, LastFailureAt: now, ExpiresAt: now + int64(failureWindow.Seconds())}
+	throttleRepo := &fakeLoginThrottleRepo{throttles: map[string]models.LoginThrottle{locked.Key: locked}}
+
+	handler := LoginHandler(&LoginDeps{
+		UserRepo:        mockRepo,
+		TokenService:    new(MockTokenService),
+		PasswordService: mockPasswordService,
+		SessionRepo:     new(MockSessionRepo),
+		UserRepoES:      new(MockLastActiveRepo),
+		ThrottleRepo:    throttleRepo,
+	})
+	login := func() *httptest.ResponseRecorder {
+		bodyBytes, _ := json.Marshal(models.LoginCredentials{Email: "user@example.com", Password: "wrongpassword"})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/login", bytes.NewBuffer(bodyBytes)))
+		return rr
+	}
+
+	for i := 0; i < 5; i++ {
+		rr := login()
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+		assert.NoError(t, err)
+		assert.InDelta(t, 8, retryAfter, 1)
+	}
+
+	assert.Equal(t, locked, throttleRepo.throttles[locked.Key])
+	mockPasswordService.AssertNotCalled(t, "CompareHashAndPassword", mock.Anything, mock.Anything)
+}
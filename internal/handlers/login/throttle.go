@@ -0,0 +1,117 @@
+package login
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strings"
+	"time"
+)
+
+// failureWindow is how long failed logins keep counting after the last one.
+const failureWindow = time.Hour
+
+// maxReserveAttempts is how often reserve reads the counter again after a concurrent attempt changed it.
+const maxReserveAttempts = 5
+
+/*
+throttlePolicy is how failed logins against one kind of key slow down the next attempts. The first freeFailures are not
+held against anyone. Every failure from then on locks the key for baseDelay, doubled for each failure beyond
+freeFailures, up to maxLockout.
+*/
+type throttlePolicy struct {
+	prefix       string
+	freeFailures int
+	baseDelay    time.Duration
+	maxLockout   time.Duration
+}
+
+var emailThrottle = throttlePolicy{
+	prefix:       "email#",
+	freeFailures: 4,
+	baseDelay:    time.Second,
+	maxLockout:   15 * time.Minute,
+}
+
+// Many users can share an address behind a NAT, so an IP is allowed more failures than a single email
+var ipThrottle = throttlePolicy{
+	prefix:       "ip#",
+	freeFailures: 20,
+	baseDelay:    time.Second,
+	maxLockout:   15 * time.Minute,
+}
+
+func (p throttlePolicy) key(value string) string {
+	return p.prefix + value
+}
+
+// lockedUntil is when the key may be tried again. The zero Time means it is not locked.
+func (p throttlePolicy) lockedUntil(throttle *models.LoginThrottle) time.Time {
+	if throttle == nil || throttle.Failures <= p.freeFailures {
+		return time.Time{}
+	}
+
+	lockout := p.maxLockout
+	// Shifting further would overflow long before reaching maxLockout
+	if shift := throttle.Failures - p.freeFailures - 1; shift < 32 {
+		lockout = min(p.baseDelay<<shift, p.maxLockout)
+	}
+	return time.Unix(throttle.LastFailureAt, 0).Add(lockout)
+}
+
+/*
+reserve counts an attempt against key, unless the key is locked, in which case it returns when the lock ends. The
+count is only written if it did not change since it was read, so concurrent attempts cannot all pass the lock check
+before any of them is counted. An attempt refused while locked is not counted, so retrying during a lockout does not
+extend it.
+*/
+func (p throttlePolicy) reserve(repo repository.LoginThrottleRepo, key string) (time.Time, error) {
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		throttle, err := repo.GetLoginThrottle(key)
+		if err != nil {
+			return time.Time{}, err
+		}
+		now := time.Now()
+		if until := p.lockedUntil(throttle); until.After(now) {
+			return until, nil
+		}
+
+		err = repo.RecordLoginAttempt(key, throttle, now.Unix(), failureWindow)
+		if !errors.Is(err, repository.ErrLoginThrottleChanged) {
+			return time.Time{}, err
+		}
+	}
+
+	// Attempts that keep losing the race for the counter are held back briefly rather than failed
+	return time.Now().Add(time.Second), nil
+}
+
+/*
+clientIP is the address a request came from. With trustForwardedFor, that is the last address in X-Forwarded-For, the
+one added by our own load balancer; anything before it is supplied by the client and cannot be trusted. IPv6 addresses
+are cut down to their /64 prefix, since a single client is usually handed a whole /64.
+*/
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	if trustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			addr = strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+	return ip.String()
+}
@@ -0,0 +1,36 @@
+package logout
+
+import (
+	"log"
+	"net/http"
+	"quick-match/internal/repository"
+)
+
+type LogoutDeps struct {
+	SessionRepo repository.RevokeSessionRepo
+}
+
+/*
+LogoutHandler revokes the session the caller's access token was issued for.
+Both the access token and the refresh token of that session stop working immediately, since JWTMiddleware and the
+refresh endpoint check the session on every use.
+*/
+func LogoutHandler(deps *LogoutDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract SessionID from context, set by JWTMiddleware
+		SessionID, ok := r.Context().Value("SessionID").(string)
+		if !ok {
+			log.Println("Could not extract SessionID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		if err := deps.SessionRepo.RevokeSession(SessionID); err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,70 @@
+package logout
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type MockRevokeSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockRevokeSessionRepo) RevokeSession(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+func TestLogoutHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockSetup      func(m *MockRevokeSessionRepo)
+		expectedStatus int
+		sessionID      string
+	}{
+		{
+			name: "successful logout",
+			mockSetup: func(m *MockRevokeSessionRepo) {
+				m.On("RevokeSession", "session1").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+			sessionID:      "session1",
+		},
+		{
+			name: "error revoking session",
+			mockSetup: func(m *MockRevokeSessionRepo) {
+				m.On("RevokeSession", "session1").Return(errors.New("db error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			sessionID:      "session1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockRevokeSessionRepo)
+			tt.mockSetup(mockRepo)
+
+			deps := LogoutDeps{
+				SessionRepo: mockRepo,
+			}
+
+			handler := LogoutHandler(&deps)
+
+			req, _ := http.NewRequest("POST", "/logout", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "SessionID", tt.sessionID)) // Simulate JWTMiddleware setting SessionID in context
+
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package matches
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type MatchesDeps struct {
+	MatchRepo  repository.ListMatchesRepo
+	UserRepoES repository.GetUsersESRepo
+}
+
+/*
+ListMatchesHandler returns the authenticated user's matches, most recent first.
+Extracts the UserID from the request context.
+Reads one page of match records, sized by the optional `limit` query parameter and continued with `cursor`.
+Fetches the other user of every match from Elasticsearch in a single request and returns only their public profile.
+Matches whose other user is no longer indexed are left out of the page.
+*/
+func ListMatchesHandler(deps *MatchesDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		limit := defaultPageSize
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsed, err := strconv.Atoi(l)
+			if err != nil || parsed < 1 || parsed > maxPageSize {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		page, err := deps.MatchRepo.ListMatches(UserID, limit, r.URL.Query().Get("cursor"))
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to list matches", http.StatusInternalServerError)
+			return
+		}
+
+		matchedUserIDs := make([]string, 0, len(page.Matches))
+		for _, m := range page.Matches {
+			matchedUserIDs = append(matchedUserIDs, m.MatchedUserID)
+		}
+
+		profiles, err := deps.UserRepoES.GetUsersByIDs(matchedUserIDs)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch matched users from Elasticsearch", http.StatusInternalServerError)
+			return
+		}
+
+		response := models.MatchesResponse{
+			Matches:    []models.MatchView{},
+			NextCursor: page.NextCursor,
+		}
+		for _, m := range page.Matches {
+			profile, found := profiles[m.MatchedUserID]
+			if !found {
+				continue
+			}
+			response.Matches = append(response.Matches, models.MatchView{
+				MatchID:   m.MatchID,
+				MatchedAt: m.MatchedAt,
+				User:      profile.PublicProfile(),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
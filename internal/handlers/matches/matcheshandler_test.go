@@ -0,0 +1,159 @@
+package matches
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+)
+
+type MockListMatchesRepo struct {
+	mock.Mock
+}
+
+func (m *MockListMatchesRepo) ListMatches(userID string, limit int, cursor string) (models.MatchPage, error) {
+	args := m.Called(userID, limit, cursor)
+	return args.Get(0).(models.MatchPage), args.Error(1)
+}
+
+type MockGetUsersESRepo struct {
+	mock.Mock
+}
+
+func (m *MockGetUsersESRepo) GetUsersByIDs(userIDs []string) (map[string]models.UserDetailsES, error) {
+	args := m.Called(userIDs)
+	users := args.Get(0)
+	if users == nil {
+		return nil, args.Error(1)
+	}
+	return users.(map[string]models.UserDetailsES), args.Error(1)
+}
+
+func TestListMatchesHandler(t *testing.T) {
+	page := models.MatchPage{
+		Matches: []models.Match{
+			{UserID: "user1", MatchID: "match2", MatchedUserID: "user3", MatchedAt: 200},
+			{UserID: "user1", MatchID: "match1", MatchedUserID: "user2", MatchedAt: 100},
+		},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		setupMocks       func(*MockListMatchesRepo, *MockGetUsersESRepo)
+		expectedStatus   int
+		expectedMatchIDs []string
+		expectedCursor   string
+		expectedErrorMsg string
+	}{
+		{
+			name: "lists matches with public profiles",
+			setupMocks: func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {
+				mm.On("ListMatches", "user1", defaultPageSize, "").Return(page, nil)
+				me.On("GetUsersByIDs", []string{"user3", "user2"}).Return(map[string]models.UserDetailsES{
+					"user2": {UserID: "user2", Name: "Two", Location: models.UserLocationES{Lat: 1, Lon: 2}},
+					"user3": {UserID: "user3", Name: "Three"},
+				}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedMatchIDs: []string{"match2", "match1"},
+			expectedCursor:   "next",
+		},
+		{
+			name:  "passes limit and cursor through",
+			query: "?limit=1&cursor=abc",
+			setupMocks: func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {
+				mm.On("ListMatches", "user1", 1, "abc").Return(models.MatchPage{}, nil)
+				me.On("GetUsersByIDs", []string{}).Return(map[string]models.UserDetailsES{}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedMatchIDs: []string{},
+		},
+		{
+			name: "skips matches whose user is no longer indexed",
+			setupMocks: func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {
+				mm.On("ListMatches", "user1", defaultPageSize, "").Return(page, nil)
+				me.On("GetUsersByIDs", []string{"user3", "user2"}).Return(map[string]models.UserDetailsES{
+					"user2": {UserID: "user2", Name: "Two"},
+				}, nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedMatchIDs: []string{"match1"},
+			expectedCursor:   "next",
+		},
+		{
+			name:             "invalid limit",
+			query:            "?limit=0",
+			setupMocks:       func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid limit",
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=garbage",
+			setupMocks: func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {
+				mm.On("ListMatches", "user1", defaultPageSize, "garbage").Return(models.MatchPage{}, repository.ErrInvalidCursor)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid cursor",
+		},
+		{
+			name: "query failure",
+			setupMocks: func(mm *MockListMatchesRepo, me *MockGetUsersESRepo) {
+				mm.On("ListMatches", "user1", defaultPageSize, "").Return(models.MatchPage{}, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to list matches",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMatchRepo := new(MockListMatchesRepo)
+			mockUserRepoES := new(MockGetUsersESRepo)
+			tt.setupMocks(mockMatchRepo, mockUserRepoES)
+
+			deps := MatchesDeps{
+				MatchRepo:  mockMatchRepo,
+				UserRepoES: mockUserRepoES,
+			}
+
+			handler := ListMatchesHandler(&deps)
+
+			req, _ := http.NewRequest("GET", "/matches"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				assert.NotContains(t, rr.Body.String(), "location")
+
+				var response models.MatchesResponse
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+
+				matchIDs := []string{}
+				for _, m := range response.Matches {
+					matchIDs = append(matchIDs, m.MatchID)
+				}
+				assert.Equal(t, tt.expectedMatchIDs, matchIDs)
+				assert.Equal(t, tt.expectedCursor, response.NextCursor)
+			}
+
+			mockMatchRepo.AssertExpectations(t)
+			mockUserRepoES.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package matches
+
+import (
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/events"
+	"quick-match/internal/repository"
+)
+
+type UnmatchDeps struct {
+	MatchRepo repository.UnmatchRepo
+	Events    events.Publisher
+}
+
+/*
+UnmatchHandler removes one of the authenticated user's matches.
+Extracts the UserID from the request context and the MatchID from the URL.
+Both match records are deleted and both swipes are turned into dislikes, so the two users do not show up in each
+other's discover results again. Their conversation can no longer be read or written.
+A match the user is not part of returns a 404, so match IDs cannot be probed.
+Both users are sent an "unmatched" event.
+*/
+func UnmatchHandler(deps *UnmatchDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		matchID := mux.Vars(r)["matchId"]
+
+		match, err := deps.MatchRepo.Unmatch(UserID, matchID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to unmatch", http.StatusInternalServerError)
+			return
+		}
+		if match == nil {
+			http.Error(w, "Match not found", http.StatusNotFound)
+			return
+		}
+
+		deps.Events.Publish(UserID, events.Event{Type: events.TypeUnmatched, Data: events.MatchData{MatchID: matchID, UserID: match.MatchedUserID}})
+		deps.Events.Publish(match.MatchedUserID, events.Event{Type: events.TypeUnmatched, Data: events.MatchData{MatchID: matchID, UserID: UserID}})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,108 @@
+package matches
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/events"
+	"quick-match/internal/models"
+	"testing"
+)
+
+type MockUnmatchRepo struct {
+	mock.Mock
+}
+
+func (m *MockUnmatchRepo) Unmatch(userID, matchID string) (*models.Match, error) {
+	args := m.Called(userID, matchID)
+	match := args.Get(0)
+	if match == nil {
+		return nil, args.Error(1)
+	}
+	return match.(*models.Match), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(userID string, event events.Event) {
+	m.Called(userID, event)
+}
+
+func TestUnmatchHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		userID           string
+		setupMocks       func(*MockUnmatchRepo, *MockPublisher)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "successful unmatch",
+			userID: "user1",
+			setupMocks: func(mu *MockUnmatchRepo, mp *MockPublisher) {
+				mu.On("Unmatch", "user1", "match1").Return(&models.Match{UserID: "user1", MatchID: "match1", MatchedUserID: "user2"}, nil)
+				mp.On("Publish", "user1", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeUnmatched && e.Data.(events.MatchData).UserID == "user2"
+				})).Return()
+				mp.On("Publish", "user2", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeUnmatched && e.Data.(events.MatchData).UserID == "user1"
+				})).Return()
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:   "match not found",
+			userID: "intruder",
+			setupMocks: func(mu *MockUnmatchRepo, mp *MockPublisher) {
+				mu.On("Unmatch", "intruder", "match1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Match not found",
+		},
+		{
+			name:   "error removing match",
+			userID: "user1",
+			setupMocks: func(mu *MockUnmatchRepo, mp *MockPublisher) {
+				mu.On("Unmatch", "user1", "match1").Return(nil, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to unmatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUnmatchRepo := new(MockUnmatchRepo)
+			mockPublisher := new(MockPublisher)
+			tt.setupMocks(mockUnmatchRepo, mockPublisher)
+
+			deps := UnmatchDeps{
+				MatchRepo: mockUnmatchRepo,
+				Events:    mockPublisher,
+			}
+
+			handler := UnmatchHandler(&deps)
+
+			req, _ := http.NewRequest("DELETE", "/matches/match1", nil)
+			req = mux.SetURLVars(req, map[string]string{"matchId": "match1"})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", tt.userID)) // Simulate JWTMiddleware setting UserID in context
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockUnmatchRepo.AssertExpectations(t)
+			mockPublisher.AssertExpectations(t)
+		})
+	}
+}
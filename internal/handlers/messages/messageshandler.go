@@ -0,0 +1,182 @@
+package messages
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/events"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+type MessagesDeps struct {
+	MatchRepo   repository.GetMatchRepo
+	MessageRepo repository.MessageRepo
+	BlockRepo   repository.BlockCheckRepo
+	Events      events.Publisher
+}
+
+/*
+SendMessageHandler adds a message to the conversation of a match.
+Extracts the UserID from the request context and the MatchID from the URL.
+Only the two users of the match can write to its conversation. Anyone else gets a 404, so match IDs cannot be probed.
+The same 404 is returned once either user has blocked the other.
+The message ID starts with the send time, so messages sort chronologically within a conversation.
+Both users are sent a "new_message" event, so the sender's other devices stay in sync too.
+*/
+func SendMessageHandler(deps *MessagesDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var smr models.SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&smr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		smr.Body = strings.TrimSpace(smr.Body)
+
+		if err := validation.ValidateMessage(smr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid message", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		matchID := mux.Vars(r)["matchId"]
+
+		match, ok := authorizeConversation(w, deps, UserID, matchID)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		message := models.Message{
+			MatchID:   matchID,
+			MessageID: fmt.Sprintf("%019d-%s", now.UnixNano(), uuid.New().String()),
+			SenderID:  UserID,
+			Body:      smr.Body,
+			SentAt:    now.UnixMilli(),
+		}
+		err := deps.MessageRepo.InsertMessage(message)
+		// Unmatched since the match was read
+		if errors.Is(err, repository.ErrMatchNotFound) {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to send message", http.StatusInternalServerError)
+			return
+		}
+		deps.Events.Publish(match.MatchedUserID, events.Event{Type: events.TypeNewMessage, Data: message})
+		deps.Events.Publish(UserID, events.Event{Type: events.TypeNewMessage, Data: message})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(message); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+ListMessagesHandler returns the conversation of a match, newest message first.
+Only the two users of the match can read it. Pages are sized by the optional `limit` query parameter and continued
+with `cursor`.
+*/
+func ListMessagesHandler(deps *MessagesDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		matchID := mux.Vars(r)["matchId"]
+
+		limit := defaultPageSize
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsed, err := strconv.Atoi(l)
+			if err != nil || parsed < 1 || parsed > maxPageSize {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		if _, ok = authorizeConversation(w, deps, UserID, matchID); !ok {
+			return
+		}
+
+		page, err := deps.MessageRepo.ListMessages(matchID, limit, r.URL.Query().Get("cursor"))
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to list messages", http.StatusInternalServerError)
+			return
+		}
+
+		response := models.MessagesResponse{
+			Messages:   page.Messages,
+			NextCursor: page.NextCursor,
+		}
+		if response.Messages == nil {
+			response.Messages = []models.Message{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+authorizeConversation returns the user's side of the match, or writes an error response and returns false if userID is
+not one of its users or the two users have blocked each other.
+*/
+func authorizeConversation(w http.ResponseWriter, deps *MessagesDeps, userID, matchID string) (*models.Match, bool) {
+	match, err := deps.MatchRepo.GetMatch(userID, matchID)
+	if err != nil {
+		log.Printf("Query Failure: %v", err)
+		http.Error(w, "Failed to fetch match", http.StatusInternalServerError)
+		return nil, false
+	}
+	if match == nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	blocked, err := deps.BlockRepo.IsBlocked(userID, match.MatchedUserID)
+	if err != nil {
+		log.Printf("Query Failure: %v", err)
+		http.Error(w, "Failed to check block status", http.StatusInternalServerError)
+		return nil, false
+	}
+	if blocked {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return nil, false
+	}
+	return match, true
+}
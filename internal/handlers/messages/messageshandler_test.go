@@ -0,0 +1,300 @@
+package messages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/events"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strings"
+	"testing"
+)
+
+type MockGetMatchRepo struct {
+	mock.Mock
+}
+
+func (m *MockGetMatchRepo) GetMatch(userID, matchID string) (*models.Match, error) {
+	args := m.Called(userID, matchID)
+	match := args.Get(0)
+	if match == nil {
+		return nil, args.Error(1)
+	}
+	return match.(*models.Match), args.Error(1)
+}
+
+type MockMessageRepo struct {
+	mock.Mock
+}
+
+func (m *MockMessageRepo) InsertMessage(message models.Message) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepo) ListMessages(matchID string, limit int, cursor string) (models.MessagePage, error) {
+	args := m.Called(matchID, limit, cursor)
+	return args.Get(0).(models.MessagePage), args.Error(1)
+}
+
+type MockBlockCheckRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockCheckRepo) IsBlocked(userID, otherUserID string) (bool, error) {
+	args := m.Called(userID, otherUserID)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(userID string, event events.Event) {
+	m.Called(userID, event)
+}
+
+var match = &models.Match{UserID: "user1", MatchID: "match1", MatchedUserID: "user2"}
+
+func newRequest(method, url string, body any, userID string) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, url, &buf)
+	req = mux.SetURLVars(req, map[string]string{"matchId": "match1"})
+	return req.WithContext(context.WithValue(req.Context(), "UserID", userID)) // Simulate JWTMiddleware setting UserID in context
+}
+
+func TestSendMessageHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             models.SendMessageRequest
+		userID           string
+		setupMocks       func(*MockGetMatchRepo, *MockBlockCheckRepo, *MockMessageRepo, *MockPublisher)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "matched user sends a message",
+			body:   models.SendMessageRequest{Body: "  hello  "},
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mr.On("InsertMessage", mock.MatchedBy(func(m models.Message) bool {
+					return m.MatchID == "match1" && m.SenderID == "user1" && m.Body == "hello" && m.MessageID != ""
+				})).Return(nil)
+				mp.On("Publish", "user2", mock.MatchedBy(func(e events.Event) bool { return e.Type == events.TypeNewMessage })).Return()
+				mp.On("Publish", "user1", mock.MatchedBy(func(e events.Event) bool { return e.Type == events.TypeNewMessage })).Return()
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:   "user outside the match is rejected",
+			body:   models.SendMessageRequest{Body: "hello"},
+			userID: "intruder",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {
+				mm.On("GetMatch", "intruder", "match1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Conversation not found",
+		},
+		{
+			name:   "blocked conversation is hidden",
+			body:   models.SendMessageRequest{Body: "hello"},
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(true, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Conversation not found",
+		},
+		{
+			name:             "empty message",
+			body:             models.SendMessageRequest{Body: "   "},
+			userID:           "user1",
+			setupMocks:       func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid message",
+		},
+		{
+			name:             "message too long",
+			body:             models.SendMessageRequest{Body: strings.Repeat("a", 2001)},
+			userID:           "user1",
+			setupMocks:       func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid message",
+		},
+		{
+			name:   "error inserting message",
+			body:   models.SendMessageRequest{Body: "hello"},
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mr.On("InsertMessage", mock.AnythingOfType("models.Message")).Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to send message",
+		},
+		{
+			name:   "unmatched before the message was stored",
+			body:   models.SendMessageRequest{Body: "hello"},
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo, mp *MockPublisher) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mr.On("InsertMessage", mock.AnythingOfType("models.Message")).Return(repository.ErrMatchNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Conversation not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMatchRepo := new(MockGetMatchRepo)
+			mockBlockRepo := new(MockBlockCheckRepo)
+			mockMessageRepo := new(MockMessageRepo)
+			mockPublisher := new(MockPublisher)
+			tt.setupMocks(mockMatchRepo, mockBlockRepo, mockMessageRepo, mockPublisher)
+
+			deps := MessagesDeps{
+				MatchRepo:   mockMatchRepo,
+				MessageRepo: mockMessageRepo,
+				BlockRepo:   mockBlockRepo,
+				Events:      mockPublisher,
+			}
+
+			handler := SendMessageHandler(&deps)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest("POST", "/matches/match1/messages", tt.body, tt.userID))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockMatchRepo.AssertExpectations(t)
+			mockBlockRepo.AssertExpectations(t)
+			mockMessageRepo.AssertExpectations(t)
+			mockPublisher.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListMessagesHandler(t *testing.T) {
+	page := models.MessagePage{
+		Messages: []models.Message{
+			{MatchID: "match1", MessageID: "2", SenderID: "user2", Body: "hi back"},
+			{MatchID: "match1", MessageID: "1", SenderID: "user1", Body: "hi"},
+		},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		userID           string
+		setupMocks       func(*MockGetMatchRepo, *MockBlockCheckRepo, *MockMessageRepo)
+		expectedStatus   int
+		expectedCount    int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "matched user reads the conversation",
+			query:  "?limit=2&cursor=abc",
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mr.On("ListMessages", "match1", 2, "abc").Return(page, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name:   "user outside the match is rejected",
+			userID: "intruder",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo) {
+				mm.On("GetMatch", "intruder", "match1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Conversation not found",
+		},
+		{
+			name:             "invalid limit",
+			query:            "?limit=abc",
+			userID:           "user1",
+			setupMocks:       func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid limit",
+		},
+		{
+			name:   "invalid cursor",
+			query:  "?cursor=garbage",
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo) {
+				mm.On("GetMatch", "user1", "match1").Return(match, nil)
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mr.On("ListMessages", "match1", defaultPageSize, "garbage").Return(models.MessagePage{}, repository.ErrInvalidCursor)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid cursor",
+		},
+		{
+			name:   "error fetching match",
+			userID: "user1",
+			setupMocks: func(mm *MockGetMatchRepo, mb *MockBlockCheckRepo, mr *MockMessageRepo) {
+				mm.On("GetMatch", "user1", "match1").Return(nil, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMatchRepo := new(MockGetMatchRepo)
+			mockBlockRepo := new(MockBlockCheckRepo)
+			mockMessageRepo := new(MockMessageRepo)
+			tt.setupMocks(mockMatchRepo, mockBlockRepo, mockMessageRepo)
+
+			deps := MessagesDeps{
+				MatchRepo:   mockMatchRepo,
+				MessageRepo: mockMessageRepo,
+				BlockRepo:   mockBlockRepo,
+			}
+
+			handler := ListMessagesHandler(&deps)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest("GET", "/matches/match1/messages"+tt.query, nil, tt.userID))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				var response models.MessagesResponse
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Len(t, response.Messages, tt.expectedCount)
+				assert.Equal(t, "next", response.NextCursor)
+			}
+
+			mockMatchRepo.AssertExpectations(t)
+			mockBlockRepo.AssertExpectations(t)
+			mockMessageRepo.AssertExpectations(t)
+		})
+	}
+}
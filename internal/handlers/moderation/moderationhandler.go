@@ -0,0 +1,185 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type ModerationDeps struct {
+	ModerationRepo repository.ModerationRepo
+}
+
+/*
+ListReportsHandler returns the moderation queue, oldest report first.
+The optional `status` query parameter selects open (the default) or resolved reports. Pages are sized by the optional
+`limit` query parameter and continued with `cursor`.
+Only reachable by admins, enforced by AdminMiddleware.
+*/
+func ListReportsHandler(deps *ModerationDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = models.ReportStatusOpen
+		}
+		if status != models.ReportStatusOpen && status != models.ReportStatusResolved {
+			http.Error(w, "Invalid status", http.StatusBadRequest)
+			return
+		}
+
+		limit := defaultPageSize
+		if l := r.URL.Query().Get("limit"); l != "" {
+			parsed, err := strconv.Atoi(l)
+			if err != nil || parsed < 1 || parsed > maxPageSize {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = parsed
+		}
+
+		page, err := deps.ModerationRepo.ListReports(status, limit, r.URL.Query().Get("cursor"))
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+			return
+		}
+
+		response := models.ReportsResponse{
+			Reports:    page.Reports,
+			NextCursor: page.NextCursor,
+		}
+		if response.Reports == nil {
+			response.Reports = []models.Report{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+ResolveReportHandler closes an open report as dismissed or actioned, recording which admin resolved it.
+A report can only be resolved once. Suspending the reported user is a separate action.
+Only reachable by admins, enforced by AdminMiddleware.
+*/
+func ResolveReportHandler(deps *ModerationDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rr models.ResolveReportRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateResolveReport(rr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid resolution", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		reportID := mux.Vars(r)["id"]
+
+		report, err := deps.ModerationRepo.GetReport(reportID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch report", http.StatusInternalServerError)
+			return
+		}
+		if report == nil {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+
+		resolvedAt := time.Now().Unix()
+		err = deps.ModerationRepo.ResolveReport(reportID, rr.Resolution, UserID, resolvedAt)
+		if errors.Is(err, repository.ErrReportNotOpen) {
+			http.Error(w, "Report already resolved", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to resolve report", http.StatusInternalServerError)
+			return
+		}
+
+		report.Status = models.ReportStatusResolved
+		report.Resolution = rr.Resolution
+		report.ResolvedBy = UserID
+		report.ResolvedAt = resolvedAt
+
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+SuspendUserHandler suspends a user account.
+The user is flagged as suspended first, so LoginHandler and JWTMiddleware refuse them from then on, even with a session
+created by a login that raced the suspension. All of their sessions are then revoked, which makes the refresh endpoint
+reject any refresh tokens they still hold. The outbox indexer then
+removes the user from the Elasticsearch "users" index, so discover stops showing them.
+Suspending a user that is already suspended repeats these steps, which also repairs a suspension that failed halfway.
+Only reachable by admins, enforced by AdminMiddleware.
+*/
+func SuspendUserHandler(deps *ModerationDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		suspendedUserID := mux.Vars(r)["id"]
+
+		if suspendedUserID == UserID {
+			http.Error(w, "Cannot suspend yourself", http.StatusBadRequest)
+			return
+		}
+
+		err := deps.ModerationRepo.SuspendUser(suspendedUserID, time.Now().Unix())
+		if errors.Is(err, repository.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to suspend user", http.StatusInternalServerError)
+			return
+		}
+
+		if err = deps.ModerationRepo.RevokeUserSessions(suspendedUserID); err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to revoke user sessions", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
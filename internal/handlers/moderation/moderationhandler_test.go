@@ -0,0 +1,290 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+)
+
+type MockModerationRepo struct {
+	mock.Mock
+}
+
+func (m *MockModerationRepo) GetReport(reportID string) (*models.Report, error) {
+	args := m.Called(reportID)
+	report := args.Get(0)
+	if report == nil {
+		return nil, args.Error(1)
+	}
+	return report.(*models.Report), args.Error(1)
+}
+
+func (m *MockModerationRepo) ListReports(status string, limit int, cursor string) (models.ReportPage, error) {
+	args := m.Called(status, limit, cursor)
+	return args.Get(0).(models.ReportPage), args.Error(1)
+}
+
+func (m *MockModerationRepo) ResolveReport(reportID, resolution, resolvedBy string, resolvedAt int64) error {
+	args := m.Called(reportID, resolution, resolvedBy, resolvedAt)
+	return args.Error(0)
+}
+
+func (m *MockModerationRepo) SuspendUser(userID string, suspendedAt int64) error {
+	args := m.Called(userID, suspendedAt)
+	return args.Error(0)
+}
+
+func (m *MockModerationRepo) RevokeUserSessions(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func newRequest(method, url string, body any, vars map[string]string) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, url, &buf)
+	req = mux.SetURLVars(req, vars)
+	return req.WithContext(context.WithValue(req.Context(), "UserID", "admin1")) // Simulate JWTMiddleware setting UserID in context
+}
+
+func TestListReportsHandler(t *testing.T) {
+	page := models.ReportPage{
+		Reports:    []models.Report{{ReportID: "report1", Status: models.ReportStatusOpen}},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		setupMocks       func(*MockModerationRepo)
+		expectedStatus   int
+		expectedCount    int
+		expectedErrorMsg string
+	}{
+		{
+			name:  "open reports by default",
+			query: "?limit=5&cursor=abc",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("ListReports", models.ReportStatusOpen, 5, "abc").Return(page, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:  "resolved reports",
+			query: "?status=resolved",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("ListReports", models.ReportStatusResolved, defaultPageSize, "").Return(page, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:             "invalid status",
+			query:            "?status=pending",
+			setupMocks:       func(mr *MockModerationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid status",
+		},
+		{
+			name:             "invalid limit",
+			query:            "?limit=0",
+			setupMocks:       func(mr *MockModerationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid limit",
+		},
+		{
+			name:  "invalid cursor",
+			query: "?cursor=garbage",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("ListReports", models.ReportStatusOpen, defaultPageSize, "garbage").Return(models.ReportPage{}, repository.ErrInvalidCursor)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid cursor",
+		},
+		{
+			name: "error listing reports",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("ListReports", models.ReportStatusOpen, defaultPageSize, "").Return(models.ReportPage{}, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to list reports",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockModerationRepo := new(MockModerationRepo)
+			tt.setupMocks(mockModerationRepo)
+
+			handler := ListReportsHandler(&ModerationDeps{ModerationRepo: mockModerationRepo})
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest("GET", "/admin/reports"+tt.query, nil, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				var response models.ReportsResponse
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Len(t, response.Reports, tt.expectedCount)
+				assert.Equal(t, "next", response.NextCursor)
+			}
+
+			mockModerationRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResolveReportHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             models.ResolveReportRequest
+		setupMocks       func(*MockModerationRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful resolve",
+			body: models.ResolveReportRequest{Resolution: "actioned"},
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("GetReport", "report1").Return(&models.Report{ReportID: "report1", Status: models.ReportStatusOpen}, nil)
+				mr.On("ResolveReport", "report1", "actioned", "admin1", mock.AnythingOfType("int64")).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "invalid resolution",
+			body:             models.ResolveReportRequest{Resolution: "ignored"},
+			setupMocks:       func(mr *MockModerationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid resolution",
+		},
+		{
+			name: "report not found",
+			body: models.ResolveReportRequest{Resolution: "dismissed"},
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("GetReport", "report1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Report not found",
+		},
+		{
+			name: "report already resolved",
+			body: models.ResolveReportRequest{Resolution: "dismissed"},
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("GetReport", "report1").Return(&models.Report{ReportID: "report1", Status: models.ReportStatusResolved}, nil)
+				mr.On("ResolveReport", "report1", "dismissed", "admin1", mock.AnythingOfType("int64")).Return(repository.ErrReportNotOpen)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Report already resolved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockModerationRepo := new(MockModerationRepo)
+			tt.setupMocks(mockModerationRepo)
+
+			handler := ResolveReportHandler(&ModerationDeps{ModerationRepo: mockModerationRepo})
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest("POST", "/admin/reports/report1/resolve", tt.body, map[string]string{"id": "report1"}))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				var response models.Report
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, models.ReportStatusResolved, response.Status)
+				assert.Equal(t, "admin1", response.ResolvedBy)
+			}
+
+			mockModerationRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSuspendUserHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		userID           string
+		setupMocks       func(*MockModerationRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:   "successful suspension",
+			userID: "user2",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("SuspendUser", "user2", mock.AnythingOfType("int64")).Return(nil)
+				mr.On("RevokeUserSessions", "user2").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:             "suspend yourself",
+			userID:           "admin1",
+			setupMocks:       func(mr *MockModerationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Cannot suspend yourself",
+		},
+		{
+			name:   "user not found",
+			userID: "ghost",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("SuspendUser", "ghost", mock.AnythingOfType("int64")).Return(repository.ErrUserNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name:   "error revoking sessions",
+			userID: "user2",
+			setupMocks: func(mr *MockModerationRepo) {
+				mr.On("SuspendUser", "user2", mock.AnythingOfType("int64")).Return(nil)
+				mr.On("RevokeUserSessions", "user2").Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to revoke user sessions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockModerationRepo := new(MockModerationRepo)
+			tt.setupMocks(mockModerationRepo)
+
+			deps := ModerationDeps{
+				ModerationRepo: mockModerationRepo,
+			}
+
+			handler := SuspendUserHandler(&deps)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newRequest("POST", "/admin/users/"+tt.userID+"/suspend", nil, map[string]string{"id": tt.userID}))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockModerationRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package photos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"io"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"quick-match/internal/services"
+	"time"
+)
+
+// maxPhotoBytes is the largest upload accepted, before processing.
+const maxPhotoBytes = 10 << 20
+
+type PhotosDeps struct {
+	UserRepo     repository.PhotoRepo
+	Blobs        repository.BlobStore
+	PhotoService services.PhotoService
+}
+
+/*
+UploadPhotoHandler adds a photo to the end of the authenticated user's profile.
+The request body is the image itself, up to maxPhotoBytes. Its type is sniffed from the content and must be JPEG or
+PNG. The image is re-encoded without its metadata and a thumbnail is generated, and both are written to the BlobStore.
+A profile holds at most models.MaxPhotos photos.
+The photo is then added to the user in DynamoDB, from where the outbox indexer reindexes them.
+The files are deleted again if the photo cannot be added to the user.
+*/
+func UploadPhotoHandler(deps *PhotosDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPhotoBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Photo too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil || len(data) == 0 {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, ok := fetchUser(w, deps, UserID)
+		if !ok {
+			return
+		}
+		if len(user.Photos) >= models.MaxPhotos {
+			http.Error(w, "Photo limit reached", http.StatusConflict)
+			return
+		}
+
+		processed, err := deps.PhotoService.Process(data)
+		if errors.Is(err, services.ErrUnsupportedPhoto) {
+			http.Error(w, "Unsupported photo type", http.StatusUnsupportedMediaType)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidPhoto) {
+			http.Error(w, "Invalid photo", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Printf("Photo Processing Failure: %v", err)
+			http.Error(w, "Failed to process photo", http.StatusInternalServerError)
+			return
+		}
+
+		photoID := uuid.New().String()
+		photo := models.Photo{
+			PhotoID:      photoID,
+			Key:          fmt.Sprintf("users/%s/photos/%s.%s", UserID, photoID, processed.Extension),
+			ThumbnailKey: fmt.Sprintf("users/%s/photos/%s_thumb.jpg", UserID, photoID),
+			UploadedAt:   time.Now().Unix(),
+		}
+		photo.URL = deps.Blobs.URL(photo.Key)
+		photo.ThumbnailURL = deps.Blobs.URL(photo.ThumbnailKey)
+
+		if err = deps.Blobs.Put(photo.Key, processed.Image, processed.ContentType); err != nil {
+			log.Printf("Blob Store Failure: %v", err)
+			http.Error(w, "Failed to store photo", http.StatusInternalServerError)
+			return
+		}
+		if err = deps.Blobs.Put(photo.ThumbnailKey, processed.Thumbnail, "image/jpeg"); err != nil {
+			log.Printf("Blob Store Failure: %v", err)
+			deleteBlobs(deps, photo)
+			http.Error(w, "Failed to store photo", http.StatusInternalServerError)
+			return
+		}
+
+		photos := append(append([]models.Photo{}, user.Photos...), photo)
+		if !savePhotos(w, deps, user, photos) {
+			deleteBlobs(deps, photo)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err = json.NewEncoder(w).Encode(photo); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+ReorderPhotosHandler changes the order of the authenticated user's photos. The first photo is the main photo.
+The request lists every photo ID of the profile exactly once, in the new order.
+*/
+func ReorderPhotosHandler(deps *PhotosDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var or models.PhotoOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&or); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidatePhotoOrder(or); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid photo order", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, ok := fetchUser(w, deps, UserID)
+		if !ok {
+			return
+		}
+
+		byID := make(map[string]models.Photo, len(user.Photos))
+		for _, p := range user.Photos {
+			byID[p.PhotoID] = p
+		}
+		if len(or.PhotoIDs) != len(user.Photos) {
+			http.Error(w, "Photo order must list every photo once", http.StatusBadRequest)
+			return
+		}
+		photos := make([]models.Photo, 0, len(or.PhotoIDs))
+		for _, id := range or.PhotoIDs {
+			p, found := byID[id]
+			if !found {
+				http.Error(w, "Photo order must list every photo once", http.StatusBadRequest)
+				return
+			}
+			photos = append(photos, p)
+		}
+
+		if !savePhotos(w, deps, user, photos) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(photos); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+DeletePhotoHandler removes one of the authenticated user's photos.
+The photo is removed from the user first, and then its files are deleted from the BlobStore. A failure to delete the
+files is only logged, since the photo is no longer referenced by the user.
+*/
+func DeletePhotoHandler(deps *PhotosDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		photoID := mux.Vars(r)["photoId"]
+
+		user, ok := fetchUser(w, deps, UserID)
+		if !ok {
+			return
+		}
+
+		var deleted *models.Photo
+		photos := make([]models.Photo, 0, len(user.Photos))
+		for i, p := range user.Photos {
+			if p.PhotoID == photoID {
+				deleted = &user.Photos[i]
+				continue
+			}
+			photos = append(photos, p)
+		}
+		if deleted == nil {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+
+		if !savePhotos(w, deps, user, photos) {
+			return
+		}
+		deleteBlobs(deps, *deleted)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// fetchUser reads the user from DynamoDB. It writes the error response and returns false if that fails.
+func fetchUser(w http.ResponseWriter, deps *PhotosDeps, userID string) (*models.UserDetails, bool) {
+	user, err := deps.UserRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Query Failure: %v", err)
+		http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+/*
+savePhotos writes the new photos of the user to DynamoDB, guarded by the version they were read at. It writes the
+error response and returns false if that fails.
+*/
+func savePhotos(w http.ResponseWriter, deps *PhotosDeps, user *models.UserDetails, photos []models.Photo) bool {
+	err := deps.UserRepo.SavePhotos(user.UserID, photos, user.PhotosVersion)
+	if errors.Is(err, repository.ErrPhotosChanged) {
+		http.Error(w, "Photos were changed by another request, please retry", http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		log.Printf("Query Failure: %v", err)
+		http.Error(w, "Failed to save photos", http.StatusInternalServerError)
+		return false
+	}
+
+	user.Photos = photos
+	user.PhotosVersion++
+	return true
+}
+
+// deleteBlobs removes a photo's files from the BlobStore. Failures are only logged, and leave an unreferenced file behind.
+func deleteBlobs(deps *PhotosDeps, photo models.Photo) {
+	for _, key := range []string{photo.Key, photo.ThumbnailKey} {
+		if err := deps.Blobs.Delete(key); err != nil {
+			log.Printf("Blob Store Failure: failed to delete %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"quick-match/internal/services"
+	"strings"
+	"testing"
+)
+
+type MockPhotoRepo struct {
+	mock.Mock
+}
+
+func (m *MockPhotoRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockPhotoRepo) SavePhotos(userID string, photos []models.Photo, version int) error {
+	args := m.Called(userID, photos, version)
+	return args.Error(0)
+}
+
+type MockBlobStore struct {
+	mock.Mock
+}
+
+func (m *MockBlobStore) Put(key string, data []byte, contentType string) error {
+	args := m.Called(key, data, contentType)
+	return args.Error(0)
+}
+
+func (m *MockBlobStore) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockBlobStore) URL(key string) string {
+	return "http://photos.test/" + key
+}
+
+type MockPhotoService struct {
+	mock.Mock
+}
+
+func (m *MockPhotoService) Process(data []byte) (*services.ProcessedPhoto, error) {
+	args := m.Called(data)
+	processed := args.Get(0)
+	if processed == nil {
+		return nil, args.Error(1)
+	}
+	return processed.(*services.ProcessedPhoto), args.Error(1)
+}
+
+var processedJPEG = &services.ProcessedPhoto{
+	Image:       []byte("image"),
+	ContentType: "image/jpeg",
+	Extension:   "jpg",
+	Thumbnail:   []byte("thumbnail"),
+}
+
+func photo(id string) models.Photo {
+	return models.Photo{
+		PhotoID:      id,
+		URL:          "http://photos.test/users/user1/photos/" + id + ".jpg",
+		ThumbnailURL: "http://photos.test/users/user1/photos/" + id + "_thumb.jpg",
+		Key:          "users/user1/photos/" + id + ".jpg",
+		ThumbnailKey: "users/user1/photos/" + id + "_thumb.jpg",
+	}
+}
+
+func userWithPhotos(ids ...string) *models.UserDetails {
+	user := &models.UserDetails{UserID: "user1", Name: "Jane", PhotosVersion: len(ids)}
+	for _, id := range ids {
+		user.Photos = append(user.Photos, photo(id))
+	}
+	return user
+}
+
+type photoMocks struct {
+	repo   *MockPhotoRepo
+	blobs  *MockBlobStore
+	images *MockPhotoService
+}
+
+func newPhotoMocks() photoMocks {
+	return photoMocks{
+		repo:   new(MockPhotoRepo),
+		blobs:  new(MockBlobStore),
+		images: new(MockPhotoService),
+	}
+}
+
+func (m photoMocks) deps() *PhotosDeps {
+	return &PhotosDeps{UserRepo: m.repo, Blobs: m.blobs, PhotoService: m.images}
+}
+
+func (m photoMocks) assertExpectations(t *testing.T) {
+	m.repo.AssertExpectations(t)
+	m.blobs.AssertExpectations(t)
+	m.images.AssertExpectations(t)
+}
+
+func TestUploadPhotoHandler(t *testing.T) {
+	isNewPhoto := func(photos []models.Photo) bool {
+		last := photos[len(photos)-1]
+		return strings.HasPrefix(last.Key, "users/user1/photos/") && strings.HasSuffix(last.Key, ".jpg") &&
+			last.URL == "http://photos.test/"+last.Key && last.ThumbnailURL == "http://photos.test/"+last.ThumbnailKey
+	}
+
+	tests := []struct {
+		name             string
+		body             []byte
+		setupMocks       func(photoMocks)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "photo uploaded",
+			body: []byte("jpeg bytes"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1"), nil)
+				m.images.On("Process", []byte("jpeg bytes")).Return(processedJPEG, nil)
+				m.blobs.On("Put", mock.MatchedBy(func(key string) bool { return !strings.HasSuffix(key, "_thumb.jpg") }), []byte("image"), "image/jpeg").Return(nil)
+				m.blobs.On("Put", mock.MatchedBy(func(key string) bool { return strings.HasSuffix(key, "_thumb.jpg") }), []byte("thumbnail"), "image/jpeg").Return(nil)
+				m.repo.On("SavePhotos", "user1", mock.MatchedBy(func(photos []models.Photo) bool {
+					return len(photos) == 2 && photos[0].PhotoID == "p1" && isNewPhoto(photos)
+				}), 1).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:             "empty body",
+			body:             []byte{},
+			setupMocks:       func(m photoMocks) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid request body",
+		},
+		{
+			name:             "photo too large",
+			body:             make([]byte, maxPhotoBytes+1),
+			setupMocks:       func(m photoMocks) {},
+			expectedStatus:   http.StatusRequestEntityTooLarge,
+			expectedErrorMsg: "Photo too large",
+		},
+		{
+			name: "photo limit reached",
+			body: []byte("jpeg bytes"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2", "p3", "p4", "p5", "p6"), nil)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Photo limit reached",
+		},
+		{
+			name: "unsupported type",
+			body: []byte("GIF89a"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos(), nil)
+				m.images.On("Process", []byte("GIF89a")).Return(nil, services.ErrUnsupportedPhoto)
+			},
+			expectedStatus:   http.StatusUnsupportedMediaType,
+			expectedErrorMsg: "Unsupported photo type",
+		},
+		{
+			name: "undecodable photo",
+			body: []byte("broken jpeg"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos(), nil)
+				m.images.On("Process", []byte("broken jpeg")).Return(nil, services.ErrInvalidPhoto)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid photo",
+		},
+		{
+			name: "blob store failure",
+			body: []byte("jpeg bytes"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos(), nil)
+				m.images.On("Process", []byte("jpeg bytes")).Return(processedJPEG, nil)
+				m.blobs.On("Put", mock.Anything, []byte("image"), "image/jpeg").Return(errors.New("disk full"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to store photo",
+		},
+		{
+			name: "concurrent change removes the stored files",
+			body: []byte("jpeg bytes"),
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos(), nil)
+				m.images.On("Process", []byte("jpeg bytes")).Return(processedJPEG, nil)
+				m.blobs.On("Put", mock.Anything, mock.Anything, "image/jpeg").Return(nil).Twice()
+				m.repo.On("SavePhotos", "user1", mock.Anything, 0).Return(repository.ErrPhotosChanged)
+				m.blobs.On("Delete", mock.Anything).Return(nil).Twice()
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Photos were changed by another request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPhotoMocks()
+			tt.setupMocks(m)
+
+			handler := UploadPhotoHandler(m.deps())
+
+			req, _ := http.NewRequest("POST", "/me/photos", bytes.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			} else {
+				assert.NotContains(t, rr.Body.String(), "thumbnailKey", "Storage keys must not be returned")
+				var uploaded models.Photo
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&uploaded))
+				assert.NotEmpty(t, uploaded.PhotoID)
+				assert.NotEmpty(t, uploaded.ThumbnailURL)
+			}
+
+			m.assertExpectations(t)
+		})
+	}
+}
+
+func TestReorderPhotosHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		setupMocks       func(photoMocks)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "photos reordered",
+			body: `{"photoIds":["p3","p1","p2"]}`,
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2", "p3"), nil)
+				m.repo.On("SavePhotos", "user1", []models.Photo{photo("p3"), photo("p1"), photo("p2")}, 3).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "duplicate photo ID",
+			body:             `{"photoIds":["p1","p1"]}`,
+			setupMocks:       func(m photoMocks) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid photo order",
+		},
+		{
+			name: "photo left out",
+			body: `{"photoIds":["p2","p1"]}`,
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2", "p3"), nil)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Photo order must list every photo once",
+		},
+		{
+			name: "unknown photo ID",
+			body: `{"photoIds":["p2","p1","p9"]}`,
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2", "p3"), nil)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Photo order must list every photo once",
+		},
+		{
+			name: "concurrent change",
+			body: `{"photoIds":["p2","p1"]}`,
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2"), nil)
+				m.repo.On("SavePhotos", "user1", mock.Anything, 2).Return(repository.ErrPhotosChanged)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Photos were changed by another request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPhotoMocks()
+			tt.setupMocks(m)
+
+			handler := ReorderPhotosHandler(m.deps())
+
+			req, _ := http.NewRequest("PUT", "/me/photos/order", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+
+			m.assertExpectations(t)
+		})
+	}
+}
+
+func TestDeletePhotoHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		photoID          string
+		setupMocks       func(photoMocks)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:    "photo deleted",
+			photoID: "p2",
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1", "p2"), nil)
+				m.repo.On("SavePhotos", "user1", []models.Photo{photo("p1")}, 2).Return(nil)
+				m.blobs.On("Delete", photo("p2").Key).Return(nil)
+				m.blobs.On("Delete", photo("p2").ThumbnailKey).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:    "last photo deleted",
+			photoID: "p1",
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1"), nil)
+				m.repo.On("SavePhotos", "user1", []models.Photo{}, 1).Return(nil)
+				m.blobs.On("Delete", mock.Anything).Return(nil).Twice()
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:    "blob delete failure is only logged",
+			photoID: "p1",
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1"), nil)
+				m.repo.On("SavePhotos", "user1", []models.Photo{}, 1).Return(nil)
+				m.blobs.On("Delete", mock.Anything).Return(errors.New("s3 error")).Twice()
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:    "photo not found",
+			photoID: "p9",
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1"), nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "Photo not found",
+		},
+		{
+			name:    "query failure",
+			photoID: "p1",
+			setupMocks: func(m photoMocks) {
+				m.repo.On("GetUserByID", "user1").Return(userWithPhotos("p1"), nil)
+				m.repo.On("SavePhotos", "user1", []models.Photo{}, 1).Return(errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to save photos",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newPhotoMocks()
+			tt.setupMocks(m)
+
+			handler := DeletePhotoHandler(m.deps())
+
+			req, _ := http.NewRequest("DELETE", "/me/photos/"+tt.photoID, nil)
+			req = mux.SetURLVars(req, map[string]string{"photoId": tt.photoID})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+
+			m.assertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+	"time"
+)
+
+// locationUpdateInterval is how long a user has to wait between two location changes.
+const locationUpdateInterval = 15 * time.Minute
+
+type LocationDeps struct {
+	UserRepo repository.LocationRepo
+}
+
+/*
+UpdateLocationHandler moves the authenticated user to a new location.
+Extracts the UserID from the request context and validates the coordinates.
+The location can change at most once per locationUpdateInterval. Earlier changes are refused with 429 Too Many
+Requests and a Retry-After header.
+The location is written to DynamoDB, from where the outbox indexer carries it to Elasticsearch for discover. Sending
+the current location again is not rate limited and changes nothing.
+*/
+func UpdateLocationHandler(deps *LocationDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var lr models.LocationUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&lr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateLocationUpdate(lr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid location", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := deps.UserRepo.GetUserByID(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		location := models.Userlocation{Latitude: *lr.Latitude, Longitude: *lr.Longitude}
+		if location != user.Userlocation {
+			now := time.Now()
+			err = deps.UserRepo.UpdateLocation(UserID, location, now.Unix(), locationUpdateInterval)
+			// A concurrent request can have moved the user since they were read, so the time comes from the refusal
+			var limited *repository.LocationRateLimitedError
+			if errors.As(err, &limited) {
+				retryAfter := time.Unix(limited.LocationUpdatedAt, 0).Add(locationUpdateInterval).Sub(now)
+				w.Header().Set("Retry-After", strconv.Itoa(max(1, int(retryAfter.Seconds()))))
+				http.Error(w, "Location was updated too recently", http.StatusTooManyRequests)
+				return
+			}
+			if errors.Is(err, repository.ErrUserNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to update location", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type MockLocationRepo struct {
+	mock.Mock
+}
+
+func (m *MockLocationRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockLocationRepo) UpdateLocation(userID string, location models.Userlocation, updatedAt int64, minInterval time.Duration) error {
+	args := m.Called(userID, location, updatedAt, minInterval)
+	return args.Error(0)
+}
+
+func TestUpdateLocationHandler(t *testing.T) {
+	munich := models.Userlocation{Latitude: 48.1351, Longitude: 11.582}
+
+	tests := []struct {
+		name               string
+		body               string
+		setupMocks         func(*MockLocationRepo)
+		expectedStatus     int
+		expectedErrorMsg   string
+		expectedRetryAfter int
+	}{
+		{
+			name: "location updated",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				ml.On("GetUserByID", "user1").Return(storedUser(), nil)
+				ml.On("UpdateLocation", "user1", munich, mock.AnythingOfType("int64"), locationUpdateInterval).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "current location is left as it is",
+			body: `{"latitude":52.52,"longitude":13.405}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				ml.On("GetUserByID", "user1").Return(storedUser(), nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "location changed too recently",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				user := storedUser()
+				user.LocationUpdatedAt = time.Now().Add(-5 * time.Minute).Unix()
+				ml.On("GetUserByID", "user1").Return(user, nil)
+				ml.On("UpdateLocation", "user1", munich, mock.AnythingOfType("int64"), locationUpdateInterval).
+					Return(&repository.LocationRateLimitedError{LocationUpdatedAt: user.LocationUpdatedAt})
+			},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedErrorMsg:   "Location was updated too recently",
+			expectedRetryAfter: 600,
+		},
+		{
+			name: "rate limited by a concurrent update",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				// Read before the other request moved the user, so only the refusal knows when that was
+				ml.On("GetUserByID", "user1").Return(storedUser(), nil)
+				ml.On("UpdateLocation", "user1", munich, mock.AnythingOfType("int64"), locationUpdateInterval).
+					Return(&repository.LocationRateLimitedError{LocationUpdatedAt: time.Now().Unix()})
+			},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedErrorMsg:   "Location was updated too recently",
+			expectedRetryAfter: int(locationUpdateInterval.Seconds()),
+		},
+		{
+			name: "user deleted before the update",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				ml.On("GetUserByID", "user1").Return(storedUser(), nil)
+				ml.On("UpdateLocation", "user1", munich, mock.AnythingOfType("int64"), locationUpdateInterval).Return(repository.ErrUserNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name:             "missing longitude",
+			body:             `{"latitude":48.1351}`,
+			setupMocks:       func(ml *MockLocationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid location",
+		},
+		{
+			name:             "latitude out of range",
+			body:             `{"latitude":91,"longitude":11.582}`,
+			setupMocks:       func(ml *MockLocationRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid location",
+		},
+		{
+			name: "user not found",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				ml.On("GetUserByID", "user1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "query failure on update",
+			body: `{"latitude":48.1351,"longitude":11.582}`,
+			setupMocks: func(ml *MockLocationRepo) {
+				ml.On("GetUserByID", "user1").Return(storedUser(), nil)
+				ml.On("UpdateLocation", "user1", munich, mock.AnythingOfType("int64"), locationUpdateInterval).Return(errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to update location",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLocationRepo := new(MockLocationRepo)
+			tt.setupMocks(mockLocationRepo)
+
+			handler := UpdateLocationHandler(&LocationDeps{UserRepo: mockLocationRepo})
+
+			req, _ := http.NewRequest("PUT", "/me/location", bytes.NewBufferString(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+			if tt.expectedRetryAfter > 0 {
+				retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+				assert.NoError(t, err)
+				assert.InDelta(t, tt.expectedRetryAfter, retryAfter, 5)
+			}
+
+			mockLocationRepo.AssertExpectations(t)
+		})
+	}
+}
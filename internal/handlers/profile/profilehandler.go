@@ -0,0 +1,166 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+)
+
+type ProfileDeps struct {
+	UserRepo  repository.ProfileRepo
+	BlockRepo repository.BlockCheckRepo
+}
+
+/*
+GetMeHandler returns the authenticated user's own profile.
+Extracts the UserID from the request context and reads the user from DynamoDB, the source of truth for profiles.
+Credentials are never returned.
+*/
+func GetMeHandler(deps *ProfileDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := deps.UserRepo.GetUserByID(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(user.Profile()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+UpdateMeHandler partially updates the authenticated user's profile.
+Extracts the UserID from the request context and validates every field that is set. Fields left out keep their value.
+The update is applied to the user stored in DynamoDB, and the resulting preferences are validated as a whole, so an
+update cannot leave the preferred age range inverted.
+The profile is written to DynamoDB, from where the outbox indexer reindexes it.
+*/
+func UpdateMeHandler(deps *ProfileDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var update models.ProfileUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateProfileUpdate(update); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid profile details", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := deps.UserRepo.GetUserByID(UserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		update.ApplyTo(user)
+		if err = validation.ValidatePreferences(user.Preferences); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid profile details", http.StatusBadRequest)
+			return
+		}
+
+		err = deps.UserRepo.UpdateProfile(*user)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to update user", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(user.Profile()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+/*
+GetUserHandler returns another user's public profile.
+Extracts the UserID from the request context and the requested user's ID from the URL.
+Only public fields are returned. Suspended users, and users who blocked or were blocked by the authenticated user,
+are reported as not found.
+*/
+func GetUserHandler(deps *ProfileDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		requestedUserID := mux.Vars(r)["id"]
+
+		if requestedUserID != UserID {
+			blocked, err := deps.BlockRepo.IsBlocked(UserID, requestedUserID)
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to check block status", http.StatusInternalServerError)
+				return
+			}
+			if blocked {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		user, err := deps.UserRepo.GetUserByID(requestedUserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if user == nil || user.Suspended {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err = json.NewEncoder(w).Encode(user.PublicProfile()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+	"time"
+)
+
+type MockProfileRepo struct {
+	mock.Mock
+}
+
+func (m *MockProfileRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockProfileRepo) UpdateProfile(user models.UserDetails) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+type MockBlockCheckRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockCheckRepo) IsBlocked(userID, otherUserID string) (bool, error) {
+	args := m.Called(userID, otherUserID)
+	return args.Bool(0), args.Error(1)
+}
+
+func storedUser() *models.UserDetails {
+	return &models.UserDetails{
+		UserID:         "user1",
+		Email:          "jane@example.com",
+		PasswordHashed: "hashed",
+		Name:           "Jane",
+		Gender:         "female",
+		Birthdate:      "1995-04-12",
+		Userlocation:   models.Userlocation{Latitude: 52.52, Longitude: 13.405},
+		Preferences:    models.Preferences{InterestedIn: []string{"male"}, PreferredMinAge: 25, PreferredMaxAge: 40},
+	}
+}
+
+func TestGetMeHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		setupMocks       func(*MockProfileRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "own profile",
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "user not found",
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "query failure",
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(nil, errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProfileRepo := new(MockProfileRepo)
+			tt.setupMocks(mockProfileRepo)
+
+			handler := GetMeHandler(&ProfileDeps{UserRepo: mockProfileRepo})
+
+			req, _ := http.NewRequest("GET", "/me", nil)
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			} else {
+				assert.NotContains(t, rr.Body.String(), "password", "Credentials must not be returned")
+				var profile models.UserProfile
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&profile))
+				assert.Equal(t, storedUser().Profile(), profile)
+			}
+
+			mockProfileRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateMeHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		setupMocks       func(*MockProfileRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "partial update keeps the other fields",
+			body: `{"name":"Jane Roe"}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				updated := *storedUser()
+				updated.Name = "Jane Roe"
+
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+				mp.On("UpdateProfile", updated).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "preferences are cleared with empty values",
+			body: `{"interestedIn":[],"preferredMinAge":0,"preferredMaxAge":0}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				updated := *storedUser()
+				updated.Preferences = models.Preferences{}
+
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+				mp.On("UpdateProfile", updated).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "bio and interests are set",
+			body: `{"bio":"Weekend climber","interests":["climbing","coffee"]}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				updated := *storedUser()
+				updated.Bio = "Weekend climber"
+				updated.Interests = []string{"climbing", "coffee"}
+
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+				mp.On("UpdateProfile", updated).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "interest outside the taxonomy",
+			body:             `{"interests":["climbing","stamp-collecting"]}`,
+			setupMocks:       func(mp *MockProfileRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid profile details",
+		},
+		{
+			name:             "duplicate interests",
+			body:             `{"interests":["coffee","coffee"]}`,
+			setupMocks:       func(mp *MockProfileRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid profile details",
+		},
+		{
+			name:             "empty name",
+			body:             `{"name":""}`,
+			setupMocks:       func(mp *MockProfileRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid profile details",
+		},
+		{
+			name:             "unknown gender",
+			body:             `{"gender":"robot"}`,
+			setupMocks:       func(mp *MockProfileRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid profile details",
+		},
+		{
+			name: "update would invert the stored age range",
+			body: `{"preferredMinAge":45}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid profile details",
+		},
+		{
+			name:             "invalid request body",
+			body:             `{"name":42}`,
+			setupMocks:       func(mp *MockProfileRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid request body",
+		},
+		{
+			name: "user deleted in the meantime",
+			body: `{"name":"Jane Roe"}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+				mp.On("UpdateProfile", mock.Anything).Return(repository.ErrUserNotFound)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "query failure on update",
+			body: `{"name":"Jane Roe"}`,
+			setupMocks: func(mp *MockProfileRepo) {
+				mp.On("GetUserByID", "user1").Return(storedUser(), nil)
+				mp.On("UpdateProfile", mock.Anything).Return(errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to update user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProfileRepo := new(MockProfileRepo)
+			tt.setupMocks(mockProfileRepo)
+
+			handler := UpdateMeHandler(&ProfileDeps{UserRepo: mockProfileRepo})
+
+			req, _ := http.NewRequest("PATCH", "/me", bytes.NewBufferString(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			}
+
+			mockProfileRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetUserHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		requestedUserID  string
+		setupMocks       func(*MockProfileRepo, *MockBlockCheckRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:            "public profile",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mp.On("GetUserByID", "user2").Return(&models.UserDetails{
+					UserID: "user2", Email: "john@example.com", Name: "John", Gender: "male",
+					Birthdate:    time.Now().AddDate(-31, 0, -1).Format(models.BirthdateLayout),
+					Userlocation: models.Userlocation{Latitude: 52.52, Longitude: 13.405},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "blocked user",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(true, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name:            "suspended user",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mp.On("GetUserByID", "user2").Return(&models.UserDetails{UserID: "user2", Suspended: true}, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name:            "user not found",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mp.On("GetUserByID", "user2").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name:            "error checking block status",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to check block status",
+		},
+		{
+			name:            "query failure",
+			requestedUserID: "user2",
+			setupMocks: func(mp *MockProfileRepo, mb *MockBlockCheckRepo) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				mp.On("GetUserByID", "user2").Return(nil, errors.New("database error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to fetch user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProfileRepo := new(MockProfileRepo)
+			mockBlockRepo := new(MockBlockCheckRepo)
+			tt.setupMocks(mockProfileRepo, mockBlockRepo)
+
+			handler := GetUserHandler(&ProfileDeps{UserRepo: mockProfileRepo, BlockRepo: mockBlockRepo})
+
+			req, _ := http.NewRequest("GET", "/users/"+tt.requestedUserID, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.requestedUserID})
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+			} else {
+				body := rr.Body.String()
+				assert.NotContains(t, body, "email", "Only public fields may be returned")
+				assert.NotContains(t, body, "latitude", "Only public fields may be returned")
+				assert.JSONEq(t, `{"UserID":"user2","name":"John","gender":"male","age":31}`, body)
+			}
+
+			mockProfileRepo.AssertExpectations(t)
+			mockBlockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package refresh
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/authentication"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"time"
+)
+
+type RefreshDeps struct {
+	SessionRepo  repository.RefreshSessionRepo
+	TokenService authentication.TokenService
+}
+
+/*
+RefreshHandler exchanges a refresh token for a new access + refresh token pair.
+The session referenced by the refresh token must exist, belong to an unexpired and unrevoked session, and the token's
+hash must match the one currently stored for it.
+Presenting an already rotated refresh token is treated as token theft and revokes the whole session.
+The refresh token is rotated on every use, so each one can only be exchanged once.
+*/
+func RefreshHandler(deps *RefreshDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rr models.RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+
+		if err := validation.ValidateRefresh(rr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid refresh token", http.StatusBadRequest)
+			return
+		}
+
+		sessionID, hash, err := authentication.ParseRefreshToken(rr.RefreshToken)
+		if err != nil {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		session, err := deps.SessionRepo.GetSession(sessionID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if session == nil || session.Revoked || session.ExpiresAt <= time.Now().Unix() {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(session.RefreshTokenHash), []byte(hash)) != 1 {
+			log.Printf("Refresh token reuse detected for session %s, revoking", sessionID)
+			if err = deps.SessionRepo.RevokeSession(sessionID); err != nil {
+				log.Printf("Query Failure: %v", err)
+			}
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		refreshToken, err := deps.TokenService.GenerateRefreshToken(sessionID)
+		if err != nil {
+			log.Printf("Token Generation Failure: %v", err)
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		err = deps.SessionRepo.RotateSession(sessionID, hash, refreshToken.Hash, refreshToken.ExpiresAt.Unix())
+		if errors.Is(err, repository.ErrSessionRotated) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		token, err := deps.TokenService.GenerateToken(session.UserID, sessionID)
+		if err != nil {
+			log.Printf("Token Generation Failure: %v", err)
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		response := models.LoginResponse{
+			Token:        token,
+			RefreshToken: refreshToken.Token,
+			ExpiresIn:    int64(authentication.AccessTokenTTL.Seconds()),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
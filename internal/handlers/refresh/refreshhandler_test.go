@@ -0,0 +1,181 @@
+package refresh
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/middleware/authentication"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+	"time"
+)
+
+type MockRefreshSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockRefreshSessionRepo) GetSession(sessionID string) (*models.Session, error) {
+	args := m.Called(sessionID)
+	session := args.Get(0)
+	if session == nil {
+		return nil, args.Error(1)
+	}
+	return session.(*models.Session), args.Error(1)
+}
+
+func (m *MockRefreshSessionRepo) RevokeSession(sessionID string) error {
+	args := m.Called(sessionID)
+	return args.Error(0)
+}
+
+func (m *MockRefreshSessionRepo) RotateSession(sessionID, oldHash, newHash string, expiresAt int64) error {
+	args := m.Called(sessionID, oldHash, newHash, expiresAt)
+	return args.Error(0)
+}
+
+type MockTokenService struct {
+	mock.Mock
+}
+
+func (m *MockTokenService) GenerateToken(userID, sessionID string) (string, error) {
+	args := m.Called(userID, sessionID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockTokenService) GenerateRefreshToken(sessionID string) (authentication.RefreshToken, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).(authentication.RefreshToken), args.Error(1)
+}
+
+func TestRefreshHandler(t *testing.T) {
+	const presented = "session1.secret"
+	presentedHash := authentication.HashRefreshSecret("secret")
+	expiresAt := time.Now().Add(time.Hour)
+	activeSession := &models.Session{SessionID: "session1", UserID: "user1", RefreshTokenHash: presentedHash, ExpiresAt: expiresAt.Unix()}
+	newToken := authentication.RefreshToken{Token: "session1.next", Hash: "nexthash", ExpiresAt: expiresAt}
+
+	tests := []struct {
+		name             string
+		body             models.RefreshRequest
+		setupMocks       func(*MockRefreshSessionRepo, *MockTokenService)
+		expectedStatus   int
+		expectedResponse *models.LoginResponse
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful rotation",
+			body: models.RefreshRequest{RefreshToken: presented},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(activeSession, nil)
+				mt.On("GenerateRefreshToken", "session1").Return(newToken, nil)
+				ms.On("RotateSession", "session1", presentedHash, "nexthash", expiresAt.Unix()).Return(nil)
+				mt.On("GenerateToken", "user1", "session1").Return("access123", nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: &models.LoginResponse{Token: "access123", RefreshToken: "session1.next"},
+		},
+		{
+			name:             "missing refresh token",
+			body:             models.RefreshRequest{},
+			setupMocks:       func(ms *MockRefreshSessionRepo, mt *MockTokenService) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name:             "malformed refresh token",
+			body:             models.RefreshRequest{RefreshToken: "nodot"},
+			setupMocks:       func(ms *MockRefreshSessionRepo, mt *MockTokenService) {},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name: "unknown session",
+			body: models.RefreshRequest{RefreshToken: presented},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name: "revoked session",
+			body: models.RefreshRequest{RefreshToken: presented},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(&models.Session{SessionID: "session1", UserID: "user1", RefreshTokenHash: presentedHash, ExpiresAt: expiresAt.Unix(), Revoked: true}, nil)
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name: "reused refresh token revokes session",
+			body: models.RefreshRequest{RefreshToken: "session1.oldsecret"},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(activeSession, nil)
+				ms.On("RevokeSession", "session1").Return(nil)
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name: "concurrent rotation loses",
+			body: models.RefreshRequest{RefreshToken: presented},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(activeSession, nil)
+				mt.On("GenerateRefreshToken", "session1").Return(newToken, nil)
+				ms.On("RotateSession", "session1", presentedHash, "nexthash", expiresAt.Unix()).Return(repository.ErrSessionRotated)
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Invalid refresh token",
+		},
+		{
+			name: "session lookup failure",
+			body: models.RefreshRequest{RefreshToken: presented},
+			setupMocks: func(ms *MockRefreshSessionRepo, mt *MockTokenService) {
+				ms.On("GetSession", "session1").Return(nil, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSessionRepo := new(MockRefreshSessionRepo)
+			mockTokenService := new(MockTokenService)
+			tt.setupMocks(mockSessionRepo, mockTokenService)
+
+			deps := RefreshDeps{
+				SessionRepo:  mockSessionRepo,
+				TokenService: mockTokenService,
+			}
+
+			handler := RefreshHandler(&deps)
+
+			bodyBytes, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest("POST", "/token/refresh", bytes.NewBuffer(bodyBytes))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedResponse == nil {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				var response models.LoginResponse
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResponse.Token, response.Token)
+				assert.Equal(t, tt.expectedResponse.RefreshToken, response.RefreshToken)
+			}
+
+			mockSessionRepo.AssertExpectations(t)
+			mockTokenService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"strings"
+	"time"
+)
+
+type ReportDeps struct {
+	ReportRepo repository.ReportRepo
+}
+
+/*
+ReportUserHandler lets the authenticated user report another user's profile.
+Extracts the UserID from the request context and validates the reason category and the optional free text.
+The reported user must exist. The report is stored as open, where it waits in the moderation queue until an admin
+resolves it.
+*/
+func ReportUserHandler(deps *ReportDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rr models.ReportRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rr.Details = strings.TrimSpace(rr.Details)
+
+		if err := validation.ValidateReport(rr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+
+		// Extract UserID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		if rr.ReportedUserID == UserID {
+			http.Error(w, "Cannot report yourself", http.StatusBadRequest)
+			return
+		}
+
+		reported, err := deps.ReportRepo.GetUserByID(rr.ReportedUserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to fetch user", http.StatusInternalServerError)
+			return
+		}
+		if reported == nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		report := models.Report{
+			ReportID:       uuid.New().String(),
+			ReporterID:     UserID,
+			ReportedUserID: rr.ReportedUserID,
+			Category:       rr.Category,
+			Details:        rr.Details,
+			Status:         models.ReportStatusOpen,
+			CreatedAt:      time.Now().Unix(),
+		}
+		if err = deps.ReportRepo.InsertReport(report); err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to create report", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err = json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
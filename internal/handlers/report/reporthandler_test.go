@@ -0,0 +1,123 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"strings"
+	"testing"
+)
+
+type MockReportRepo struct {
+	mock.Mock
+}
+
+func (m *MockReportRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockReportRepo) InsertReport(report models.Report) error {
+	args := m.Called(report)
+	return args.Error(0)
+}
+
+func TestReportUserHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             models.ReportRequest
+		setupMocks       func(*MockReportRepo)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful report",
+			body: models.ReportRequest{ReportedUserID: "user2", Category: "spam", Details: "  sends links  "},
+			setupMocks: func(mr *MockReportRepo) {
+				mr.On("GetUserByID", "user2").Return(&models.UserDetails{UserID: "user2"}, nil)
+				mr.On("InsertReport", mock.MatchedBy(func(r models.Report) bool {
+					return r.ReportID != "" && r.ReporterID == "user1" && r.ReportedUserID == "user2" &&
+						r.Category == "spam" && r.Details == "sends links" && r.Status == models.ReportStatusOpen
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:             "unknown category",
+			body:             models.ReportRequest{ReportedUserID: "user2", Category: "boring"},
+			setupMocks:       func(mr *MockReportRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid report",
+		},
+		{
+			name:             "details too long",
+			body:             models.ReportRequest{ReportedUserID: "user2", Category: "other", Details: strings.Repeat("a", 1001)},
+			setupMocks:       func(mr *MockReportRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid report",
+		},
+		{
+			name:             "report yourself",
+			body:             models.ReportRequest{ReportedUserID: "user1", Category: "spam"},
+			setupMocks:       func(mr *MockReportRepo) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Cannot report yourself",
+		},
+		{
+			name: "reported user does not exist",
+			body: models.ReportRequest{ReportedUserID: "ghost", Category: "fake_profile"},
+			setupMocks: func(mr *MockReportRepo) {
+				mr.On("GetUserByID", "ghost").Return(nil, nil)
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedErrorMsg: "User not found",
+		},
+		{
+			name: "error storing report",
+			body: models.ReportRequest{ReportedUserID: "user2", Category: "harassment"},
+			setupMocks: func(mr *MockReportRepo) {
+				mr.On("GetUserByID", "user2").Return(&models.UserDetails{UserID: "user2"}, nil)
+				mr.On("InsertReport", mock.AnythingOfType("models.Report")).Return(errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to create report",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockReportRepo := new(MockReportRepo)
+			tt.setupMocks(mockReportRepo)
+
+			deps := ReportDeps{
+				ReportRepo: mockReportRepo,
+			}
+
+			handler := ReportUserHandler(&deps)
+
+			bodyBytes, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest("POST", "/reports", bytes.NewBuffer(bodyBytes))
+			req = req.WithContext(context.WithValue(req.Context(), "UserID", "user1")) // Simulate JWTMiddleware setting UserID in context
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			}
+
+			mockReportRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package signup
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"net/http"
+	"quick-match/internal/middleware/validation"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"quick-match/internal/services"
+	"strings"
+)
+
+type SignupDeps struct {
+	UserRepo        repository.SignupUserRepo
+	PasswordService services.PasswordService
+}
+
+/*
+SignupHandler registers a new user from the details they provide.
+Validates the request with the validation package, normalising the email to lower case first. Users must be at least
+models.MinimumAge years old.
+Rejects the request if the email is already registered, either up front or, if another signup claimed the same email
+in the meantime, when the user is inserted.
+Hashes the password using the PasswordService, so only the hash is ever stored.
+Inserts the new user into DynamoDB, from where the outbox indexer indexes them, and returns their profile.
+*/
+func SignupHandler(deps *SignupDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sr models.SignupRequest
+		if err := json.NewDecoder(r.Body).Decode(&sr); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		sr.Email = strings.ToLower(strings.TrimSpace(sr.Email))
+		sr.Name = strings.TrimSpace(sr.Name)
+
+		if err := validation.ValidateSignup(sr); err != nil {
+			log.Printf("Validation Failure: %v", err)
+			http.Error(w, "Invalid signup details", http.StatusBadRequest)
+			return
+		}
+
+		existing, err := deps.UserRepo.GetUserByEmail(sr.Email)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+		if existing != nil {
+			http.Error(w, "Email already registered", http.StatusConflict)
+			return
+		}
+
+		hashedPassword, err := deps.PasswordService.GenerateHashedPassword(sr.Password)
+		if err != nil {
+			log.Printf("Password Hashing Failure: %v", err)
+			http.Error(w, "Server error", http.StatusInternalServerError)
+			return
+		}
+
+		newUser := models.UserDetails{
+			UserID:         uuid.New().String(),
+			Email:          sr.Email,
+			PasswordHashed: hashedPassword,
+			Name:           sr.Name,
+			Gender:         sr.Gender,
+			Birthdate:      sr.Birthdate,
+			Userlocation: models.Userlocation{
+				Latitude:  *sr.Latitude,
+				Longitude: *sr.Longitude,
+			},
+			Preferences: sr.Preferences,
+		}
+
+		err = deps.UserRepo.InsertUser(newUser)
+		if errors.Is(err, repository.ErrEmailTaken) {
+			http.Error(w, "Email already registered", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to insert user", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err = json.NewEncoder(w).Encode(newUser.Profile()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package signup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+	"time"
+)
+
+type MockSignupUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockSignupUserRepo) GetUserByEmail(email string) (*models.UserDetails, error) {
+	args := m.Called(email)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+func (m *MockSignupUserRepo) InsertUser(user models.UserDetails) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+type MockPasswordService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordService) CompareHashAndPassword(hashedPassword, password string) error {
+	args := m.Called(hashedPassword, password)
+	return args.Error(0)
+}
+
+func (m *MockPasswordService) GenerateHashedPassword(password string) (string, error) {
+	args := m.Called(password)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPasswordService) NeedsRehash(hashedPassword string) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func TestSignupHandler(t *testing.T) {
+	validBody := models.SignupRequest{
+		Email:     "New.User@Example.com",
+		Password:  "correcthorse",
+		Name:      "New User",
+		Gender:    "female",
+		Birthdate: "1995-04-12",
+		Latitude:  float(52.52),
+		Longitude: float(13.405),
+	}
+
+	withPreferences := validBody
+	withPreferences.Preferences = models.Preferences{InterestedIn: []string{"male", "nonbinary"}, PreferredMinAge: 25, PreferredMaxAge: 35}
+
+	invalidInterest := validBody
+	invalidInterest.Preferences = models.Preferences{InterestedIn: []string{"robots"}}
+
+	underage := validBody
+	underage.Birthdate = time.Now().AddDate(-models.MinimumAge, 0, 1).Format(models.BirthdateLayout)
+
+	invertedAgeRange := validBody
+	invertedAgeRange.Preferences = models.Preferences{PreferredMinAge: 40, PreferredMaxAge: 30}
+
+	tests := []struct {
+		name             string
+		body             models.SignupRequest
+		setupMocks       func(*MockSignupUserRepo, *MockPasswordService)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name: "successful signup",
+			body: validBody,
+			setupMocks: func(mr *MockSignupUserRepo, mp *MockPasswordService) {
+				mr.On("GetUserByEmail", "new.user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", "correcthorse").Return("hashed", nil)
+				mr.On("InsertUser", mock.MatchedBy(func(u models.UserDetails) bool {
+					return u.Email == "new.user@example.com" && u.PasswordHashed == "hashed" && u.Password == "" && u.Birthdate == "1995-04-12"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "signup with preferences",
+			body: withPreferences,
+			setupMocks: func(mr *MockSignupUserRepo, mp *MockPasswordService) {
+				mr.On("GetUserByEmail", "new.user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", "correcthorse").Return("hashed", nil)
+				mr.On("InsertUser", mock.MatchedBy(func(u models.UserDetails) bool {
+					return len(u.InterestedIn) == 2 && u.PreferredMinAge == 25 && u.PreferredMaxAge == 35
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:             "unknown gender in preferences",
+			body:             invalidInterest,
+			setupMocks:       func(mr *MockSignupUserRepo, mp *MockPasswordService) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid signup details",
+		},
+		{
+			name:             "younger than the minimum age",
+			body:             underage,
+			setupMocks:       func(mr *MockSignupUserRepo, mp *MockPasswordService) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid signup details",
+		},
+		{
+			name:             "preferred age range inverted",
+			body:             invertedAgeRange,
+			setupMocks:       func(mr *MockSignupUserRepo, mp *MockPasswordService) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid signup details",
+		},
+		{
+			name:             "validation failure",
+			body:             models.SignupRequest{Email: "not-an-email", Password: "short"},
+			setupMocks:       func(mr *MockSignupUserRepo, mp *MockPasswordService) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedErrorMsg: "Invalid signup details",
+		},
+		{
+			name: "duplicate email",
+			body: validBody,
+			setupMocks: func(mr *MockSignupUserRepo, mp *MockPasswordService) {
+				mr.On("GetUserByEmail", "new.user@example.com").Return(&models.UserDetails{UserID: "existing"}, nil)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Email already registered",
+		},
+		{
+			name: "email claimed by a concurrent signup",
+			body: validBody,
+			setupMocks: func(mr *MockSignupUserRepo, mp *MockPasswordService) {
+				mr.On("GetUserByEmail", "new.user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", "correcthorse").Return("hashed", nil)
+				mr.On("InsertUser", mock.AnythingOfType("models.UserDetails")).Return(repository.ErrEmailTaken)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedErrorMsg: "Email already registered",
+		},
+		{
+			name: "failure inserting user",
+			body: validBody,
+			setupMocks: func(mr *MockSignupUserRepo, mp *MockPasswordService) {
+				mr.On("GetUserByEmail", "new.user@example.com").Return(nil, nil)
+				mp.On("GenerateHashedPassword", "correcthorse").Return("hashed", nil)
+				mr.On("InsertUser", mock.AnythingOfType("models.UserDetails")).Return(errors.New("insert user error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedErrorMsg: "Failed to insert user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(MockSignupUserRepo)
+			mockPasswordService := new(MockPasswordService)
+			tt.setupMocks(mockUserRepo, mockPasswordService)
+
+			deps := SignupDeps{
+				UserRepo:        mockUserRepo,
+				PasswordService: mockPasswordService,
+			}
+
+			handler := SignupHandler(&deps)
+
+			bodyBytes, _ := json.Marshal(tt.body)
+			req, _ := http.NewRequest("POST", "/user/register", bytes.NewBuffer(bodyBytes))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg, "Error message does not match")
+			} else {
+				var response models.UserProfile
+				err := json.NewDecoder(rr.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, "new.user@example.com", response.Email)
+				assert.NotEmpty(t, response.UserID)
+				assert.NotContains(t, rr.Body.String(), "password")
+			}
+
+			mockUserRepo.AssertExpectations(t)
+			mockPasswordService.AssertExpectations(t)
+		})
+	}
+}
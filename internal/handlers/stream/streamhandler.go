@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"quick-match/internal/events"
+	"quick-match/internal/repository"
+	"time"
+)
+
+const heartbeatInterval = 25 * time.Second
+
+type StreamDeps struct {
+	Broker      events.Subscriber
+	SessionRepo repository.GetSessionRepo
+	// Heartbeat overrides heartbeatInterval, used by tests.
+	Heartbeat time.Duration
+}
+
+/*
+EventStreamHandler streams real-time events to the authenticated user as Server-Sent Events.
+Subscribes to the broker with the UserID from the request context and writes every event as an SSE frame named after
+its type ("match_created", "new_message", "unmatched") with a JSON payload.
+A comment line is sent on every heartbeat to keep proxies from closing the idle connection. The session is checked
+again on each heartbeat, so logging out or revoking the session also ends the stream.
+*/
+func EventStreamHandler(deps *StreamDeps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extract UserID and SessionID from context, set by JWTMiddleware
+		UserID, ok := r.Context().Value("UserID").(string)
+		if !ok {
+			log.Println("Could not extract UserID from token")
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		SessionID, _ := r.Context().Value("SessionID").(string)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// The server's WriteTimeout would otherwise cut the stream short
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("Could not clear write deadline for event stream: %v", err)
+		}
+
+		ch, unsubscribe := deps.Broker.Subscribe(UserID)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		interval := deps.Heartbeat
+		if interval == 0 {
+			interval = heartbeatInterval
+		}
+		heartbeat := time.NewTicker(interval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, open := <-ch:
+				if !open {
+					return
+				}
+				data, err := json.Marshal(event.Data)
+				if err != nil {
+					log.Printf("Failed to encode %s event: %v", event.Type, err)
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				flusher.Flush()
+			case <-heartbeat.C:
+				session, err := deps.SessionRepo.GetSession(SessionID)
+				if err != nil {
+					log.Printf("Query Failure: %v", err)
+				} else if session == nil || session.Revoked || session.ExpiresAt <= time.Now().Unix() {
+					return
+				}
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/events"
+	"quick-match/internal/models"
+	"testing"
+	"time"
+)
+
+type MockSubscriber struct {
+	mock.Mock
+}
+
+func (m *MockSubscriber) Subscribe(userID string) (<-chan events.Event, func()) {
+	args := m.Called(userID)
+	return args.Get(0).(chan events.Event), args.Get(1).(func())
+}
+
+type MockGetSessionRepo struct {
+	mock.Mock
+}
+
+func (m *MockGetSessionRepo) GetSession(sessionID string) (*models.Session, error) {
+	args := m.Called(sessionID)
+	session := args.Get(0)
+	if session == nil {
+		return nil, args.Error(1)
+	}
+	return session.(*models.Session), args.Error(1)
+}
+
+func TestEventStreamHandler(t *testing.T) {
+	activeSession := &models.Session{SessionID: "session1", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name          string
+		setupMocks    func(*MockSubscriber, *MockGetSessionRepo, chan events.Event)
+		drive         func(chan events.Event, context.CancelFunc)
+		expectedBody  []string
+		unexpectedStr string
+	}{
+		{
+			name: "streams published events",
+			setupMocks: func(ms *MockSubscriber, mr *MockGetSessionRepo, ch chan events.Event) {
+				ms.On("Subscribe", "user1").Return(ch, func() {})
+				mr.On("GetSession", "session1").Return(activeSession, nil).Maybe()
+			},
+			drive: func(ch chan events.Event, cancel context.CancelFunc) {
+				ch <- events.Event{Type: events.TypeMatchCreated, Data: events.MatchData{MatchID: "match1", UserID: "user2"}}
+				ch <- events.Event{Type: events.TypeNewMessage, Data: models.Message{MatchID: "match1", Body: "hi"}}
+				close(ch)
+			},
+			expectedBody: []string{
+				": connected",
+				"event: match_created\ndata: {\"matchId\":\"match1\",\"UserID\":\"user2\"}\n\n",
+				"event: new_message\ndata: {\"matchId\":\"match1\"",
+			},
+		},
+		{
+			name: "client disconnect ends the stream",
+			setupMocks: func(ms *MockSubscriber, mr *MockGetSessionRepo, ch chan events.Event) {
+				ms.On("Subscribe", "user1").Return(ch, func() {})
+				mr.On("GetSession", "session1").Return(activeSession, nil).Maybe()
+			},
+			drive: func(ch chan events.Event, cancel context.CancelFunc) {
+				cancel()
+			},
+			expectedBody: []string{": connected"},
+		},
+		{
+			name: "revoked session ends the stream on heartbeat",
+			setupMocks: func(ms *MockSubscriber, mr *MockGetSessionRepo, ch chan events.Event) {
+				ms.On("Subscribe", "user1").Return(ch, func() {})
+				mr.On("GetSession", "session1").Return(&models.Session{SessionID: "session1", UserID: "user1", Revoked: true}, nil)
+			},
+			drive:         func(ch chan events.Event, cancel context.CancelFunc) {},
+			expectedBody:  []string{": connected"},
+			unexpectedStr: ": ping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan events.Event)
+			mockSubscriber := new(MockSubscriber)
+			mockSessionRepo := new(MockGetSessionRepo)
+			tt.setupMocks(mockSubscriber, mockSessionRepo, ch)
+
+			deps := StreamDeps{
+				Broker:      mockSubscriber,
+				SessionRepo: mockSessionRepo,
+				Heartbeat:   10 * time.Millisecond,
+			}
+
+			handler := EventStreamHandler(&deps)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req, _ := http.NewRequest("GET", "/events", nil)
+			ctx = context.WithValue(ctx, "UserID", "user1")
+			ctx = context.WithValue(ctx, "SessionID", "session1")
+			req = req.WithContext(ctx)
+			rr := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				handler.ServeHTTP(rr, req)
+				close(done)
+			}()
+			tt.drive(ch, cancel)
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("stream did not end")
+			}
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, rr.Body.String(), expected)
+			}
+			if tt.unexpectedStr != "" {
+				assert.NotContains(t, rr.Body.String(), tt.unexpectedStr)
+			}
+
+			mockSubscriber.AssertExpectations(t)
+			mockSessionRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -2,26 +2,38 @@ package swipe
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"log"
 	"net/http"
+	"quick-match/internal/events"
 	"quick-match/internal/models"
 	"quick-match/internal/repository"
+	"time"
 )
 
 type SwipeDeps struct {
 	SwipeRepo repository.SwipeRepo
+	BlockRepo repository.BlockCheckRepo
+	SwipeSets repository.SwipeSetRepo
+	Events    events.Publisher
 }
 
 /*
 SwipeHandler processes swipe actions (like or dislike) between users.
 Extracts the UserID from the request context
 Updates the Swipe model with the UserID to associate the swipe action with the correct user.
-If the swipe preference is false (dislike), it simply inserts the swipe record into the repository and sets the SwipeResponse's matched field to false.
-If the swipe preference is true (like), it checks if the swiped user has also swiped right (liked) on the current user, indicating a potential match.
-If a match is found, it generates a unique MatchID, updates the swipe action to indicate a match, and inserts the record into the repository.
-The SwipeResponse includes the MatchID and indicates a successful match.
-If no match is found, it inserts the swipe action as a non-matching action into the repository, and the SwipeResponse indicates no match.
+Swipes without a swiped user, or on the user themselves, are rejected.
+Swipes between two users where either one has blocked the other are rejected.
+The swipe record is always inserted into the repository first, unmatched. A swipe that is already part of a match
+cannot be replaced by a dislike.
+The swiped user is then added to the user's swipe set in Elasticsearch, which keeps them out of the user's discover
+results. If that fails the swipe set is dropped, so discover rebuilds it from the swipe history instead of missing a swipe.
+If the swipe preference is true (like), the repository then atomically checks whether the swiped user has also liked the
+current user and, if so, marks both swipes as matched under a newly generated MatchID and stores the match record for
+both users. Two users liking each other at the same time always end up with exactly one match.
+If this request created the match, both users are sent a "match_created" event.
+The SwipeResponse includes the MatchID and indicates a successful match, otherwise it indicates no match.
 */
 func SwipeHandler(deps *SwipeDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -38,47 +50,64 @@ func SwipeHandler(deps *SwipeDeps) http.HandlerFunc {
 			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
 			return
 		}
+		if s.SwipedUserID == "" {
+			http.Error(w, "Invalid swiped user", http.StatusBadRequest)
+			return
+		}
+		if s.SwipedUserID == UserID {
+			http.Error(w, "Cannot swipe on yourself", http.StatusBadRequest)
+			return
+		}
 		s.UserID = UserID
+		s.Matched = false
+		s.MatchID = ""
 
-		var sp models.SwipeResponse
-		if s.Preference == false {
-			sp.Matched = false
-			// Insert the swipe record
-			if err := deps.SwipeRepo.InsertSwipeRecord(s); err != nil {
+		blocked, err := deps.BlockRepo.IsBlocked(UserID, s.SwipedUserID)
+		if err != nil {
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to check block status", http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			http.Error(w, "Cannot swipe on this user", http.StatusForbidden)
+			return
+		}
+
+		err = deps.SwipeRepo.InsertSwipeRecord(s)
+		switch {
+		case errors.Is(err, repository.ErrAlreadyMatched) && !s.Preference:
+			http.Error(w, "Already matched with this user", http.StatusConflict)
+			return
+		case errors.Is(err, repository.ErrAlreadyMatched):
+			// Liking someone you already matched with again just returns the existing match below
+		case err != nil:
+			log.Printf("Query Failure: %v", err)
+			http.Error(w, "Failed to insert swipe record", http.StatusInternalServerError)
+			return
+		}
+
+		if err = deps.SwipeSets.AddToSwipeSet(UserID, s.SwipedUserID); err != nil {
+			log.Printf("Query Failure: %v", err)
+			if err = deps.SwipeSets.DeleteSwipeSet(UserID); err != nil {
 				log.Printf("Query Failure: %v", err)
-				http.Error(w, "Failed to insert swipe record", http.StatusInternalServerError)
-				return
 			}
 		}
 
+		var sp models.SwipeResponse
 		if s.Preference == true {
-			// Check if the swiped user has swiped "yes" on the current user
-			isMatch, err := deps.SwipeRepo.CheckSwipeMatch(s.SwipedUserID, UserID)
+			matchID, created, err := deps.SwipeRepo.MatchMutualLikes(UserID, s.SwipedUserID, uuid.New().String(), time.Now().Unix())
 			if err != nil {
 				log.Printf("Query Failure: %v", err)
 				http.Error(w, "Failed to check for swipe match", http.StatusInternalServerError)
 				return
 			}
-			if isMatch == true {
-				// It's a match! Generate a unique MatchID
-				s.MatchID = uuid.New().String()
-				s.Matched = true
-				if err = deps.SwipeRepo.InsertSwipeRecord(s); err != nil {
-					log.Printf("Query Failure: %v", err)
-					http.Error(w, "Failed to insert swipe record", http.StatusInternalServerError)
-					return
-				}
-				sp.MatchID = s.MatchID
+			if matchID != "" {
 				sp.Matched = true
-			} else {
-				sp.Matched = false
-				s.Matched = false
-				// Insert the swipe record
-				if err = deps.SwipeRepo.InsertSwipeRecord(s); err != nil {
-					log.Printf("Query Failure: %v", err)
-					http.Error(w, "Failed to insert swipe record", http.StatusInternalServerError)
-					return
-				}
+				sp.MatchID = matchID
+			}
+			if created {
+				deps.Events.Publish(UserID, events.Event{Type: events.TypeMatchCreated, Data: events.MatchData{MatchID: matchID, UserID: s.SwipedUserID}})
+				deps.Events.Publish(s.SwipedUserID, events.Event{Type: events.TypeMatchCreated, Data: events.MatchData{MatchID: matchID, UserID: UserID}})
 			}
 		}
 
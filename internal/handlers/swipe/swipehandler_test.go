@@ -7,7 +7,10 @@ import (
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"quick-match/internal/events"
 	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"sync"
 	"testing"
 
 	"context"
@@ -23,16 +26,47 @@ func (m *MockSwipeRepo) InsertSwipeRecord(swipe models.Swipe) error {
 	return args.Error(0)
 }
 
-func (m *MockSwipeRepo) CheckSwipeMatch(swipedUserID, currentUserID string) (bool, error) {
-	args := m.Called(swipedUserID, currentUserID)
+func (m *MockSwipeRepo) MatchMutualLikes(userID, swipedUserID, matchID string, matchedAt int64) (string, bool, error) {
+	args := m.Called(userID, swipedUserID, matchID, matchedAt)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+type MockBlockCheckRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlockCheckRepo) IsBlocked(userID, otherUserID string) (bool, error) {
+	args := m.Called(userID, otherUserID)
 	return args.Bool(0), args.Error(1)
 }
 
+type MockSwipeSetRepo struct {
+	mock.Mock
+}
+
+func (m *MockSwipeSetRepo) AddToSwipeSet(userID, swipedUserID string) error {
+	args := m.Called(userID, swipedUserID)
+	return args.Error(0)
+}
+
+func (m *MockSwipeSetRepo) DeleteSwipeSet(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) Publish(userID string, event events.Event) {
+	m.Called(userID, event)
+}
+
 func TestSwipeHandler(t *testing.T) {
 	tests := []struct {
 		name             string
 		body             models.Swipe
-		mockSetup        func(m *MockSwipeRepo)
+		mockSetup        func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher)
 		expectedStatus   int
 		expectedResponse models.SwipeResponse
 		userID           string
@@ -40,8 +74,10 @@ func TestSwipeHandler(t *testing.T) {
 		{
 			name: "dislike swipe",
 			body: models.Swipe{SwipedUserID: "user2", Preference: false},
-			mockSetup: func(m *MockSwipeRepo) {
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
 				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(nil)
 			},
 			expectedStatus:   http.StatusOK,
 			expectedResponse: models.SwipeResponse{Matched: false},
@@ -50,9 +86,11 @@ func TestSwipeHandler(t *testing.T) {
 		{
 			name: "like swipe with no match",
 			body: models.Swipe{SwipedUserID: "user2", Preference: true},
-			mockSetup: func(m *MockSwipeRepo) {
-				m.On("CheckSwipeMatch", "user2", "user1").Return(false, nil)
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
 				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(nil)
+				m.On("MatchMutualLikes", "user1", "user2", mock.AnythingOfType("string"), mock.AnythingOfType("int64")).Return("", false, nil)
 			},
 			expectedStatus:   http.StatusOK,
 			expectedResponse: models.SwipeResponse{Matched: false},
@@ -61,18 +99,113 @@ func TestSwipeHandler(t *testing.T) {
 		{
 			name: "like swipe with match",
 			body: models.Swipe{SwipedUserID: "user2", Preference: true},
-			mockSetup: func(m *MockSwipeRepo) {
-				m.On("CheckSwipeMatch", "user2", "user1").Return(true, nil)
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(nil)
+				m.On("MatchMutualLikes", "user1", "user2", mock.AnythingOfType("string"), mock.AnythingOfType("int64")).Return("match1", true, nil)
+				mp.On("Publish", "user1", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeMatchCreated && e.Data.(events.MatchData).UserID == "user2"
+				})).Return()
+				mp.On("Publish", "user2", mock.MatchedBy(func(e events.Event) bool {
+					return e.Type == events.TypeMatchCreated && e.Data.(events.MatchData).UserID == "user1"
+				})).Return()
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: models.SwipeResponse{Matched: true, MatchID: "match1"},
+			userID:           "user1",
+		},
+		{
+			name: "like swipe matched first by the other user",
+			body: models.Swipe{SwipedUserID: "user2", Preference: true},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
 				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(nil)
+				m.On("MatchMutualLikes", "user1", "user2", mock.AnythingOfType("string"), mock.AnythingOfType("int64")).Return("match1", false, nil)
 			},
 			expectedStatus:   http.StatusOK,
-			expectedResponse: models.SwipeResponse{Matched: true}, // MatchID not tested here due to randomness
+			expectedResponse: models.SwipeResponse{Matched: true, MatchID: "match1"},
+			userID:           "user1",
+		},
+		{
+			name: "dislike on an existing match",
+			body: models.Swipe{SwipedUserID: "user2", Preference: false},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(repository.ErrAlreadyMatched)
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: models.SwipeResponse{},
+			userID:           "user1",
+		},
+		{
+			name: "error on match check",
+			body: models.Swipe{SwipedUserID: "user2", Preference: true},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(nil)
+				m.On("MatchMutualLikes", "user1", "user2", mock.AnythingOfType("string"), mock.AnythingOfType("int64")).Return("", false, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: models.SwipeResponse{},
+			userID:           "user1",
+		},
+		{
+			name: "swipe set update failure drops the swipe set",
+			body: models.Swipe{SwipedUserID: "user2", Preference: false},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
+				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(nil)
+				ms.On("AddToSwipeSet", "user1", "user2").Return(errors.New("es error"))
+				ms.On("DeleteSwipeSet", "user1").Return(nil)
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: models.SwipeResponse{Matched: false},
+			userID:           "user1",
+		},
+		{
+			name:             "swipe without a swiped user",
+			body:             models.Swipe{Preference: true},
+			mockSetup:        func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: models.SwipeResponse{},
+			userID:           "user1",
+		},
+		{
+			name:             "swipe on yourself",
+			body:             models.Swipe{SwipedUserID: "user1", Preference: true},
+			mockSetup:        func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: models.SwipeResponse{},
+			userID:           "user1",
+		},
+		{
+			name: "swipe on a blocked user",
+			body: models.Swipe{SwipedUserID: "user2", Preference: true},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(true, nil)
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedResponse: models.SwipeResponse{},
+			userID:           "user1",
+		},
+		{
+			name: "error on block check",
+			body: models.Swipe{SwipedUserID: "user2", Preference: true},
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, errors.New("db error"))
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: models.SwipeResponse{},
 			userID:           "user1",
 		},
 		{
 			name: "error on swipe record insertion",
 			body: models.Swipe{SwipedUserID: "user2", Preference: false},
-			mockSetup: func(m *MockSwipeRepo) {
+			mockSetup: func(m *MockSwipeRepo, mb *MockBlockCheckRepo, ms *MockSwipeSetRepo, mp *MockPublisher) {
+				mb.On("IsBlocked", "user1", "user2").Return(false, nil)
 				m.On("InsertSwipeRecord", mock.AnythingOfType("models.Swipe")).Return(errors.New("db error"))
 			},
 			expectedStatus:   http.StatusInternalServerError,
@@ -84,10 +217,16 @@ func TestSwipeHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockSwipeRepo)
-			tt.mockSetup(mockRepo)
+			mockBlockRepo := new(MockBlockCheckRepo)
+			mockSwipeSetRepo := new(MockSwipeSetRepo)
+			mockPublisher := new(MockPublisher)
+			tt.mockSetup(mockRepo, mockBlockRepo, mockSwipeSetRepo, mockPublisher)
 
 			deps := SwipeDeps{
 				SwipeRepo: mockRepo,
+				BlockRepo: mockBlockRepo,
+				SwipeSets: mockSwipeSetRepo,
+				Events:    mockPublisher,
 			}
 
 			handler := SwipeHandler(&deps)
@@ -104,10 +243,138 @@ func TestSwipeHandler(t *testing.T) {
 
 			var response models.SwipeResponse
 			if err := json.NewDecoder(rr.Body).Decode(&response); err == nil {
-				assert.Equal(t, tt.expectedResponse.Matched, response.Matched)
+				assert.Equal(t, tt.expectedResponse, response)
 			}
 
 			mockRepo.AssertExpectations(t)
+			mockBlockRepo.AssertExpectations(t)
+			mockSwipeSetRepo.AssertExpectations(t)
+			mockPublisher.AssertExpectations(t)
 		})
 	}
 }
+
+/*
+fakeSwipeStore is an in-memory SwipeRepo with the same conditional semantics as the DynamoDB implementation:
+InsertSwipeRecord refuses to overwrite a matched swipe, and MatchMutualLikes marks both swipes and stores the match
+records in a single atomic step that only succeeds if both swipes are unmatched likes.
+*/
+type fakeSwipeStore struct {
+	mu      sync.Mutex
+	swipes  map[[2]string]models.Swipe
+	matches map[string]int
+}
+
+func newFakeSwipeStore() *fakeSwipeStore {
+	return &fakeSwipeStore{
+		swipes:  make(map[[2]string]models.Swipe),
+		matches: make(map[string]int),
+	}
+}
+
+func (f *fakeSwipeStore) InsertSwipeRecord(swipe models.Swipe) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := [2]string{swipe.UserID, swipe.SwipedUserID}
+	if f.swipes[key].Matched {
+		return repository.ErrAlreadyMatched
+	}
+	f.swipes[key] = swipe
+	return nil
+}
+
+func (f *fakeSwipeStore) MatchMutualLikes(userID, swipedUserID, matchID string, matchedAt int64) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	own, theirs := [2]string{userID, swipedUserID}, [2]string{swipedUserID, userID}
+	a, b := f.swipes[own], f.swipes[theirs]
+	if a.Preference && !a.Matched && b.Preference && !b.Matched {
+		a.Matched, a.MatchID = true, matchID
+		b.Matched, b.MatchID = true, matchID
+		f.swipes[own], f.swipes[theirs] = a, b
+		f.matches[matchID] += 2
+		return matchID, true, nil
+	}
+	if a.Matched {
+		return a.MatchID, false, nil
+	}
+	return "", false, nil
+}
+
+func (f *fakeSwipeStore) IsBlocked(userID, otherUserID string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeSwipeStore) AddToSwipeSet(userID, swipedUserID string) error {
+	return nil
+}
+
+func (f *fakeSwipeStore) DeleteSwipeSet(userID string) error {
+	return nil
+}
+
+type countingPublisher struct {
+	mu     sync.Mutex
+	events map[string][]events.Event
+}
+
+func (p *countingPublisher) Publish(userID string, event events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events[userID] = append(p.events[userID], event)
+}
+
+func TestSwipeHandlerConcurrentMutualLike(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		store := newFakeSwipeStore()
+		publisher := &countingPublisher{events: make(map[string][]events.Event)}
+		handler := SwipeHandler(&SwipeDeps{SwipeRepo: store, BlockRepo: store, SwipeSets: store, Events: publisher})
+
+		responses := make([]models.SwipeResponse, 2)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for n, pair := range [][2]string{{"user1", "user2"}, {"user2", "user1"}} {
+			wg.Add(1)
+			go func(n int, userID, swipedUserID string) {
+				defer wg.Done()
+				bodyBytes, _ := json.Marshal(models.Swipe{SwipedUserID: swipedUserID, Preference: true})
+				req, _ := http.NewRequest("POST", "/swipe", bytes.NewBuffer(bodyBytes))
+				req = req.WithContext(context.WithValue(req.Context(), "UserID", userID))
+				rr := httptest.NewRecorder()
+
+				<-start
+				handler.ServeHTTP(rr, req)
+
+				assert.Equal(t, http.StatusOK, rr.Code)
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&responses[n]))
+			}(n, pair[0], pair[1])
+		}
+		close(start)
+		wg.Wait()
+
+		// Exactly one match exists, and both swipe rows point at it
+		assert.Len(t, store.matches, 1, "expected exactly one match")
+		row1, row2 := store.swipes[[2]string{"user1", "user2"}], store.swipes[[2]string{"user2", "user1"}]
+		assert.True(t, row1.Matched)
+		assert.True(t, row2.Matched)
+		assert.Equal(t, row1.MatchID, row2.MatchID)
+		for matchID, rows := range store.matches {
+			assert.Equal(t, row1.MatchID, matchID)
+			assert.Equal(t, 2, rows)
+		}
+
+		// At least the later request reports the match, and nobody reports a different MatchID
+		assert.True(t, responses[0].Matched || responses[1].Matched, "mutual like was lost")
+		for _, response := range responses {
+			if response.Matched {
+				assert.Equal(t, row1.MatchID, response.MatchID)
+			}
+		}
+
+		// The match is announced once to each user
+		assert.Len(t, publisher.events["user1"], 1)
+		assert.Len(t, publisher.events["user2"], 1)
+	}
+}
@@ -9,36 +9,36 @@ import (
 )
 
 type CreateUserDeps struct {
-	UserRepoES repository.InsertUserESRepo
-	UserRepo   repository.InsertUserRepo
+	UserRepo        repository.InsertUserRepo
+	PasswordService services.PasswordService
 }
 
 /*
-CreateUserHandler generates new users. The process involves the following steps:
+CreateUserHandler generates fake users for development seeding and is only routed when dev routes are enabled.
+Real users register through the signup endpoint. The process involves the following steps:
 Generates a new user entity using the GenerateNewUser function from the services package. This entity includes all necessary details for a new user.
-Inserts the new generated user into DynamoDB & ElasticSearch with sensitive data stripped.
+The password is hashed with the PasswordService, the same way signup hashes it.
+Inserts the new generated user into DynamoDB, from where the outbox indexer indexes them.
+Returns the generated user including its plaintext password, so it can be used to log in.
 */
 func CreateUserHandler(deps *CreateUserDeps) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		newUser := services.GenerateNewUser()
-
-		err := deps.UserRepo.InsertUser(newUser)
+		newUser, err := services.GenerateNewUser(deps.PasswordService)
 		if err != nil {
-			log.Printf("Query Failure: %v", err)
-			http.Error(w, "Failed to insert user", http.StatusInternalServerError)
+			log.Printf("Password Hashing Failure: %v", err)
+			http.Error(w, "Failed to generate user", http.StatusInternalServerError)
 			return
 		}
 
-		userES := repository.CreateElasticSearchUser(newUser)
-		err = deps.UserRepoES.InsertUserES(userES)
+		err = deps.UserRepo.InsertUser(newUser)
 		if err != nil {
 			log.Printf("Query Failure: %v", err)
 			http.Error(w, "Failed to insert user", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
 		if err = json.NewEncoder(w).Encode(newUser); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -20,20 +20,30 @@ func (m *MockUserRepo) InsertUser(user models.UserDetails) error {
 	return args.Error(0)
 }
 
-type MockUserRepoES struct {
+type MockPasswordService struct {
 	mock.Mock
 }
 
-func (m *MockUserRepoES) InsertUserES(user models.UserDetailsES) error {
-	args := m.Called(user)
+func (m *MockPasswordService) CompareHashAndPassword(hashedPassword, password string) error {
+	args := m.Called(hashedPassword, password)
 	return args.Error(0)
 }
 
+func (m *MockPasswordService) GenerateHashedPassword(password string) (string, error) {
+	args := m.Called(password)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPasswordService) NeedsRehash(hashedPassword string) bool {
+	args := m.Called(hashedPassword)
+	return args.Bool(0)
+}
+
 func TestCreateUserHandler(t *testing.T) {
 	tests := []struct {
 		name                 string
 		mockUserRepo         func() *MockUserRepo
-		mockUserRepoES       func() *MockUserRepoES
+		mockPasswordService  func() *MockPasswordService
 		expectedStatus       int
 		expectedBodyContains string
 	}{
@@ -41,17 +51,33 @@ func TestCreateUserHandler(t *testing.T) {
 			name: "Successful User Creation",
 			mockUserRepo: func() *MockUserRepo {
 				m := new(MockUserRepo)
-				m.On("InsertUser", mock.AnythingOfType("models.UserDetails")).Return(nil)
+				m.On("InsertUser", mock.MatchedBy(func(u models.UserDetails) bool {
+					return u.PasswordHashed == "hashed" && u.Password != ""
+				})).Return(nil)
 				return m
 			},
-			mockUserRepoES: func() *MockUserRepoES {
-				m := new(MockUserRepoES)
-				m.On("InsertUserES", mock.AnythingOfType("models.UserDetailsES")).Return(nil)
+			mockPasswordService: func() *MockPasswordService {
+				m := new(MockPasswordService)
+				m.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("hashed", nil)
 				return m
 			},
 			expectedStatus:       http.StatusCreated,
 			expectedBodyContains: "",
 		},
+		{
+			name: "Failure Hashing Password",
+			mockUserRepo: func() *MockUserRepo {
+				m := new(MockUserRepo)
+				return m
+			},
+			mockPasswordService: func() *MockPasswordService {
+				m := new(MockPasswordService)
+				m.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("", errors.New("hash error"))
+				return m
+			},
+			expectedStatus:       http.StatusInternalServerError,
+			expectedBodyContains: "Failed to generate user",
+		},
 		{
 			name: "Failure Inserting User into Repo",
 			mockUserRepo: func() *MockUserRepo {
@@ -59,8 +85,9 @@ func TestCreateUserHandler(t *testing.T) {
 				m.On("InsertUser", mock.AnythingOfType("models.UserDetails")).Return(errors.New("insert user error"))
 				return m
 			},
-			mockUserRepoES: func() *MockUserRepoES {
-				m := new(MockUserRepoES)
+			mockPasswordService: func() *MockPasswordService {
+				m := new(MockPasswordService)
+				m.On("GenerateHashedPassword", mock.AnythingOfType("string")).Return("hashed", nil)
 				return m
 			},
 			expectedStatus:       http.StatusInternalServerError,
@@ -71,11 +98,11 @@ func TestCreateUserHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockUserRepo := tt.mockUserRepo()
-			mockUserRepoES := tt.mockUserRepoES()
+			mockPasswordService := tt.mockPasswordService()
 
 			deps := CreateUserDeps{
-				UserRepo:   mockUserRepo,
-				UserRepoES: mockUserRepoES,
+				UserRepo:        mockUserRepo,
+				PasswordService: mockPasswordService,
 			}
 
 			handler := CreateUserHandler(&deps)
@@ -91,6 +118,10 @@ func TestCreateUserHandler(t *testing.T) {
 			handlerFunc.ServeHTTP(rr, req)
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusCreated {
+				// Result holds the headers as they were when the status was written
+				assert.Equal(t, "application/json", rr.Result().Header.Get("Content-Type"))
+			}
 
 			if tt.expectedBodyContains != "" {
 				body := rr.Body.String()
@@ -98,7 +129,7 @@ func TestCreateUserHandler(t *testing.T) {
 			}
 
 			mockUserRepo.AssertExpectations(t)
-			mockUserRepoES.AssertExpectations(t)
+			mockPasswordService.AssertExpectations(t)
 		})
 	}
 }
@@ -0,0 +1,106 @@
+package indexer
+
+import (
+	"context"
+	"log"
+	"quick-match/internal/repository"
+	"time"
+)
+
+const (
+	// batchSize is how many outbox entries are handled per poll.
+	batchSize = 100
+	// maxRetryDelay caps the backoff between attempts to index a user, so a long Elasticsearch outage is caught up
+	// within minutes of it ending.
+	maxRetryDelay = 5 * time.Minute
+)
+
+/*
+OutboxIndexer keeps the "users" index in line with DynamoDB, which is the source of truth. Every change to a user in
+DynamoDB leaves an entry in the outbox in the same transaction, and the indexer works through them: each user is read
+back from DynamoDB and reindexed, or removed from Elasticsearch if they no longer exist or are suspended. Entries that
+fail are retried with exponential backoff until they succeed. Handlers leave indexing to the indexer and only change
+DynamoDB, so a request never fails because Elasticsearch is down, and a change that could not be indexed is not lost.
+*/
+type OutboxIndexer struct {
+	Outbox     repository.OutboxRepo
+	UserRepo   repository.GetUserByIDRepo
+	UserRepoES repository.IndexUserESRepo
+}
+
+func NewOutboxIndexer(outbox repository.OutboxRepo, userRepo repository.GetUserByIDRepo, userRepoES repository.IndexUserESRepo) *OutboxIndexer {
+	return &OutboxIndexer{
+		Outbox:     outbox,
+		UserRepo:   userRepo,
+		UserRepoES: userRepoES,
+	}
+}
+
+/*
+SyncUser brings the user's Elasticsearch document in line with DynamoDB. Reading the user back, rather than indexing
+what the change wrote, makes it safe to sync the same user any number of times and in any order.
+*/
+func (ix *OutboxIndexer) SyncUser(userID string) error {
+	user, err := ix.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Suspended {
+		return ix.UserRepoES.DeleteUserES(userID)
+	}
+	return ix.UserRepoES.ReindexUserES(repository.CreateElasticSearchUser(*user))
+}
+
+// ProcessDue syncs every user whose outbox entry is due at now, and returns how many were synced.
+func (ix *OutboxIndexer) ProcessDue(now time.Time) (int, error) {
+	entries, err := ix.Outbox.ListDueOutboxEntries(now.Unix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for _, entry := range entries {
+		if err = ix.SyncUser(entry.UserID); err != nil {
+			next := now.Add(retryDelay(entry.Attempts))
+			log.Printf("Indexing Failure: user %s, attempt %d, retrying at %s: %v", entry.UserID, entry.Attempts+1, next.Format(time.RFC3339), err)
+			if err = ix.Outbox.RescheduleOutboxEntry(entry, next.Unix()); err != nil {
+				log.Printf("Query Failure: %v", err)
+			}
+			continue
+		}
+
+		if err = ix.Outbox.DeleteOutboxEntry(entry); err != nil {
+			log.Printf("Query Failure: %v", err)
+			continue
+		}
+		synced++
+	}
+
+	return synced, nil
+}
+
+// Start polls the outbox every interval until ctx is cancelled.
+func (ix *OutboxIndexer) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if _, err := ix.ProcessDue(now); err != nil {
+					log.Printf("Query Failure: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// retryDelay doubles the wait after every failed attempt, starting at one second and capped at maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	if attempts >= 16 {
+		return maxRetryDelay
+	}
+	return min(time.Second<<attempts, maxRetryDelay)
+}
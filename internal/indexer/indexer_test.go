@@ -0,0 +1,263 @@
+package indexer
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+	"testing"
+	"time"
+)
+
+type MockOutboxRepo struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepo) ListDueOutboxEntries(now int64, limit int) ([]models.OutboxEntry, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]models.OutboxEntry), args.Error(1)
+}
+
+func (m *MockOutboxRepo) DeleteOutboxEntry(entry models.OutboxEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepo) RescheduleOutboxEntry(entry models.OutboxEntry, nextAttemptAt int64) error {
+	args := m.Called(entry, nextAttemptAt)
+	return args.Error(0)
+}
+
+type MockUserRepo struct {
+	mock.Mock
+}
+
+func (m *MockUserRepo) GetUserByID(userID string) (*models.UserDetails, error) {
+	args := m.Called(userID)
+	user := args.Get(0)
+	if user == nil {
+		return nil, args.Error(1)
+	}
+	return user.(*models.UserDetails), args.Error(1)
+}
+
+type MockIndexUserESRepo struct {
+	mock.Mock
+}
+
+func (m *MockIndexUserESRepo) ReindexUserES(user models.UserDetailsES) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockIndexUserESRepo) DeleteUserES(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func storedUser(userID string) *models.UserDetails {
+	return &models.UserDetails{
+		UserID:         userID,
+		Email:          userID + "@example.com",
+		PasswordHashed: "hashed",
+		Name:           "Jane",
+	}
+}
+
+func TestSyncUser(t *testing.T) {
+	suspended := storedUser("user1")
+	suspended.Suspended = true
+
+	tests := []struct {
+		name        string
+		setupMocks  func(*MockUserRepo, *MockIndexUserESRepo)
+		expectedErr bool
+	}{
+		{
+			name: "user is reindexed",
+			setupMocks: func(mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mu.On("GetUserByID", "user1").Return(storedUser("user1"), nil)
+				me.On("ReindexUserES", repository.CreateElasticSearchUser(*storedUser("user1"))).Return(nil)
+			},
+		},
+		{
+			name: "missing user is removed",
+			setupMocks: func(mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mu.On("GetUserByID", "user1").Return(nil, nil)
+				me.On("DeleteUserES", "user1").Return(nil)
+			},
+		},
+		{
+			name: "suspended user is removed",
+			setupMocks: func(mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mu.On("GetUserByID", "user1").Return(suspended, nil)
+				me.On("DeleteUserES", "user1").Return(nil)
+			},
+		},
+		{
+			name: "query failure leaves the index alone",
+			setupMocks: func(mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mu.On("GetUserByID", "user1").Return(nil, errors.New("database error"))
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Elasticsearch failure is returned",
+			setupMocks: func(mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mu.On("GetUserByID", "user1").Return(storedUser("user1"), nil)
+				me.On("ReindexUserES", mock.Anything).Return(errors.New("es error"))
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserRepo := new(MockUserRepo)
+			mockUserRepoES := new(MockIndexUserESRepo)
+			tt.setupMocks(mockUserRepo, mockUserRepoES)
+
+			ix := NewOutboxIndexer(new(MockOutboxRepo), mockUserRepo, mockUserRepoES)
+			err := ix.SyncUser("user1")
+
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockUserRepo.AssertExpectations(t)
+			mockUserRepoES.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessDue(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	first := models.OutboxEntry{UserID: "user1", ChangeID: "change1", NextAttemptAt: now.Unix()}
+	retried := models.OutboxEntry{UserID: "user2", ChangeID: "change2", Attempts: 3, NextAttemptAt: now.Unix()}
+
+	tests := []struct {
+		name           string
+		setupMocks     func(*MockOutboxRepo, *MockUserRepo, *MockIndexUserESRepo)
+		expectedSynced int
+		expectedErr    bool
+	}{
+		{
+			name: "synced entries are deleted by their change ID",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{first, retried}, nil)
+				mu.On("GetUserByID", "user1").Return(storedUser("user1"), nil)
+				mu.On("GetUserByID", "user2").Return(nil, nil)
+				me.On("ReindexUserES", repository.CreateElasticSearchUser(*storedUser("user1"))).Return(nil)
+				me.On("DeleteUserES", "user2").Return(nil)
+				mo.On("DeleteOutboxEntry", first).Return(nil)
+				mo.On("DeleteOutboxEntry", retried).Return(nil)
+			},
+			expectedSynced: 2,
+		},
+		{
+			name: "failed entry is rescheduled by its change ID with backoff",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{first, retried}, nil)
+				mu.On("GetUserByID", "user1").Return(storedUser("user1"), nil)
+				mu.On("GetUserByID", "user2").Return(storedUser("user2"), nil)
+				me.On("ReindexUserES", repository.CreateElasticSearchUser(*storedUser("user1"))).Return(nil)
+				me.On("ReindexUserES", repository.CreateElasticSearchUser(*storedUser("user2"))).Return(errors.New("es error"))
+				mo.On("DeleteOutboxEntry", first).Return(nil)
+				mo.On("RescheduleOutboxEntry", retried, now.Add(8*time.Second).Unix()).Return(nil)
+			},
+			expectedSynced: 1,
+		},
+		{
+			name: "query failure reading the user is rescheduled",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{first}, nil)
+				mu.On("GetUserByID", "user1").Return(nil, errors.New("database error"))
+				mo.On("RescheduleOutboxEntry", first, now.Add(time.Second).Unix()).Return(nil)
+			},
+			expectedSynced: 0,
+		},
+		{
+			name: "failure deleting an entry is not counted and the rest continue",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{first, retried}, nil)
+				mu.On("GetUserByID", "user1").Return(nil, nil)
+				mu.On("GetUserByID", "user2").Return(nil, nil)
+				me.On("DeleteUserES", "user1").Return(nil)
+				me.On("DeleteUserES", "user2").Return(nil)
+				mo.On("DeleteOutboxEntry", first).Return(errors.New("database error"))
+				mo.On("DeleteOutboxEntry", retried).Return(nil)
+			},
+			expectedSynced: 1,
+		},
+		{
+			name: "failure rescheduling an entry leaves it due",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{first}, nil)
+				mu.On("GetUserByID", "user1").Return(nil, nil)
+				me.On("DeleteUserES", "user1").Return(errors.New("es error"))
+				mo.On("RescheduleOutboxEntry", first, now.Add(time.Second).Unix()).Return(errors.New("database error"))
+			},
+			expectedSynced: 0,
+		},
+		{
+			name: "nothing due",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry{}, nil)
+			},
+			expectedSynced: 0,
+		},
+		{
+			name: "query failure listing the outbox",
+			setupMocks: func(mo *MockOutboxRepo, mu *MockUserRepo, me *MockIndexUserESRepo) {
+				mo.On("ListDueOutboxEntries", now.Unix(), batchSize).Return([]models.OutboxEntry(nil), errors.New("database error"))
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOutboxRepo := new(MockOutboxRepo)
+			mockUserRepo := new(MockUserRepo)
+			mockUserRepoES := new(MockIndexUserESRepo)
+			tt.setupMocks(mockOutboxRepo, mockUserRepo, mockUserRepoES)
+
+			ix := NewOutboxIndexer(mockOutboxRepo, mockUserRepo, mockUserRepoES)
+			synced, err := ix.ProcessDue(now)
+
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedSynced, synced)
+
+			mockOutboxRepo.AssertExpectations(t)
+			mockUserRepo.AssertExpectations(t)
+			mockUserRepoES.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 0, expected: time.Second},
+		{attempts: 1, expected: 2 * time.Second},
+		{attempts: 3, expected: 8 * time.Second},
+		{attempts: 8, expected: 256 * time.Second},
+		{attempts: 9, expected: maxRetryDelay},
+		{attempts: 16, expected: maxRetryDelay},
+		// Large enough to overflow the shift if it were not capped first
+		{attempts: 70, expected: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, retryDelay(tt.attempts), "attempts: %d", tt.attempts)
+	}
+}
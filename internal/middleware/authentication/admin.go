@@ -0,0 +1,43 @@
+package authentication
+
+import (
+	"log"
+	"net/http"
+	"quick-match/internal/models"
+	"quick-match/internal/repository"
+)
+
+type AdminMiddlewareDeps struct {
+	UserRepo repository.GetUserByIDRepo
+}
+
+/*
+AdminMiddleware only lets requests through for users with the admin role. It must run after JWTMiddleware, which sets
+the UserID in the request context.
+The role is read from the user record on every request, so revoking it takes effect immediately.
+*/
+func AdminMiddleware(deps *AdminMiddlewareDeps) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			UserID, ok := r.Context().Value("UserID").(string)
+			if !ok {
+				log.Println("Could not extract UserID from token")
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+
+			user, err := deps.UserRepo.GetUserByID(UserID)
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+			if user == nil || user.Suspended || user.Role != models.RoleAdmin {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -4,32 +4,77 @@ import (
 	"context"
 	"fmt"
 	"github.com/golang-jwt/jwt"
+	"log"
 	"net/http"
+	"quick-match/internal/repository"
 	"strings"
+	"time"
 )
 
-// JWTMiddleware validates the JWT token and extracts the UserID, attaching it to the request context.
-func JWTMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := extractToken(r)
-		if tokenString == "" {
-			http.Error(w, "Authorization header is missing or invalid", http.StatusUnauthorized)
-			return
-		}
-
-		claims := &CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
-			return []byte("quick_match"), nil
-		})
+type JWTMiddlewareDeps struct {
+	SessionRepo repository.GetSessionRepo
+	UserRepo    repository.GetUserByIDRepo
+	Keys        *KeyManager
+}
+
+/*
+JWTMiddleware validates the JWT token and extracts the UserID, attaching it to the request context.
+The verification key is picked from the shared KeyManager by the token's `kid` header.
+The session the token was issued for is looked up on every request so that tokens belonging to a logged-out or
+revoked session are rejected before they expire.
+The user is read on every request as well, and refused with 403 Forbidden once suspended. Suspending a user also
+revokes their sessions, but a login that checked the user just before the suspension can still create one afterwards,
+so the revocation alone is not enough.
+*/
+func JWTMiddleware(deps *JWTMiddlewareDeps) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := extractToken(r)
+			if tokenString == "" {
+				http.Error(w, "Authorization header is missing or invalid", http.StatusUnauthorized)
+				return
+			}
+
+			claims := &CustomClaims{}
+			token, err := jwt.ParseWithClaims(tokenString, claims, deps.Keys.VerificationKey)
+
+			if err != nil || !token.Valid {
+				http.Error(w, fmt.Sprintf("Invalid or expired token: %v", err), http.StatusUnauthorized)
+				return
+			}
 
-		if err != nil || !token.Valid {
-			http.Error(w, fmt.Sprintf("Invalid or expired token: %v", err), http.StatusUnauthorized)
-			return
-		}
-		ctx := context.WithValue(r.Context(), "UserID", claims.UserID)
+			session, err := deps.SessionRepo.GetSession(claims.SessionID)
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+			if session == nil || session.UserID != claims.UserID || session.Revoked || session.ExpiresAt <= time.Now().Unix() {
+				http.Error(w, "Session has been revoked or expired", http.StatusUnauthorized)
+				return
+			}
 
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			user, err := deps.UserRepo.GetUserByID(claims.UserID)
+			if err != nil {
+				log.Printf("Query Failure: %v", err)
+				http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+				return
+			}
+			if user == nil {
+				http.Error(w, "Session has been revoked or expired", http.StatusUnauthorized)
+				return
+			}
+			if user.Suspended {
+				http.Error(w, "Account suspended", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "UserID", claims.UserID)
+			ctx = context.WithValue(ctx, "SessionID", claims.SessionID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 // extractToken extracts the JWT token from the Authorization header.
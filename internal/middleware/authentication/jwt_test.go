@@ -0,0 +1,128 @@
+package authentication
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"testing"
+	"time"
+)
+
+// fakeAuthStore keeps sessions and users in memory and revokes sessions the way the moderation handler does
+type fakeAuthStore struct {
+	sessions map[string]models.Session
+	users    map[string]models.UserDetails
+}
+
+func (f *fakeAuthStore) GetSession(sessionID string) (*models.Session, error) {
+	session, ok := f.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (f *fakeAuthStore) GetUserByID(userID string) (*models.UserDetails, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (f *fakeAuthStore) suspendUser(userID string) {
+	user := f.users[userID]
+	user.Suspended = true
+	f.users[userID] = user
+	for id, session := range f.sessions {
+		if session.UserID == userID {
+			session.Revoked = true
+			f.sessions[id] = session
+		}
+	}
+}
+
+func (f *fakeAuthStore) createSession(sessionID, userID string) {
+	f.sessions[sessionID] = models.Session{SessionID: sessionID, UserID: userID, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	keys := NewKeyManager(time.Hour)
+	keys.AddKey(NewHMACKey([]byte("secret")), true)
+	tokens := NewJWTTokenService(keys)
+
+	tests := []struct {
+		name             string
+		setup            func(*fakeAuthStore)
+		expectedStatus   int
+		expectedErrorMsg string
+	}{
+		{
+			name:           "active session",
+			setup:          func(f *fakeAuthStore) { f.createSession("session1", "user1") },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "session revoked by a suspension",
+			setup: func(f *fakeAuthStore) {
+				f.createSession("session1", "user1")
+				f.suspendUser("user1")
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Session has been revoked or expired",
+		},
+		{
+			// A login that checked the user just before the suspension creates its session after the revocation
+			name: "session created after the suspension",
+			setup: func(f *fakeAuthStore) {
+				f.suspendUser("user1")
+				f.createSession("session1", "user1")
+			},
+			expectedStatus:   http.StatusForbidden,
+			expectedErrorMsg: "Account suspended",
+		},
+		{
+			name: "user no longer exists",
+			setup: func(f *fakeAuthStore) {
+				f.createSession("session1", "user1")
+				delete(f.users, "user1")
+			},
+			expectedStatus:   http.StatusUnauthorized,
+			expectedErrorMsg: "Session has been revoked or expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeAuthStore{
+				sessions: map[string]models.Session{},
+				users:    map[string]models.UserDetails{"user1": {UserID: "user1"}},
+			}
+			tt.setup(store)
+
+			var userID string
+			handler := JWTMiddleware(&JWTMiddlewareDeps{SessionRepo: store, UserRepo: store, Keys: keys})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					userID, _ = r.Context().Value("UserID").(string)
+				}))
+
+			token, err := tokens.GenerateToken("user1", "session1")
+			require.NoError(t, err)
+			req := httptest.NewRequest("GET", "/profile", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedErrorMsg != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedErrorMsg)
+				assert.Empty(t, userID)
+			} else {
+				assert.Equal(t, "user1", userID)
+			}
+		})
+	}
+}
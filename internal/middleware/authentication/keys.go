@@ -0,0 +1,458 @@
+package authentication
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"quick-match/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	// DefaultRotationGrace keeps a rotated-out key valid for verification for a while, so access tokens signed
+	// just before a rotation keep working until they expire.
+	DefaultRotationGrace = 2 * AccessTokenTTL
+)
+
+var (
+	ErrUnknownKey        = errors.New("unknown signing key")
+	ErrUnexpectedAlg     = errors.New("unexpected signing algorithm")
+	ErrUnsupportedKeyAlg = errors.New("unsupported signing algorithm")
+)
+
+// SigningKey is a key used to sign and verify JWTs. For HS256 both SignKey and VerifyKey hold the shared secret.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   any
+	VerifyKey any
+	// RetiredAt is set once the key has been rotated out. It keeps verifying tokens until RetiredAt + grace.
+	RetiredAt time.Time
+}
+
+// KeySet exposes the public keys that can verify quick-match tokens.
+type KeySet interface {
+	JWKS() models.JWKS
+}
+
+/*
+KeyManager holds every key that is currently allowed to verify tokens, selected by their `kid` header, and the single
+key used to sign new tokens. It is shared by JWTTokenService and JWTMiddleware so both sides always agree.
+
+Rotating installs a new signing key and retires the previous one. Retired keys keep verifying tokens for the grace
+window and are then pruned.
+*/
+type KeyManager struct {
+	mu      sync.RWMutex
+	keys    map[string]*SigningKey
+	current *SigningKey
+	grace   time.Duration
+
+	// source is where Reload reads the keys from, nil if they are only added in code
+	source         *keysDir
+	reloadInterval time.Duration
+}
+
+// keysDir is a directory of PEM private keys shared by every instance, see NewKeyManagerFromEnv.
+type keysDir struct {
+	path       string
+	alg        string
+	signingKid string
+}
+
+func NewKeyManager(grace time.Duration) *KeyManager {
+	return &KeyManager{
+		keys:  make(map[string]*SigningKey),
+		grace: grace,
+	}
+}
+
+/*
+NewKeyManagerFromEnv builds a KeyManager from the environment:
+- JWT_SIGNING_ALG selects HS256 (default), RS256 or EdDSA.
+- JWT_KEY is the HS256 secret, defaulting to "quick_match" for local development.
+- JWT_KEYS_DIR points to a directory of PEM private keys for RS256/EdDSA, and is required for them. Each file name
+(without extension) is used as its kid, and JWT_SIGNING_KID selects the signing key, defaulting to the last file in
+lexical order. The directory must be shared by every instance, for example a mounted secret, since a key that only one
+instance knows would make the others reject its tokens.
+- JWT_ROTATION_INTERVAL, for RS256/EdDSA only, is how often StartReload reads JWT_KEYS_DIR again, see Reload.
+- JWT_ROTATION_GRACE overrides how long a rotated-out key keeps verifying tokens.
+*/
+func NewKeyManagerFromEnv() (*KeyManager, error) {
+	grace := DefaultRotationGrace
+	if v := os.Getenv("JWT_ROTATION_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_ROTATION_GRACE: %w", err)
+		}
+		grace = d
+	}
+	km := NewKeyManager(grace)
+
+	alg := SigningAlgFromEnv()
+	if alg == AlgHS256 {
+		if os.Getenv("JWT_ROTATION_INTERVAL") != "" {
+			return nil, errors.New("JWT_ROTATION_INTERVAL needs RS256 or EdDSA keys in JWT_KEYS_DIR")
+		}
+		secret := os.Getenv("JWT_KEY")
+		if secret == "" {
+			secret = "quick_match"
+		}
+		km.AddKey(NewHMACKey([]byte(secret)), true)
+		return km, nil
+	}
+
+	if alg != AlgRS256 && alg != AlgEdDSA {
+		return nil, ErrUnsupportedKeyAlg
+	}
+
+	// Every instance has to sign and verify with the same keys, so they are never generated in the running process
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is required for %s", alg)
+	}
+	km.source = &keysDir{path: dir, alg: alg, signingKid: os.Getenv("JWT_SIGNING_KID")}
+
+	if v := os.Getenv("JWT_ROTATION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid JWT_ROTATION_INTERVAL: %q", v)
+		}
+		km.reloadInterval = d
+	}
+
+	if err := km.Reload(); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// SigningAlgFromEnv returns the algorithm configured through JWT_SIGNING_ALG, defaulting to HS256.
+func SigningAlgFromEnv() string {
+	if alg := os.Getenv("JWT_SIGNING_ALG"); alg != "" {
+		return alg
+	}
+	return AlgHS256
+}
+
+// AddKey registers a key for verification and, if makeCurrent is set, uses it to sign new tokens.
+func (km *KeyManager) AddKey(key *SigningKey, makeCurrent bool) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	km.keys[key.ID] = key
+	if makeCurrent {
+		km.current = key
+	}
+}
+
+// Rotate makes key the signing key. The previous signing key is retired and only verifies tokens for the grace window.
+func (km *KeyManager) Rotate(key *SigningKey) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	now := time.Now()
+	if km.current != nil && km.current.ID != key.ID {
+		km.current.RetiredAt = now
+	}
+	km.keys[key.ID] = key
+	km.current = key
+	km.pruneLocked(now)
+}
+
+func (km *KeyManager) pruneLocked(now time.Time) {
+	for kid, key := range km.keys {
+		if !key.RetiredAt.IsZero() && now.After(key.RetiredAt.Add(km.grace)) {
+			delete(km.keys, kid)
+		}
+	}
+}
+
+func (km *KeyManager) SigningKey() *SigningKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	return km.current
+}
+
+/*
+VerificationKey is a jwt.Keyfunc. It picks the key named by the token's `kid` header and refuses tokens whose `alg`
+does not match that key, so an RS256 public key can never be used as an HS256 secret.
+*/
+func (km *KeyManager) VerificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	key, found := km.keys[kid]
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	if !key.RetiredAt.IsZero() && time.Now().After(key.RetiredAt.Add(km.grace)) {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrUnexpectedAlg
+	}
+
+	return key.VerifyKey, nil
+}
+
+// JWKS returns the public keys still valid for verification. Shared HS256 secrets are never published.
+func (km *KeyManager) JWKS() models.JWKS {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	now := time.Now()
+	jwks := models.JWKS{Keys: []models.JSONWebKey{}}
+	for _, key := range km.keys {
+		if !key.RetiredAt.IsZero() && now.After(key.RetiredAt.Add(km.grace)) {
+			continue
+		}
+		if jwk, ok := publicJWK(key); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+
+	return jwks
+}
+
+/*
+Reload reads the keys from JWT_KEYS_DIR again. Keys added there start verifying tokens, and keys removed from it are
+retired and keep verifying for the grace window. Without JWT_SIGNING_KID, a new last file only becomes the signing key
+once it has been in the directory for a full reload interval. By then every instance has read it at least once, so
+none of them rejects the tokens it signs. If no key is that old, as on a first deployment, the last file signs
+straight away.
+*/
+func (km *KeyManager) Reload() error {
+	if km.source == nil {
+		return errors.New("no key directory to reload from")
+	}
+
+	files, err := filepath.Glob(filepath.Join(km.source.path, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *.pem keys found in %s", km.source.path)
+	}
+	sort.Strings(files)
+
+	settled := time.Now().Add(-km.reloadInterval)
+	keys := make(map[string]*SigningKey, len(files))
+	var current, newest *SigningKey
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		key, err := LoadPEMKey(file, kid)
+		if err != nil {
+			return err
+		}
+		if key.Method.Alg() != km.source.alg {
+			return fmt.Errorf("key %s is %s, expected %s", kid, key.Method.Alg(), km.source.alg)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+
+		keys[kid] = key
+		newest = key
+		if km.source.signingKid == "" && !info.ModTime().After(settled) {
+			current = key
+		}
+	}
+	if km.source.signingKid != "" {
+		current = keys[km.source.signingKid]
+		if current == nil {
+			return fmt.Errorf("signing key %q not found in %s", km.source.signingKid, km.source.path)
+		}
+	} else if current == nil {
+		current = newest
+	}
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	now := time.Now()
+	for kid, key := range km.keys {
+		if _, found := keys[kid]; !found && key.RetiredAt.IsZero() {
+			key.RetiredAt = now
+		}
+	}
+	for kid, key := range keys {
+		km.keys[kid] = key
+	}
+	km.current = current
+	km.pruneLocked(now)
+
+	return nil
+}
+
+// StartReload calls Reload every JWT_ROTATION_INTERVAL until ctx is cancelled. It does nothing if none is configured.
+func (km *KeyManager) StartReload(ctx context.Context) {
+	if km.source == nil || km.reloadInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(km.reloadInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				previous := km.SigningKey()
+				if err := km.Reload(); err != nil {
+					log.Printf("Key Rotation Failure: %v", err)
+					continue
+				}
+				if current := km.SigningKey(); current.ID != previous.ID {
+					log.Printf("Rotated JWT signing key, new kid: %s", current.ID)
+				}
+			}
+		}
+	}()
+}
+
+func NewHMACKey(secret []byte) *SigningKey {
+	sum := sha256.Sum256(secret)
+	return &SigningKey{
+		ID:        "hs-" + hex.EncodeToString(sum[:8]),
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}
+}
+
+// GenerateSigningKey creates a new random key for the given algorithm, identified by its JWK thumbprint.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	switch alg {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(secret), nil
+	case AlgRS256:
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return newAsymmetricKey("", private)
+	case AlgEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return newAsymmetricKey("", private)
+	default:
+		return nil, ErrUnsupportedKeyAlg
+	}
+}
+
+// LoadPEMKey reads a PKCS#8 (or PKCS#1 for RSA) PEM private key from disk.
+func LoadPEMKey(path, kid string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if rsaErr != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		private = rsaKey
+	}
+
+	return newAsymmetricKey(kid, private)
+}
+
+func newAsymmetricKey(kid string, private any) (*SigningKey, error) {
+	key := &SigningKey{ID: kid, SignKey: private}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.VerifyKey = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.VerifyKey = k.Public()
+	default:
+		return nil, ErrUnsupportedKeyAlg
+	}
+
+	if key.ID == "" {
+		jwk, _ := publicJWK(key)
+		key.ID = thumbprint(jwk)
+	}
+
+	return key, nil
+}
+
+func publicJWK(key *SigningKey) (models.JSONWebKey, bool) {
+	switch pub := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		return models.JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: key.ID,
+			Alg: AlgRS256,
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return models.JSONWebKey{
+			Kty: "OKP",
+			Use: "sig",
+			Kid: key.ID,
+			Alg: AlgEdDSA,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}, true
+	}
+	return models.JSONWebKey{}, false
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, which gives generated keys a stable kid.
+func thumbprint(jwk models.JSONWebKey) string {
+	var members map[string]string
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case "OKP":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+	// encoding/json sorts map keys, which is exactly the canonical member order the RFC requires
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
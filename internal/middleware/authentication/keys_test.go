@@ -0,0 +1,372 @@
+package authentication
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"quick-match/internal/models"
+	"testing"
+	"time"
+)
+
+func tokenFor(kid string, method jwt.SigningMethod) *jwt.Token {
+	token := jwt.New(method)
+	token.Header["kid"] = kid
+	return token
+}
+
+func TestVerificationKey(t *testing.T) {
+	grace := time.Hour
+	hmacKey := NewHMACKey([]byte("secret"))
+	rsaKey, err := GenerateSigningKey(AlgRS256)
+	require.NoError(t, err)
+	edKey, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+
+	retiredInGrace, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	retiredInGrace.RetiredAt = time.Now().Add(-grace / 2)
+
+	retiredPastGrace, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	retiredPastGrace.RetiredAt = time.Now().Add(-2 * grace)
+
+	tests := []struct {
+		name        string
+		token       *jwt.Token
+		expectedKey any
+		expectedErr error
+	}{
+		{
+			name:        "HS256 key",
+			token:       tokenFor(hmacKey.ID, jwt.SigningMethodHS256),
+			expectedKey: hmacKey.VerifyKey,
+		},
+		{
+			name:        "RS256 key",
+			token:       tokenFor(rsaKey.ID, jwt.SigningMethodRS256),
+			expectedKey: rsaKey.VerifyKey,
+		},
+		{
+			name:        "EdDSA key",
+			token:       tokenFor(edKey.ID, jwt.SigningMethodEdDSA),
+			expectedKey: edKey.VerifyKey,
+		},
+		{
+			name:        "RS256 key presented as HS256",
+			token:       tokenFor(rsaKey.ID, jwt.SigningMethodHS256),
+			expectedErr: ErrUnexpectedAlg,
+		},
+		{
+			name:        "HS256 key presented as RS256",
+			token:       tokenFor(hmacKey.ID, jwt.SigningMethodRS256),
+			expectedErr: ErrUnexpectedAlg,
+		},
+		{
+			name:        "unknown kid",
+			token:       tokenFor("unknown", jwt.SigningMethodHS256),
+			expectedErr: ErrUnknownKey,
+		},
+		{
+			name:        "missing kid",
+			token:       jwt.New(jwt.SigningMethodHS256),
+			expectedErr: ErrUnknownKey,
+		},
+		{
+			name:        "retired key inside the grace window",
+			token:       tokenFor(retiredInGrace.ID, jwt.SigningMethodEdDSA),
+			expectedKey: retiredInGrace.VerifyKey,
+		},
+		{
+			name:        "retired key past the grace window",
+			token:       tokenFor(retiredPastGrace.ID, jwt.SigningMethodEdDSA),
+			expectedErr: ErrUnknownKey,
+		},
+	}
+
+	km := NewKeyManager(grace)
+	km.AddKey(hmacKey, false)
+	km.AddKey(rsaKey, false)
+	km.AddKey(retiredInGrace, false)
+	km.AddKey(retiredPastGrace, false)
+	km.AddKey(edKey, true)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := km.VerificationKey(tt.token)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, key)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedKey, key)
+		})
+	}
+}
+
+// An HS256 token signed with the RS256 public key as its secret is the classic algorithm confusion attack
+func TestVerificationKeyRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := GenerateSigningKey(AlgRS256)
+	require.NoError(t, err)
+	km := NewKeyManager(DefaultRotationGrace)
+	km.AddKey(rsaKey, true)
+
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.VerifyKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	forged := tokenFor(rsaKey.ID, jwt.SigningMethodHS256)
+	forged.Claims = jwt.MapClaims{"sub": "admin"}
+	signed, err := forged.SignedString(publicPEM)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(signed, km.VerificationKey)
+	assert.Error(t, err)
+	assert.ErrorIs(t, err.(*jwt.ValidationError).Inner, ErrUnexpectedAlg)
+}
+
+func TestRotate(t *testing.T) {
+	grace := time.Hour
+	first, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	second, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+
+	km := NewKeyManager(grace)
+	km.AddKey(first, true)
+	km.Rotate(second)
+
+	assert.Equal(t, second, km.SigningKey())
+	assert.False(t, first.RetiredAt.IsZero())
+	assert.True(t, second.RetiredAt.IsZero())
+
+	// Still inside the grace window, so tokens signed just before the rotation keep verifying
+	key, err := km.VerificationKey(tokenFor(first.ID, jwt.SigningMethodEdDSA))
+	assert.NoError(t, err)
+	assert.Equal(t, first.VerifyKey, key)
+	assert.Len(t, km.JWKS().Keys, 2)
+
+	// Once the window has passed, the retired key is pruned on the next rotation
+	first.RetiredAt = time.Now().Add(-2 * grace)
+	third, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	km.Rotate(third)
+
+	_, err = km.VerificationKey(tokenFor(first.ID, jwt.SigningMethodEdDSA))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Len(t, km.JWKS().Keys, 2)
+}
+
+// writePEMKey writes a new key of alg to dir as kid.pem, last modified at modTime
+func writePEMKey(t *testing.T, dir, kid, alg string, modTime time.Time) *SigningKey {
+	key, err := GenerateSigningKey(alg)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key.SignKey)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, kid+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	loaded, err := LoadPEMKey(path, kid)
+	require.NoError(t, err)
+	return loaded
+}
+
+func TestNewKeyManagerFromEnv(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		keys        []string
+		expectedKid string
+		expectedErr bool
+	}{
+		{name: "HS256 secret", env: map[string]string{"JWT_KEY": "secret"}, expectedKid: NewHMACKey([]byte("secret")).ID},
+		{name: "HS256 cannot rotate", env: map[string]string{"JWT_ROTATION_INTERVAL": "24h"}, expectedErr: true},
+		{name: "EdDSA without a key directory", env: map[string]string{"JWT_SIGNING_ALG": AlgEdDSA}, expectedErr: true},
+		{name: "RS256 without a key directory", env: map[string]string{"JWT_SIGNING_ALG": AlgRS256}, expectedErr: true},
+		{name: "unsupported algorithm", env: map[string]string{"JWT_SIGNING_ALG": "none"}, keys: []string{"a"}, expectedErr: true},
+		{name: "last key signs", env: map[string]string{"JWT_SIGNING_ALG": AlgEdDSA}, keys: []string{"a", "b"}, expectedKid: "b"},
+		{
+			name:        "signing kid selected",
+			env:         map[string]string{"JWT_SIGNING_ALG": AlgEdDSA, "JWT_SIGNING_KID": "a"},
+			keys:        []string{"a", "b"},
+			expectedKid: "a",
+		},
+		{
+			name:        "unknown signing kid",
+			env:         map[string]string{"JWT_SIGNING_ALG": AlgEdDSA, "JWT_SIGNING_KID": "c"},
+			keys:        []string{"a", "b"},
+			expectedErr: true,
+		},
+		{name: "empty key directory", env: map[string]string{"JWT_SIGNING_ALG": AlgEdDSA}, keys: []string{}, expectedErr: true},
+		{
+			name:        "invalid rotation interval",
+			env:         map[string]string{"JWT_SIGNING_ALG": AlgEdDSA, "JWT_ROTATION_INTERVAL": "soon"},
+			keys:        []string{"a"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			for name, value := range tt.env {
+				env[name] = value
+			}
+			if tt.keys != nil {
+				dir := t.TempDir()
+				for _, kid := range tt.keys {
+					writePEMKey(t, dir, kid, AlgEdDSA, old)
+				}
+				env["JWT_KEYS_DIR"] = dir
+			}
+			for _, name := range []string{"JWT_SIGNING_ALG", "JWT_KEY", "JWT_KEYS_DIR", "JWT_SIGNING_KID", "JWT_ROTATION_INTERVAL", "JWT_ROTATION_GRACE"} {
+				t.Setenv(name, env[name])
+			}
+
+			km, err := NewKeyManagerFromEnv()
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedKid, km.SigningKey().ID)
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	interval := time.Minute
+	dir := t.TempDir()
+	first := writePEMKey(t, dir, "2024-01", AlgEdDSA, time.Now().Add(-time.Hour))
+
+	km := NewKeyManager(time.Hour)
+	km.source = &keysDir{path: dir, alg: AlgEdDSA}
+	km.reloadInterval = interval
+	require.NoError(t, km.Reload())
+	assert.Equal(t, first.ID, km.SigningKey().ID)
+
+	// Other instances may not have read a new key yet, so it verifies tokens but does not sign them
+	second := writePEMKey(t, dir, "2024-02", AlgEdDSA, time.Now())
+	require.NoError(t, km.Reload())
+	assert.Equal(t, first.ID, km.SigningKey().ID)
+	key, err := km.VerificationKey(tokenFor(second.ID, jwt.SigningMethodEdDSA))
+	assert.NoError(t, err)
+	assert.Equal(t, second.VerifyKey, key)
+
+	// A full interval later every instance knows it
+	settled := time.Now().Add(-interval)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "2024-02.pem"), settled, settled))
+	require.NoError(t, km.Reload())
+	assert.Equal(t, second.ID, km.SigningKey().ID)
+
+	// A key removed from the directory keeps verifying for the grace window
+	require.NoError(t, os.Remove(filepath.Join(dir, "2024-01.pem")))
+	require.NoError(t, km.Reload())
+	_, err = km.VerificationKey(tokenFor(first.ID, jwt.SigningMethodEdDSA))
+	assert.NoError(t, err)
+	assert.Len(t, km.JWKS().Keys, 2)
+
+	km.keys[first.ID].RetiredAt = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, km.Reload())
+	_, err = km.VerificationKey(tokenFor(first.ID, jwt.SigningMethodEdDSA))
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// A broken directory leaves the loaded keys alone
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024-03.pem"), []byte("not a key"), 0o600))
+	assert.Error(t, km.Reload())
+	assert.Equal(t, second.ID, km.SigningKey().ID)
+}
+
+func TestJWKS(t *testing.T) {
+	grace := time.Hour
+	hmacKey := NewHMACKey([]byte("secret"))
+	rsaKey, err := GenerateSigningKey(AlgRS256)
+	require.NoError(t, err)
+	edKey, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	retired, err := GenerateSigningKey(AlgEdDSA)
+	require.NoError(t, err)
+	retired.RetiredAt = time.Now().Add(-2 * grace)
+
+	t.Run("only HMAC keys", func(t *testing.T) {
+		km := NewKeyManager(grace)
+		km.AddKey(hmacKey, true)
+
+		jwks := km.JWKS()
+		assert.NotNil(t, jwks.Keys)
+		assert.Empty(t, jwks.Keys)
+	})
+
+	t.Run("asymmetric keys published, HMAC and expired keys not", func(t *testing.T) {
+		km := NewKeyManager(grace)
+		km.AddKey(hmacKey, false)
+		km.AddKey(rsaKey, false)
+		km.AddKey(retired, false)
+		km.AddKey(edKey, true)
+
+		jwks := km.JWKS()
+		kids := make([]string, 0, len(jwks.Keys))
+		for _, jwk := range jwks.Keys {
+			kids = append(kids, jwk.Kid)
+			assert.NotEqual(t, "oct", jwk.Kty)
+			assert.Equal(t, "sig", jwk.Use)
+		}
+		assert.ElementsMatch(t, []string{rsaKey.ID, edKey.ID}, kids)
+		assert.NotContains(t, kids, hmacKey.ID)
+		assert.NotContains(t, kids, retired.ID)
+	})
+}
+
+func TestThumbprintKid(t *testing.T) {
+	t.Run("RFC 7638 example", func(t *testing.T) {
+		jwk := models.JSONWebKey{
+			Kty: "RSA",
+			N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+			E:   "AQAB",
+		}
+		assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint(jwk))
+	})
+
+	t.Run("same key gets the same kid", func(t *testing.T) {
+		_, private, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+
+		first, err := newAsymmetricKey("", private)
+		require.NoError(t, err)
+		second, err := newAsymmetricKey("", private)
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, first.ID)
+		assert.Equal(t, first.ID, second.ID)
+	})
+
+	t.Run("different keys get different kids", func(t *testing.T) {
+		first, err := GenerateSigningKey(AlgRS256)
+		require.NoError(t, err)
+		second, err := GenerateSigningKey(AlgRS256)
+		require.NoError(t, err)
+
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("kid from the PEM file name is kept", func(t *testing.T) {
+		private, err := GenerateSigningKey(AlgRS256)
+		require.NoError(t, err)
+
+		key, err := newAsymmetricKey("2024-01", private.SignKey.(*rsa.PrivateKey))
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01", key.ID)
+	})
+}
@@ -1,46 +1,100 @@
 package authentication
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"github.com/golang-jwt/jwt"
-	"os"
+	"strings"
 	"time"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var ErrMalformedRefreshToken = errors.New("malformed refresh token")
+
 type TokenService interface {
-	GenerateToken(userID string) (string, error)
+	GenerateToken(userID, sessionID string) (string, error)
+	GenerateRefreshToken(sessionID string) (RefreshToken, error)
 }
 
-type JWTTokenService struct{}
+type JWTTokenService struct {
+	Keys *KeyManager
+}
 
 type CustomClaims struct {
-	UserID string `json:"userId"`
+	UserID    string `json:"userId"`
+	SessionID string `json:"sid"`
 	jwt.StandardClaims
 }
 
-func NewJWTTokenService() *JWTTokenService {
-	return &JWTTokenService{}
+// RefreshToken is an opaque token handed to the client. Only its Hash is ever persisted.
+type RefreshToken struct {
+	Token     string
+	Hash      string
+	ExpiresAt time.Time
 }
 
-// GenerateToken generates a new JWT token for a given user ID.
-func (service *JWTTokenService) GenerateToken(userID string) (string, error) {
-	jwtkey := os.Getenv("JWT_KEY")
-	if jwtkey == "" {
-		jwtkey = "quick_match"
-	}
-	var jk = []byte(jwtkey)
-	expirationTime := time.Now().Add(24 * time.Hour)
+func NewJWTTokenService(keys *KeyManager) *JWTTokenService {
+	return &JWTTokenService{Keys: keys}
+}
+
+/*
+GenerateToken generates a new short-lived JWT access token for a given user ID, bound to the session it was issued for.
+The token is signed with the KeyManager's current signing key and names it in the `kid` header.
+*/
+func (service *JWTTokenService) GenerateToken(userID, sessionID string) (string, error) {
+	key := service.Keys.SigningKey()
+	now := time.Now()
 	claims := &CustomClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jk)
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, err
 }
+
+// GenerateRefreshToken creates a random refresh token for a session in the form "<sessionID>.<secret>".
+func (service *JWTTokenService) GenerateRefreshToken(sessionID string) (RefreshToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return RefreshToken{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	return RefreshToken{
+		Token:     sessionID + "." + encoded,
+		Hash:      HashRefreshSecret(encoded),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}, nil
+}
+
+// ParseRefreshToken splits a refresh token into its session ID and the hash of its secret.
+func ParseRefreshToken(token string) (sessionID, hash string, err error) {
+	sessionID, secret, found := strings.Cut(token, ".")
+	if !found || sessionID == "" || secret == "" {
+		return "", "", ErrMalformedRefreshToken
+	}
+	return sessionID, HashRefreshSecret(secret), nil
+}
+
+func HashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,10 @@
+package validation
+
+import (
+	"quick-match/internal/models"
+)
+
+// ValidateDeleteAccount validates the DeleteAccountRequest struct.
+func ValidateDeleteAccount(request models.DeleteAccountRequest) error {
+	return validate.Struct(request)
+}
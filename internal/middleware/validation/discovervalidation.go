@@ -0,0 +1,15 @@
+package validation
+
+import (
+	"quick-match/internal/models"
+)
+
+// ValidateDiscover validates the DiscoverFilters struct.
+func ValidateDiscover(filters models.DiscoverFilters) error {
+	return validate.Struct(filters)
+}
+
+// ValidateDiscoverSettings validates the DiscoverSettings struct.
+func ValidateDiscoverSettings(settings models.DiscoverSettings) error {
+	return validate.Struct(settings)
+}
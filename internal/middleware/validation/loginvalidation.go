@@ -15,8 +15,10 @@ func emailRegexValidation(fl validator.FieldLevel) bool {
 // ValidateLogin uses the validator package to validate the LoginCredentials struct,
 // including a custom regex validation for the email.
 func ValidateLogin(login models.LoginCredentials) error {
-	validate := validator.New()
-	validate.RegisterValidation("email_regex", emailRegexValidation)
-
 	return validate.Struct(login)
 }
+
+// ValidateRefresh validates the RefreshRequest struct.
+func ValidateRefresh(refresh models.RefreshRequest) error {
+	return validate.Struct(refresh)
+}
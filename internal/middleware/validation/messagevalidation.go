@@ -0,0 +1,10 @@
+package validation
+
+import (
+	"quick-match/internal/models"
+)
+
+// ValidateMessage validates the SendMessageRequest struct.
+func ValidateMessage(message models.SendMessageRequest) error {
+	return validate.Struct(message)
+}
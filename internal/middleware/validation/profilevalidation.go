@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"quick-match/internal/models"
+)
+
+// interestValidation accepts only tags from the interest taxonomy.
+func interestValidation(fl validator.FieldLevel) bool {
+	return models.IsInterest(fl.Field().String())
+}
+
+// ValidateProfileUpdate validates the ProfileUpdateRequest struct.
+func ValidateProfileUpdate(update models.ProfileUpdateRequest) error {
+	return validate.Struct(update)
+}
+
+// ValidatePreferences validates the Preferences struct, used to check the preferences that result from a partial update.
+func ValidatePreferences(preferences models.Preferences) error {
+	return validate.Struct(preferences)
+}
+
+// ValidateLocationUpdate validates the LocationUpdateRequest struct.
+func ValidateLocationUpdate(update models.LocationUpdateRequest) error {
+	return validate.Struct(update)
+}
+
+// ValidatePhotoOrder validates the PhotoOrderRequest struct.
+func ValidatePhotoOrder(order models.PhotoOrderRequest) error {
+	return validate.Struct(order)
+}
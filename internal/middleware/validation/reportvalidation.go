@@ -0,0 +1,15 @@
+package validation
+
+import (
+	"quick-match/internal/models"
+)
+
+// ValidateReport validates the ReportRequest struct.
+func ValidateReport(report models.ReportRequest) error {
+	return validate.Struct(report)
+}
+
+// ValidateResolveReport validates the ResolveReportRequest struct.
+func ValidateResolveReport(resolve models.ResolveReportRequest) error {
+	return validate.Struct(resolve)
+}
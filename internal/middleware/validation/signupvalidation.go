@@ -0,0 +1,18 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"quick-match/internal/models"
+	"time"
+)
+
+// adultValidation accepts birthdates of people who are at least models.MinimumAge years old today.
+func adultValidation(fl validator.FieldLevel) bool {
+	birthdate, err := time.Parse(models.BirthdateLayout, fl.Field().String())
+	return err == nil && models.AgeOn(birthdate, time.Now()) >= models.MinimumAge
+}
+
+// ValidateSignup validates the SignupRequest struct, sharing the email regex validation with ValidateLogin.
+func ValidateSignup(signup models.SignupRequest) error {
+	return validate.Struct(signup)
+}
@@ -0,0 +1,17 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+)
+
+// validate is shared by every Validate* function so custom validations are registered once.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("email_regex", emailRegexValidation)
+	v.RegisterValidation("interest", interestValidation)
+	v.RegisterValidation("adult", adultValidation)
+
+	return v
+}
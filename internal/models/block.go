@@ -0,0 +1,7 @@
+package models
+
+type Block struct {
+	UserID        string `json:"UserID" dynamodbav:"UserID"`
+	BlockedUserID string `json:"blockedUserId" dynamodbav:"BlockedUserID"`
+	BlockedAt     int64  `json:"blockedAt" dynamodbav:"blockedAt"`
+}
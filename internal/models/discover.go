@@ -1,11 +1,70 @@
 package models
 
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	SortDistance = "distance"
+	SortRecent   = "recent"
+	SortAge      = "age"
+	// SortRelevance ranks users by the number of interests they share with the searcher, nearest first among equals.
+	SortRelevance = "relevance"
+)
+
+const (
+	UnitKilometers = "km"
+	UnitMiles      = "mi"
+)
+
+// Mean earth radius, the same value Elasticsearch uses for its geo distance calculations
+const earthRadiusKM = 6371.0088
+
+const kilometersPerMile = 1.609344
+
+// distanceBuckets are the upper bounds, in kilometers or miles, of the ranges distances are reported in.
+var distanceBuckets = []int{5, 10, 25, 50, 100}
+
+// travelGridSteps is how many steps of the grid travel mode origins are snapped to fit in a degree. A tenth of a degree
+// is about 11 km north to south.
+const travelGridSteps = 10
+
 type UserDetailsES struct {
-	UserID   string         `json:"UserID"`
-	Name     string         `json:"name"`
-	Gender   string         `json:"gender"`
-	Age      int            `json:"age"`
-	Location UserLocationES `json:"location"`
+	UserID       string         `json:"UserID"`
+	Name         string         `json:"name"`
+	Gender       string         `json:"gender"`
+	Birthdate    string         `json:"birthdate,omitempty"`
+	Location     UserLocationES `json:"location"`
+	LastActiveAt int64          `json:"lastActiveAt,omitempty"`
+	Bio          string         `json:"bio,omitempty"`
+	Interests    []string       `json:"interests,omitempty"`
+	Photos       []PhotoURLs    `json:"photos,omitempty"`
+	Preferences  `mapstructure:",squash"`
+}
+
+// PublicProfile is what other users are allowed to see about a user.
+type PublicProfile struct {
+	UserID    string      `json:"UserID"`
+	Name      string      `json:"name"`
+	Gender    string      `json:"gender"`
+	Age       int         `json:"age"`
+	Bio       string      `json:"bio,omitempty"`
+	Interests []string    `json:"interests,omitempty"`
+	Photos    []PhotoURLs `json:"photos,omitempty"`
+}
+
+func (u UserDetailsES) PublicProfile() PublicProfile {
+	return PublicProfile{
+		UserID:    u.UserID,
+		Name:      u.Name,
+		Gender:    u.Gender,
+		Age:       AgeFromBirthdate(u.Birthdate, time.Now()),
+		Bio:       u.Bio,
+		Interests: u.Interests,
+		Photos:    u.Photos,
+	}
 }
 
 type UserLocationES struct {
@@ -13,14 +72,134 @@ type UserLocationES struct {
 	Lon float64 `json:"lon"`
 }
 
+// DistanceKM returns the great-circle distance between two locations in kilometers, using the haversine formula.
+func DistanceKM(a, b UserLocationES) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+/*
+SnapToTravelGrid moves a travel mode origin to the nearest point of a coarse grid. Otherwise a searcher could measure
+a candidate's distance from as many points of their choosing as they like, and work out where the candidate lives.
+*/
+func SnapToTravelGrid(location UserLocationES) UserLocationES {
+	return UserLocationES{
+		Lat: math.Round(location.Lat*travelGridSteps) / travelGridSteps,
+		Lon: math.Round(location.Lon*travelGridSteps) / travelGridSteps,
+	}
+}
+
+// DiscoverUser is a discover result as returned to the client. It carries the distance instead of the coordinates, so exact positions are never exposed.
+type DiscoverUser struct {
+	UserID    string      `json:"UserID"`
+	Name      string      `json:"name"`
+	Gender    string      `json:"gender"`
+	Age       int         `json:"age"`
+	Distance  string      `json:"distance"`
+	Bio       string      `json:"bio,omitempty"`
+	Interests []string    `json:"interests,omitempty"`
+	Photos    []PhotoURLs `json:"photos,omitempty"`
+}
+
+/*
+DiscoverUser returns the user as seen from the given location, with the distance in the given unit reported as one of
+the ranges of distanceBuckets, such as "<5" or "10-25". An exact distance would pin the user down to a circle around
+the searcher's position.
+*/
+func (u UserDetailsES) DiscoverUser(from UserLocationES, unit string) DiscoverUser {
+	distance := DistanceKM(from, u.Location)
+	if unit == UnitMiles {
+		distance /= kilometersPerMile
+	}
+
+	return DiscoverUser{
+		UserID:    u.UserID,
+		Name:      u.Name,
+		Gender:    u.Gender,
+		Age:       AgeFromBirthdate(u.Birthdate, time.Now()),
+		Distance:  distanceBucket(distance),
+		Bio:       u.Bio,
+		Interests: u.Interests,
+		Photos:    u.Photos,
+	}
+}
+
+// distanceBucket returns the range of distanceBuckets the distance falls in.
+func distanceBucket(distance float64) string {
+	lower := 0
+	for _, upper := range distanceBuckets {
+		if distance < float64(upper) {
+			if lower == 0 {
+				return fmt.Sprintf("<%d", upper)
+			}
+			return fmt.Sprintf("%d-%d", lower, upper)
+		}
+		lower = upper
+	}
+	return fmt.Sprintf("%d+", lower)
+}
+
 type DiscoverFilters struct {
 	UserID      string
 	Gender      string `json:"gender,omitempty"`
 	MaxAge      int    `json:"maxAge,omitempty"`
 	MinAge      int    `json:"minAge,omitempty"`
 	MaxLocation int    `json:"maxLocation,omitempty"`
+	Unit        string `json:"unit,omitempty" validate:"omitempty,oneof=km mi"`
+	PageSize    int    `json:"pageSize,omitempty" validate:"omitempty,min=1,max=100"`
+	Sort        string `json:"sort,omitempty" validate:"omitempty,oneof=relevance distance recent age"`
+	Cursor      string `json:"cursor,omitempty"`
+	// Latitude and Longitude search from another location than the user's own for this request only ("travel mode").
+	// They are snapped to a coarse grid, see SnapToTravelGrid.
+	Latitude  *float64 `json:"latitude,omitempty" validate:"required_with=Longitude,omitnil,latitude"`
+	Longitude *float64 `json:"longitude,omitempty" validate:"required_with=Latitude,omitnil,longitude"`
+}
+
+/*
+WithDefaults fills in every filter the request left out from the user's saved settings. Filters the request sets
+always win, and paging and sorting are never defaulted.
+*/
+func (df DiscoverFilters) WithDefaults(settings DiscoverSettings) DiscoverFilters {
+	if df.Gender == "" {
+		df.Gender = settings.Gender
+	}
+	if df.MinAge == 0 {
+		df.MinAge = settings.MinAge
+	}
+	if df.MaxAge == 0 {
+		df.MaxAge = settings.MaxAge
+	}
+	if df.MaxLocation == 0 {
+		df.MaxLocation = settings.MaxLocation
+	}
+	if df.Unit == "" {
+		df.Unit = settings.Unit
+	}
+	return df
+}
+
+// DiscoverSettings are a user's saved default discover filters, stored with the user in DynamoDB.
+type DiscoverSettings struct {
+	Gender      string `json:"gender,omitempty" dynamodbav:"gender,omitempty" validate:"omitempty,oneof=male female nonbinary"`
+	MinAge      int    `json:"minAge,omitempty" dynamodbav:"minAge,omitempty" validate:"omitempty,min=18,max=120"`
+	MaxAge      int    `json:"maxAge,omitempty" dynamodbav:"maxAge,omitempty" validate:"omitempty,min=18,max=120,gtefield=MinAge"`
+	MaxLocation int    `json:"maxLocation,omitempty" dynamodbav:"maxLocation,omitempty" validate:"omitempty,min=1,max=20000"`
+	Unit        string `json:"unit,omitempty" dynamodbav:"unit,omitempty" validate:"omitempty,oneof=km mi"`
+}
+
+type DiscoverPage struct {
+	Users      []UserDetailsES
+	NextCursor string
+	Total      int
 }
 
 type DiscoverReturn struct {
-	UsersES []UserDetailsES `json:"users"`
+	Users      []DiscoverUser `json:"users"`
+	Unit       string         `json:"unit"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+	Total      int            `json:"total"`
 }
@@ -0,0 +1,30 @@
+package models
+
+// AuditActionAccountErased is the audit action recorded once all of a deleted account's data is erased.
+const AuditActionAccountErased = "account_erased"
+
+/*
+Tombstone marks a deleted account. It is written in the same transaction that deletes the user, and outlives the rest
+of their data so nothing can bring the user back. PendingSince is set until every trace of the user is erased, and
+PhotoKeys remembers the photo files to delete until then, since the user record listing them is already gone.
+*/
+type Tombstone struct {
+	UserID       string   `dynamodbav:"UserID"`
+	DeletedAt    int64    `dynamodbav:"deletedAt"`
+	PendingSince int64    `dynamodbav:"pendingSince,omitempty"`
+	PhotoKeys    []string `dynamodbav:"photoKeys,omitempty"`
+	ErasedAt     int64    `dynamodbav:"erasedAt,omitempty"`
+}
+
+// AuditEntry records an action taken on a user's account. It holds IDs only, so it can be kept after an erasure.
+type AuditEntry struct {
+	AuditID   string `dynamodbav:"AuditID"`
+	Action    string `dynamodbav:"action"`
+	SubjectID string `dynamodbav:"subjectId"`
+	ActorID   string `dynamodbav:"actorId"`
+	CreatedAt int64  `dynamodbav:"createdAt"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,44 @@
+package models
+
+const (
+	ExportStatusPending = "pending"
+	ExportStatusReady   = "ready"
+	ExportStatusFailed  = "failed"
+	ExportStatusExpired = "expired"
+)
+
+/*
+ExportJob is a request for a copy of a user's personal data. It stays pending until the exporter has built the archive,
+and NextAttemptAt is only set while it does, so the jobs still to build can be found through a sparse index. The same
+goes for ArchiveExpiresAt, which is only set while the archive is stored.
+*/
+type ExportJob struct {
+	UserID           string `json:"-" dynamodbav:"UserID"`
+	ExportID         string `json:"exportId" dynamodbav:"ExportID"`
+	Status           string `json:"status" dynamodbav:"status"`
+	RequestedAt      int64  `json:"requestedAt" dynamodbav:"requestedAt"`
+	CompletedAt      int64  `json:"completedAt,omitempty" dynamodbav:"completedAt,omitempty"`
+	ExpiresAt        int64  `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
+	DownloadURL      string `json:"downloadUrl,omitempty" dynamodbav:"-"`
+	Attempts         int    `json:"-" dynamodbav:"attempts,omitempty"`
+	NextAttemptAt    int64  `json:"-" dynamodbav:"nextAttemptAt,omitempty"`
+	ArchiveKey       string `json:"-" dynamodbav:"archiveKey,omitempty"`
+	ArchiveExpiresAt int64  `json:"-" dynamodbav:"archiveExpiresAt,omitempty"`
+}
+
+// ExportedProfile is everything stored about a user for a data export, apart from their password hash.
+type ExportedProfile struct {
+	UserProfile
+	Suspended         bool              `json:"suspended"`
+	LocationUpdatedAt int64             `json:"locationUpdatedAt,omitempty"`
+	DiscoverSettings  *DiscoverSettings `json:"discoverSettings,omitempty"`
+}
+
+func (u UserDetails) ExportedProfile() ExportedProfile {
+	return ExportedProfile{
+		UserProfile:       u.Profile(),
+		Suspended:         u.Suspended,
+		LocationUpdatedAt: u.LocationUpdatedAt,
+		DiscoverSettings:  u.DiscoverSettings,
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+// Interests is the curated taxonomy of interest tags users can pick from. Tags are stored and indexed as written here.
+var Interests = []string{
+	"art", "board-games", "books", "cats", "climbing", "coffee", "comedy", "cooking", "cycling", "dancing", "dogs",
+	"fashion", "film", "fitness", "gaming", "gardening", "hiking", "languages", "live-music", "meditation",
+	"museums", "music", "nightlife", "photography", "podcasts", "politics", "running", "science", "skiing",
+	"soccer", "surfing", "swimming", "technology", "theatre", "travel", "volunteering", "wine", "writing", "yoga",
+}
+
+var interestSet = func() map[string]bool {
+	set := make(map[string]bool, len(Interests))
+	for _, interest := range Interests {
+		set[interest] = true
+	}
+	return set
+}()
+
+// IsInterest reports whether tag is part of the interest taxonomy.
+func IsInterest(tag string) bool {
+	return interestSet[tag]
+}
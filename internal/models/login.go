@@ -6,5 +6,19 @@ type LoginCredentials struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+/*
+LoginThrottle counts the failed logins against one key, an email or a client IP, since the counter was last reset.
+An attempt is counted as soon as it starts and only taken back if it succeeds. Attempts refused while the key is
+locked are not counted. ExpiresAt is when the failures stop counting, and is also the table's TTL attribute.
+*/
+type LoginThrottle struct {
+	Key           string `dynamodbav:"ThrottleKey"`
+	Failures      int    `dynamodbav:"failures"`
+	LastFailureAt int64  `dynamodbav:"lastFailureAt"`
+	ExpiresAt     int64  `dynamodbav:"expiresAt"`
 }
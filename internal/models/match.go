@@ -0,0 +1,25 @@
+package models
+
+// Match is stored once per side of a match, so each user can list their own matches with a single Query.
+type Match struct {
+	UserID        string `json:"UserID" dynamodbav:"UserID"`
+	MatchID       string `json:"matchId" dynamodbav:"MatchID"`
+	MatchedUserID string `json:"matchedUserId" dynamodbav:"MatchedUserID"`
+	MatchedAt     int64  `json:"matchedAt" dynamodbav:"matchedAt"`
+}
+
+type MatchPage struct {
+	Matches    []Match
+	NextCursor string
+}
+
+type MatchView struct {
+	MatchID   string        `json:"matchId"`
+	MatchedAt int64         `json:"matchedAt"`
+	User      PublicProfile `json:"user"`
+}
+
+type MatchesResponse struct {
+	Matches    []MatchView `json:"matches"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
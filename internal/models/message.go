@@ -0,0 +1,24 @@
+package models
+
+// Message belongs to the conversation of a match. MessageID sorts in the order messages were sent.
+type Message struct {
+	MatchID   string `json:"matchId" dynamodbav:"MatchID"`
+	MessageID string `json:"messageId" dynamodbav:"MessageID"`
+	SenderID  string `json:"senderId" dynamodbav:"SenderID"`
+	Body      string `json:"body" dynamodbav:"body"`
+	SentAt    int64  `json:"sentAt" dynamodbav:"sentAt"`
+}
+
+type SendMessageRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+type MessagePage struct {
+	Messages   []Message
+	NextCursor string
+}
+
+type MessagesResponse struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor,omitempty"`
+}
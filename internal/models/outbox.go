@@ -0,0 +1,14 @@
+package models
+
+/*
+OutboxEntry records that a user changed in DynamoDB and has to be reindexed in Elasticsearch. It is written in the same
+transaction as the change, and there is at most one per user: later changes replace it with a new ChangeID, so the
+indexer can tell whether the user changed again while it was being reindexed.
+*/
+type OutboxEntry struct {
+	UserID   string `dynamodbav:"UserID"`
+	ChangeID string `dynamodbav:"changeId"`
+	// Attempts counts the failed attempts to reindex the user, and NextAttemptAt is when the next one is due.
+	Attempts      int   `dynamodbav:"attempts,omitempty"`
+	NextAttemptAt int64 `dynamodbav:"nextAttemptAt"`
+}
@@ -0,0 +1,41 @@
+package models
+
+// MaxPhotos is the number of photos a profile can hold.
+const MaxPhotos = 6
+
+// Photo is a profile photo as stored with the user. The first photo is the profile's main photo.
+type Photo struct {
+	PhotoID      string `json:"photoId" dynamodbav:"photoId"`
+	URL          string `json:"url" dynamodbav:"url"`
+	ThumbnailURL string `json:"thumbnailUrl" dynamodbav:"thumbnailUrl"`
+	Key          string `json:"-" dynamodbav:"key"`
+	ThumbnailKey string `json:"-" dynamodbav:"thumbnailKey"`
+	UploadedAt   int64  `json:"uploadedAt" dynamodbav:"uploadedAt"`
+}
+
+// PhotoURLs is the part of a photo that other users see, and that is indexed with the user in Elasticsearch.
+type PhotoURLs struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+func (p Photo) URLs() PhotoURLs {
+	return PhotoURLs{URL: p.URL, ThumbnailURL: p.ThumbnailURL}
+}
+
+// PhotoURLsOf returns the URLs of the given photos, in the same order.
+func PhotoURLsOf(photos []Photo) []PhotoURLs {
+	if len(photos) == 0 {
+		return nil
+	}
+	urls := make([]PhotoURLs, 0, len(photos))
+	for _, p := range photos {
+		urls = append(urls, p.URLs())
+	}
+	return urls
+}
+
+// PhotoOrderRequest is the body of PUT /me/photos/order. It lists every photo ID of the profile, in the new order.
+type PhotoOrderRequest struct {
+	PhotoIDs []string `json:"photoIds" validate:"required,max=6,unique,dive,required"`
+}
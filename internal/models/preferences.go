@@ -0,0 +1,8 @@
+package models
+
+// Preferences describe who a user wants to see in discover, and who they want to be shown to. Empty fields accept anyone.
+type Preferences struct {
+	InterestedIn    []string `json:"interestedIn,omitempty" dynamodbav:"interestedIn,omitempty" validate:"omitempty,max=3,dive,oneof=male female nonbinary"`
+	PreferredMinAge int      `json:"preferredMinAge,omitempty" dynamodbav:"preferredMinAge,omitempty" validate:"omitempty,min=18,max=120"`
+	PreferredMaxAge int      `json:"preferredMaxAge,omitempty" dynamodbav:"preferredMaxAge,omitempty" validate:"omitempty,min=18,max=120,gtefield=PreferredMinAge"`
+}
@@ -0,0 +1,53 @@
+package models
+
+/*
+ProfileUpdateRequest is the body of PATCH /me. Fields left out keep their current value. The bio, interests and
+preferences are cleared by sending an empty string, an empty list or an age of 0. Interests must be tags from the
+Interests taxonomy. The location has its own rate limited endpoint, see LocationUpdateRequest.
+*/
+type ProfileUpdateRequest struct {
+	Name            *string   `json:"name" validate:"omitnil,min=1,max=100"`
+	Gender          *string   `json:"gender" validate:"omitnil,oneof=male female nonbinary"`
+	Bio             *string   `json:"bio" validate:"omitnil,max=500"`
+	Interests       *[]string `json:"interests" validate:"omitnil,max=10,unique,dive,interest"`
+	InterestedIn    *[]string `json:"interestedIn" validate:"omitnil,max=3,dive,oneof=male female nonbinary"`
+	PreferredMinAge *int      `json:"preferredMinAge" validate:"omitnil,eq=0|min=18,max=120"`
+	PreferredMaxAge *int      `json:"preferredMaxAge" validate:"omitnil,eq=0|min=18,max=120"`
+}
+
+// ApplyTo copies every field set in the update onto the user.
+func (u ProfileUpdateRequest) ApplyTo(user *UserDetails) {
+	if u.Name != nil {
+		user.Name = *u.Name
+	}
+	if u.Gender != nil {
+		user.Gender = *u.Gender
+	}
+	if u.Bio != nil {
+		user.Bio = *u.Bio
+	}
+	if u.Interests != nil {
+		user.Interests = *u.Interests
+		if len(user.Interests) == 0 {
+			user.Interests = nil
+		}
+	}
+	if u.InterestedIn != nil {
+		user.InterestedIn = *u.InterestedIn
+		if len(user.InterestedIn) == 0 {
+			user.InterestedIn = nil
+		}
+	}
+	if u.PreferredMinAge != nil {
+		user.PreferredMinAge = *u.PreferredMinAge
+	}
+	if u.PreferredMaxAge != nil {
+		user.PreferredMaxAge = *u.PreferredMaxAge
+	}
+}
+
+// LocationUpdateRequest is the body of PUT /me/location.
+type LocationUpdateRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,latitude"`
+	Longitude *float64 `json:"longitude" validate:"required,longitude"`
+}
@@ -0,0 +1,39 @@
+package models
+
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+type Report struct {
+	ReportID       string `json:"reportId" dynamodbav:"ReportID"`
+	ReporterID     string `json:"reporterId" dynamodbav:"reporterId"`
+	ReportedUserID string `json:"reportedUserId" dynamodbav:"reportedUserId"`
+	Category       string `json:"category" dynamodbav:"category"`
+	Details        string `json:"details,omitempty" dynamodbav:"details,omitempty"`
+	Status         string `json:"status" dynamodbav:"status"`
+	CreatedAt      int64  `json:"createdAt" dynamodbav:"createdAt"`
+	Resolution     string `json:"resolution,omitempty" dynamodbav:"resolution,omitempty"`
+	ResolvedBy     string `json:"resolvedBy,omitempty" dynamodbav:"resolvedBy,omitempty"`
+	ResolvedAt     int64  `json:"resolvedAt,omitempty" dynamodbav:"resolvedAt,omitempty"`
+}
+
+type ReportRequest struct {
+	ReportedUserID string `json:"reportedUserId" validate:"required"`
+	Category       string `json:"category" validate:"required,oneof=spam harassment fake_profile inappropriate_content underage other"`
+	Details        string `json:"details" validate:"max=1000"`
+}
+
+type ResolveReportRequest struct {
+	Resolution string `json:"resolution" validate:"required,oneof=dismissed actioned"`
+}
+
+type ReportPage struct {
+	Reports    []Report
+	NextCursor string
+}
+
+type ReportsResponse struct {
+	Reports    []Report `json:"reports"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+type Session struct {
+	SessionID        string `json:"sessionId" dynamodbav:"SessionID"`
+	UserID           string `json:"UserID" dynamodbav:"UserID"`
+	RefreshTokenHash string `json:"-" dynamodbav:"refreshTokenHash"`
+	CreatedAt        int64  `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt        int64  `json:"expiresAt" dynamodbav:"expiresAt"`
+	Revoked          bool   `json:"revoked" dynamodbav:"revoked"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
@@ -0,0 +1,12 @@
+package models
+
+type SignupRequest struct {
+	Email     string   `json:"email" validate:"required,email_regex,max=254"`
+	Password  string   `json:"password" validate:"required,min=8,max=72"`
+	Name      string   `json:"name" validate:"required,max=100"`
+	Gender    string   `json:"gender" validate:"required,oneof=male female nonbinary"`
+	Birthdate string   `json:"birthdate" validate:"required,datetime=2006-01-02,adult"`
+	Latitude  *float64 `json:"latitude" validate:"required,latitude"`
+	Longitude *float64 `json:"longitude" validate:"required,longitude"`
+	Preferences
+}
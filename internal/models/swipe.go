@@ -5,7 +5,7 @@ type Swipe struct {
 	SwipedUserID string `json:"SwipedUserID" dynamodbav:"SwipedUserID"`
 	Preference   bool   `json:"preference" dynamodbav:"preference"`
 	Matched      bool   `json:"matched" dynamodbav:"matched"`
-	MatchID      string `json:"matchId,omitempty" dynamodbav:"matchId"`
+	MatchID      string `json:"matchId,omitempty" dynamodbav:"matchId,omitempty"`
 }
 
 type SwipeResponse struct {
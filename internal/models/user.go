@@ -1,17 +1,100 @@
 package models
 
+import "time"
+
+const BirthdateLayout = "2006-01-02"
+
+// MinimumAge is the youngest a user can be to sign up.
+const MinimumAge = 18
+
+const RoleAdmin = "admin"
+
 type UserDetails struct {
 	UserID         string `json:"UserID" dynamodbav:"UserID"`
 	Email          string `json:"email" dynamodbav:"email"`
-	Password       string `json:"password" dynamodbav:"password"`
+	Password       string `json:"password,omitempty" dynamodbav:"-"`
 	PasswordHashed string `json:"password_hashed" dynamodbav:"password_hashed"`
 	Name           string `json:"name" dynamodbav:"name"`
 	Gender         string `json:"gender" dynamodbav:"gender"`
-	Age            int    `json:"age" dynamodbav:"age"`
+	// Birthdate is stored instead of an age, which would go stale. Ages are worked out from it when needed.
+	Birthdate string `json:"birthdate,omitempty" dynamodbav:"birthdate,omitempty"`
+	Role      string `json:"-" dynamodbav:"role,omitempty"`
+	Suspended bool   `json:"-" dynamodbav:"suspended,omitempty"`
+	// LocationUpdatedAt is when the location was last changed through PUT /me/location, used to rate limit changes.
+	LocationUpdatedAt int64    `json:"-" dynamodbav:"locationUpdatedAt,omitempty"`
+	Bio               string   `json:"bio,omitempty" dynamodbav:"bio,omitempty"`
+	Interests         []string `json:"interests,omitempty" dynamodbav:"interests,omitempty"`
+	Photos            []Photo  `json:"photos,omitempty" dynamodbav:"photos,omitempty"`
+	// PhotosVersion is bumped on every change to Photos, so concurrent changes cannot overwrite each other.
+	PhotosVersion int `json:"-" dynamodbav:"photosVersion,omitempty"`
+	// DiscoverSettings is nil until the user saves their discover settings.
+	DiscoverSettings *DiscoverSettings `json:"-" dynamodbav:"discoverSettings,omitempty"`
 	Userlocation
+	Preferences
 }
 
 type Userlocation struct {
 	Latitude  float64 `json:"latitude" dynamodbav:"latitude"`
 	Longitude float64 `json:"longitude" dynamodbav:"longitude"`
 }
+
+// UserProfile is the view of UserDetails returned to the user it belongs to, without any credentials.
+type UserProfile struct {
+	UserID    string   `json:"UserID"`
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	Gender    string   `json:"gender"`
+	Age       int      `json:"age"`
+	Birthdate string   `json:"birthdate,omitempty"`
+	Bio       string   `json:"bio,omitempty"`
+	Interests []string `json:"interests,omitempty"`
+	Photos    []Photo  `json:"photos,omitempty"`
+	Userlocation
+	Preferences
+}
+
+func (u UserDetails) Profile() UserProfile {
+	return UserProfile{
+		UserID:       u.UserID,
+		Email:        u.Email,
+		Name:         u.Name,
+		Gender:       u.Gender,
+		Age:          AgeFromBirthdate(u.Birthdate, time.Now()),
+		Birthdate:    u.Birthdate,
+		Bio:          u.Bio,
+		Interests:    u.Interests,
+		Photos:       u.Photos,
+		Userlocation: u.Userlocation,
+		Preferences:  u.Preferences,
+	}
+}
+
+func (u UserDetails) PublicProfile() PublicProfile {
+	return PublicProfile{
+		UserID:    u.UserID,
+		Name:      u.Name,
+		Gender:    u.Gender,
+		Age:       AgeFromBirthdate(u.Birthdate, time.Now()),
+		Bio:       u.Bio,
+		Interests: u.Interests,
+		Photos:    PhotoURLsOf(u.Photos),
+	}
+}
+
+// AgeOn returns the age in whole years of someone born on birthdate at the given moment.
+func AgeOn(birthdate, now time.Time) int {
+	age := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		age--
+	}
+	return age
+}
+
+// AgeFromBirthdate returns the age on the given moment for a birthdate in BirthdateLayout, or 0 if it cannot be parsed.
+func AgeFromBirthdate(birthdate string, now time.Time) int {
+	parsed, err := time.Parse(BirthdateLayout, birthdate)
+	if err != nil {
+		return 0
+	}
+	return AgeOn(parsed, now)
+}
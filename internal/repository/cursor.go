@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// encodeCursor turns a DynamoDB LastEvaluatedKey into an opaque string that can be handed to clients.
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	var values map[string]any
+	if err := dynamodbattribute.UnmarshalMap(key, &values); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor reverses encodeCursor, returning nil for an empty cursor and ErrInvalidCursor for anything unreadable.
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var values map[string]any
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	key, err := dynamodbattribute.MarshalMap(values)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return key, nil
+}
+
+// searchCursor is the position of the last hit of an Elasticsearch page, together with the sort it belongs to.
+type searchCursor struct {
+	Sort        string `json:"s"`
+	SearchAfter []any  `json:"a"`
+}
+
+// encodeSearchCursor turns the sort values of the last hit of a page into an opaque string that can be handed to clients.
+func encodeSearchCursor(sort string, searchAfter []any) (string, error) {
+	data, err := json.Marshal(searchCursor{Sort: sort, SearchAfter: searchAfter})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+/*
+decodeSearchCursor reverses encodeSearchCursor, returning nil for an empty cursor. ErrInvalidCursor is returned for
+anything unreadable, and for a cursor taken from a page with a different sort, whose values would not line up.
+*/
+func decodeSearchCursor(cursor, sort string) ([]any, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	// Numbers are kept as written, so large sort values such as timestamps survive the round trip exactly
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var sc searchCursor
+	if err = decoder.Decode(&sc); err != nil || sc.Sort != sort || len(sc.SearchAfter) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return sc.SearchAfter, nil
+}
@@ -1,17 +1,25 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
-	"log"
 	"quick-match/internal/models"
+	"time"
 )
 
 const usersTable = "quickmatch_users"
 const swipesTable = "quickmatch_swipes"
+const sessionsTable = "quickmatch_sessions"
+const matchesTable = "quickmatch_matches"
+const messagesTable = "quickmatch_messages"
+const blocksTable = "quickmatch_blocks"
+const reportsTable = "quickmatch_reports"
+const emailsTable = "quickmatch_emails"
 
 type DynamoDBRepository struct {
 	Client *dynamodb.DynamoDB
@@ -23,19 +31,60 @@ func NewDynamoDBRepository(client *dynamodb.DynamoDB) DynamoDBRepository {
 	}
 }
 
+/*
+InsertUser stores a new user, claims their email and records the user in the outbox for indexing, in one transaction.
+The emails table holds one item per email, so its condition guarantees that no two users share an email, which a GSI
+cannot enforce. ErrEmailTaken is returned if the email is already claimed. The insert also fails for the ID of a deleted
+account, which keeps its tombstone.
+*/
 func (repo *DynamoDBRepository) InsertUser(user models.UserDetails) error {
 	av, err := dynamodbattribute.MarshalMap(user)
 	if err != nil {
 		fmt.Printf("Failed to marshal user: %v\n", err)
 		return err
 	}
-
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(usersTable),
+	entry, err := outboxPut(user.UserID)
+	if err != nil {
+		return err
 	}
 
-	_, err = repo.Client.PutItem(input)
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(emailsTable),
+					Item: map[string]*dynamodb.AttributeValue{
+						"email":  {S: aws.String(user.Email)},
+						"UserID": {S: aws.String(user.UserID)},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(email)"),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(usersTable),
+					Item:                av,
+					ConditionExpression: aws.String("attribute_not_exists(UserID)"),
+				},
+			},
+			entry,
+			{
+				// The ID of a deleted account is never given out again, so an erased user cannot be inserted back
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName: aws.String(tombstonesTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID": {S: aws.String(user.UserID)},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(UserID)"),
+				},
+			},
+		},
+	})
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+		aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return ErrEmailTaken
+	}
 	if err != nil {
 		fmt.Printf("Failed to insert user into DynamoDB: %v\n", err)
 		return err
@@ -44,21 +93,27 @@ func (repo *DynamoDBRepository) InsertUser(user models.UserDetails) error {
 	return nil
 }
 
+/*
+GetUserByEmail returns the user with the given email, or nil if there is none. It queries the `EmailIndex` GSI of the
+users table, which is eventually consistent, so a user created a moment ago may not be found yet.
+*/
 func (repo *DynamoDBRepository) GetUserByEmail(email string) (*models.UserDetails, error) {
-	f := expression.Name("email").Equal(expression.Value(email))
-	e, err := expression.NewBuilder().WithFilter(f).Build()
+	keyCond := expression.Key("email").Equal(expression.Value(email))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
 	if err != nil {
 		return nil, err
 	}
 
-	input := &dynamodb.ScanInput{
-		ExpressionAttributeNames:  e.Names(),
-		ExpressionAttributeValues: e.Values(),
-		FilterExpression:          e.Filter(),
+	input := &dynamodb.QueryInput{
 		TableName:                 aws.String(usersTable),
+		IndexName:                 aws.String("EmailIndex"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		Limit:                     aws.Int64(1),
 	}
 
-	result, err := repo.Client.Scan(input)
+	result, err := repo.Client.Query(input)
 	if err != nil {
 		return nil, err
 	}
@@ -68,14 +123,455 @@ func (repo *DynamoDBRepository) GetUserByEmail(email string) (*models.UserDetail
 	}
 
 	var user models.UserDetails
-	err = dynamodbattribute.UnmarshalMap(result.Items[0], &user)
+	if err = dynamodbattribute.UnmarshalMap(result.Items[0], &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetUserByID returns the user with the given ID, or nil if there is none.
+func (repo *DynamoDBRepository) GetUserByID(userID string) (*models.UserDetails, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := repo.Client.GetItem(input)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var user models.UserDetails
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &user); err != nil {
+		return nil, err
+	}
+
 	return &user, nil
 }
 
+/*
+ScanUserAges calls visit with the ID of every user, together with the age stored for users created before birthdates
+replaced ages, or 0 if there is none. Scanning stops at the first error returned by visit.
+*/
+func (repo *DynamoDBRepository) ScanUserAges(visit func(userID string, age int) error) error {
+	proj := expression.NamesList(expression.Name("UserID"), expression.Name("age"))
+	expr, err := expression.NewBuilder().WithProjection(proj).Build()
+	if err != nil {
+		return err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                aws.String(usersTable),
+		ExpressionAttributeNames: expr.Names(),
+		ProjectionExpression:     expr.Projection(),
+	}
+
+	var visitErr error
+	err = repo.Client.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			var legacy struct {
+				UserID string `dynamodbav:"UserID"`
+				Age    int    `dynamodbav:"age"`
+			}
+			if visitErr = dynamodbattribute.UnmarshalMap(item, &legacy); visitErr != nil {
+				return false
+			}
+			if visitErr = visit(legacy.UserID, legacy.Age); visitErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return visitErr
+}
+
+// ScanUsers calls visit with every user in DynamoDB. Scanning stops at the first error returned by visit.
+func (repo *DynamoDBRepository) ScanUsers(visit func(user models.UserDetails) error) error {
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(usersTable),
+	}
+
+	var visitErr error
+	err := repo.Client.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			var user models.UserDetails
+			if visitErr = dynamodbattribute.UnmarshalMap(item, &user); visitErr != nil {
+				return false
+			}
+			if visitErr = visit(user); visitErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return visitErr
+}
+
+/*
+MigrateAgeToBirthdate replaces the age stored for a user created before birthdates replaced ages. Only whole years are
+known, so the birthdate is put in the middle of the possible range: age years and six months before now. A birthdate
+the user already has is kept, and the age is removed either way. ErrUserNotFound is returned if the user does not exist.
+*/
+func (repo *DynamoDBRepository) MigrateAgeToBirthdate(userID string, age int, now time.Time) error {
+	birthdate := now.AddDate(-age, -6, 0).Format(models.BirthdateLayout)
+	update := expression.Set(expression.Name("birthdate"), expression.IfNotExists(expression.Name("birthdate"), expression.Value(birthdate))).
+		Remove(expression.Name("age"))
+	cond := expression.AttributeExists(expression.Name("UserID"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = repo.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+/*
+ClaimEmail writes the email claim of a user created before emails were claimed on insert. A claim the user already
+holds is left as it is. The claim is only written while the user still exists with that email, so it cannot outlive an
+account erased in the meantime. ErrEmailTaken is returned if another user holds the claim, and ErrUserNotFound if the
+user no longer exists with that email.
+*/
+func (repo *DynamoDBRepository) ClaimEmail(userID, email string) error {
+	claimCond := expression.AttributeNotExists(expression.Name("email")).
+		Or(expression.Name("UserID").Equal(expression.Value(userID)))
+	claimExpr, err := expression.NewBuilder().WithCondition(claimCond).Build()
+	if err != nil {
+		return err
+	}
+	userExpr, err := expression.NewBuilder().
+		WithCondition(expression.Name("email").Equal(expression.Value(email))).
+		Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(emailsTable),
+					Item: map[string]*dynamodb.AttributeValue{
+						"email":  {S: aws.String(email)},
+						"UserID": {S: aws.String(userID)},
+					},
+					ConditionExpression:       claimExpr.Condition(),
+					ExpressionAttributeNames:  claimExpr.Names(),
+					ExpressionAttributeValues: claimExpr.Values(),
+				},
+			},
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName: aws.String(usersTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID": {S: aws.String(userID)},
+					},
+					ConditionExpression:       userExpr.Condition(),
+					ExpressionAttributeNames:  userExpr.Names(),
+					ExpressionAttributeValues: userExpr.Values(),
+				},
+			},
+		},
+	})
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 1 {
+		if aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			return ErrEmailTaken
+		}
+		if aws.StringValue(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+			return ErrUserNotFound
+		}
+	}
+	return err
+}
+
+/*
+SuspendUser flags an existing user as suspended, and records the change in the outbox so the user is removed from
+Elasticsearch. ErrUserNotFound is returned if the user does not exist.
+*/
+func (repo *DynamoDBRepository) SuspendUser(userID string, suspendedAt int64) error {
+	update := expression.Set(expression.Name("suspended"), expression.Value(true)).
+		Set(expression.Name("suspendedAt"), expression.Value(suspendedAt))
+	cond := expression.AttributeExists(expression.Name("UserID"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.Update{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	err = repo.updateUser(userID, input)
+	if isConditionalCheckFailed(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+/*
+UpdatePasswordHash replaces the user's password hash with newHash, as long as it is still oldHash. If the hash changed
+in the meantime, or the user no longer exists, nothing is written and no error is returned.
+*/
+func (repo *DynamoDBRepository) UpdatePasswordHash(userID, oldHash, newHash string) error {
+	update := expression.Set(expression.Name("password_hashed"), expression.Value(newHash))
+	cond := expression.Name("password_hashed").Equal(expression.Value(oldHash))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+/*
+UpdateProfile writes the editable profile fields of an existing user: name, gender, bio, interests and preferences.
+A bio, interests and preferences that are unset are removed. Credentials, role, suspension and location are never touched, so a profile
+update cannot undo a concurrent suspension or location change. The change is recorded in the outbox for reindexing.
+ErrUserNotFound is returned if the user does not exist.
+*/
+func (repo *DynamoDBRepository) UpdateProfile(user models.UserDetails) error {
+	update := expression.Set(expression.Name("name"), expression.Value(user.Name)).
+		Set(expression.Name("gender"), expression.Value(user.Gender))
+
+	if user.Bio != "" {
+		update = update.Set(expression.Name("bio"), expression.Value(user.Bio))
+	} else {
+		update = update.Remove(expression.Name("bio"))
+	}
+	if len(user.Interests) > 0 {
+		update = update.Set(expression.Name("interests"), expression.Value(user.Interests))
+	} else {
+		update = update.Remove(expression.Name("interests"))
+	}
+	if len(user.InterestedIn) > 0 {
+		update = update.Set(expression.Name("interestedIn"), expression.Value(user.InterestedIn))
+	} else {
+		update = update.Remove(expression.Name("interestedIn"))
+	}
+	if user.PreferredMinAge > 0 {
+		update = update.Set(expression.Name("preferredMinAge"), expression.Value(user.PreferredMinAge))
+	} else {
+		update = update.Remove(expression.Name("preferredMinAge"))
+	}
+	if user.PreferredMaxAge > 0 {
+		update = update.Set(expression.Name("preferredMaxAge"), expression.Value(user.PreferredMaxAge))
+	} else {
+		update = update.Remove(expression.Name("preferredMaxAge"))
+	}
+	cond := expression.AttributeExists(expression.Name("UserID"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.Update{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(user.UserID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	err = repo.updateUser(user.UserID, input)
+	if isConditionalCheckFailed(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+/*
+UpdateLocation moves an existing user to a new location. The change is refused with a LocationRateLimitedError if the
+location was already changed less than minInterval before updatedAt, holding the time of that change as stored when
+the update was refused. ErrUserNotFound is returned if the user does not exist. The change is recorded in the outbox
+for reindexing.
+*/
+func (repo *DynamoDBRepository) UpdateLocation(userID string, location models.Userlocation, updatedAt int64, minInterval time.Duration) error {
+	update := expression.Set(expression.Name("latitude"), expression.Value(location.Latitude)).
+		Set(expression.Name("longitude"), expression.Value(location.Longitude)).
+		Set(expression.Name("locationUpdatedAt"), expression.Value(updatedAt))
+	cutoff := updatedAt - int64(minInterval.Seconds())
+	cond := expression.AttributeExists(expression.Name("UserID")).And(
+		expression.Or(
+			expression.AttributeNotExists(expression.Name("locationUpdatedAt")),
+			expression.Name("locationUpdatedAt").LessThanEqual(expression.Value(cutoff)),
+		),
+	)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.Update{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+		// The stored item tells a missing user apart from a rate limited one, and when the limit ends
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+	}
+
+	err = repo.updateUser(userID, input)
+	if !isConditionalCheckFailed(err) {
+		return err
+	}
+
+	// The update is the first item of the transaction, see updateUser
+	var canceled *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceled) || len(canceled.CancellationReasons) == 0 || canceled.CancellationReasons[0] == nil ||
+		len(canceled.CancellationReasons[0].Item) == 0 {
+		return ErrUserNotFound
+	}
+	var current struct {
+		LocationUpdatedAt int64 `dynamodbav:"locationUpdatedAt"`
+	}
+	if err = dynamodbattribute.UnmarshalMap(canceled.CancellationReasons[0].Item, &current); err != nil {
+		return err
+	}
+	return &LocationRateLimitedError{LocationUpdatedAt: current.LocationUpdatedAt}
+}
+
+/*
+SavePhotos replaces the photos of an existing user. version is the PhotosVersion the photos were read at: the write is
+refused with ErrPhotosChanged if the photos changed since then, or if the user does not exist. The change is recorded
+in the outbox for reindexing.
+*/
+func (repo *DynamoDBRepository) SavePhotos(userID string, photos []models.Photo, version int) error {
+	update := expression.Set(expression.Name("photos"), expression.Value(photos))
+	if len(photos) == 0 {
+		update = expression.Remove(expression.Name("photos"))
+	}
+	update = update.Set(expression.Name("photosVersion"), expression.Value(version+1))
+
+	versionCond := expression.Name("photosVersion").Equal(expression.Value(version))
+	if version == 0 {
+		versionCond = expression.AttributeNotExists(expression.Name("photosVersion"))
+	}
+	cond := expression.AttributeExists(expression.Name("UserID")).And(versionCond)
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.Update{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	err = repo.updateUser(userID, input)
+	if isConditionalCheckFailed(err) {
+		return ErrPhotosChanged
+	}
+	return err
+}
+
+/*
+UpdateDiscoverSettings replaces the saved discover settings of an existing user. Saving empty settings removes them.
+ErrUserNotFound is returned if the user does not exist.
+*/
+func (repo *DynamoDBRepository) UpdateDiscoverSettings(userID string, settings models.DiscoverSettings) error {
+	update := expression.Set(expression.Name("discoverSettings"), expression.Value(settings))
+	if settings == (models.DiscoverSettings{}) {
+		update = expression.Remove(expression.Name("discoverSettings"))
+	}
+	cond := expression.AttributeExists(expression.Name("UserID"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(usersTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(userID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = repo.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+/*
+InsertSwipeRecord stores the current user's swipe on another user, replacing any earlier swipe between them.
+
+A swipe that already belongs to a match is never overwritten, since that would leave the other user's side of the
+match dangling. In that case ErrAlreadyMatched is returned.
+*/
 func (repo *DynamoDBRepository) InsertSwipeRecord(swipe models.Swipe) error {
 	av, err := dynamodbattribute.MarshalMap(swipe)
 	if err != nil {
@@ -83,84 +579,171 @@ func (repo *DynamoDBRepository) InsertSwipeRecord(swipe models.Swipe) error {
 	}
 
 	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(swipesTable),
+		Item:                      av,
+		TableName:                 aws.String(swipesTable),
+		ConditionExpression:       aws.String("attribute_not_exists(matched) OR matched = :false"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":false": {BOOL: aws.Bool(false)}},
 	}
 
 	_, err = repo.Client.PutItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrAlreadyMatched
+	}
 	return err
 }
 
 /*
-CheckSwipeMatch queries the DynamoDB to check if a mutual "like" exists between two users, indicating a match.
+MatchMutualLikes atomically turns two likes into a match, if both users have liked each other.
 
-This method performs a query using a Global Secondary Index (GSI) to efficiently find if the user identified by
-`swipedUserID` has swiped right (liked) on the user identified by `currentUserID`. It specifically looks for records
-where the `SwipedUserID` equals `currentUserID` and `UserID` equals `swipedUserID`, indicating that the swiped user
-has previously swiped on the current user.
+It must be called after the current user's like has been stored with InsertSwipeRecord. A single DynamoDB transaction
+then marks both swipe rows as matched with `matchID` and writes the match record for both users. The transaction is
+conditional on both rows being likes that are not matched yet, so it only succeeds when the other user's like already
+exists, and only one of two concurrent callers can ever succeed.
 
-The method constructs a key condition expression to query the `swipesTable` using the `SwipedUserIndex` GSI. It
-only retrieves the `preference` attribute of the swipe record to determine if the swiped user has liked the current
-user. If a swipe record with `preference` set to true is found, the function returns true, indicating a match. If no
-such record is found or if the query does not return any items, it assumes that no swipe has occurred yet and returns
-false. Errors during the query execution or result unmarshalling are returned to the caller.
+Because each user stores their like before trying the transaction, whichever of two simultaneous likes runs its
+transaction last is guaranteed to see both likes, so a mutual like is never missed. The caller that loses the race finds
+its own row already matched and returns the winner's MatchID, so both users end up with exactly one MatchID.
 
-Parameters:
-- swipedUserID: The ID of the user who is being checked to see if they have liked the current user.
-- currentUserID: The ID of the current user performing the check.
+Transactions cancelled by a conflicting in-flight transaction are retried a few times before falling back to reading
+the current user's swipe row.
 
 Returns:
-- A boolean indicating whether a mutual like exists (true if there is a match, false otherwise).
-- An error if the query fails to execute or if there is an issue unmarshalling the query result.
+- The MatchID of the match, or an empty string if the likes are not mutual.
+- Whether this call created the match, as opposed to finding one created by the other user.
+- An error if the transaction or the read fails.
 */
-func (repo *DynamoDBRepository) CheckSwipeMatch(swipedUserID, currentUserID string) (bool, error) {
-	// Using GSI to efficiently query swipes where swipedUserID has swiped on currentUserID
-	keyCond := expression.Key("SwipedUserID").Equal(expression.Value(currentUserID)).
-		And(expression.Key("UserID").Equal(expression.Value(swipedUserID)))
-
-	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+func (repo *DynamoDBRepository) MatchMutualLikes(userID, swipedUserID, matchID string, matchedAt int64) (string, bool, error) {
+	items, err := matchTransactionItems(userID, swipedUserID, matchID, matchedAt)
 	if err != nil {
-		return false, err
+		return "", false, err
 	}
 
-	queryInput := &dynamodb.QueryInput{
-		TableName:                 aws.String(swipesTable),
-		IndexName:                 aws.String("SwipedUserIndex"), // Using the GSI
-		ExpressionAttributeNames:  expr.Names(),
-		ExpressionAttributeValues: expr.Values(),
-		KeyConditionExpression:    expr.KeyCondition(),
-		ProjectionExpression:      aws.String("preference"), // Assuming we're interested in the Preference attribute
+	for attempt := 0; attempt < 3; attempt++ {
+		_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if err == nil {
+			return matchID, true, nil
+		}
+
+		var canceled *dynamodb.TransactionCanceledException
+		if !errors.As(err, &canceled) {
+			return "", false, err
+		}
+		if !hasCancellationCode(canceled, "TransactionConflict") {
+			break
+		}
+		time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
 	}
 
-	result, err := repo.Client.Query(queryInput)
-	if len(result.Items) == 0 {
-		log.Println("No existing swipe record found for the swiped user on current user. Assuming no swipe yet.")
-		return false, nil
+	// The likes are not mutual, or the other user's request matched them first
+	own, err := repo.getSwipe(userID, swipedUserID)
+	if err != nil {
+		return "", false, err
+	}
+	if own != nil && own.Matched {
+		return own.MatchID, false, nil
 	}
+
+	return "", false, nil
+}
+
+func matchTransactionItems(userID, swipedUserID, matchID string, matchedAt int64) ([]*dynamodb.TransactWriteItem, error) {
+	update := expression.Set(expression.Name("matched"), expression.Value(true)).
+		Set(expression.Name("matchId"), expression.Value(matchID))
+	cond := expression.Name("preference").Equal(expression.Value(true)).
+		And(expression.Or(
+			expression.AttributeNotExists(expression.Name("matched")),
+			expression.Name("matched").Equal(expression.Value(false)),
+		))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	// Checking if there is a swipe record with Preference = true
-	for _, item := range result.Items {
-		var swipe models.Swipe
-		err = dynamodbattribute.UnmarshalMap(item, &swipe)
+	var items []*dynamodb.TransactWriteItem
+	for _, pair := range [][2]string{{userID, swipedUserID}, {swipedUserID, userID}} {
+		items = append(items, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(swipesTable),
+				Key: map[string]*dynamodb.AttributeValue{
+					"UserID":       {S: aws.String(pair[0])},
+					"SwipedUserID": {S: aws.String(pair[1])},
+				},
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+				ConditionExpression:       expr.Condition(),
+			},
+		})
+	}
+
+	for _, m := range []models.Match{
+		{UserID: userID, MatchID: matchID, MatchedUserID: swipedUserID, MatchedAt: matchedAt},
+		{UserID: swipedUserID, MatchID: matchID, MatchedUserID: userID, MatchedAt: matchedAt},
+	} {
+		av, err := dynamodbattribute.MarshalMap(m)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
+		items = append(items, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(matchesTable),
+				Item:                av,
+				ConditionExpression: aws.String("attribute_not_exists(MatchID)"),
+			},
+		})
+	}
 
-		if swipe.Preference {
-			return true, nil // Found a match
-		}
+	return items, nil
+}
+
+func (repo *DynamoDBRepository) getSwipe(userID, swipedUserID string) (*models.Swipe, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(swipesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID":       {S: aws.String(userID)},
+			"SwipedUserID": {S: aws.String(swipedUserID)},
+		},
+		ConsistentRead: aws.Bool(true),
 	}
 
-	return false, nil
+	result, err := repo.Client.GetItem(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var swipe models.Swipe
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &swipe); err != nil {
+		return nil, err
+	}
+
+	return &swipe, nil
+}
+
+func hasCancellationCode(canceled *dynamodb.TransactionCanceledException, code string) bool {
+	for _, reason := range canceled.CancellationReasons {
+		if reason != nil && aws.StringValue(reason.Code) == code {
+			return true
+		}
+	}
+	return false
 }
 
+/*
+GetSwipedUserIDs returns the IDs of every user that userID has swiped on. The swipe history is read page by page until
+DynamoDB reports no LastEvaluatedKey, so heavy swipers with more than 1MB of swipes are fully covered.
+*/
 func (repo *DynamoDBRepository) GetSwipedUserIDs(userID string) ([]string, error) {
 	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	proj := expression.NamesList(expression.Name("SwipedUserID"))
 
-	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
 	if err != nil {
 		return nil, err
 	}
@@ -170,23 +753,677 @@ func (repo *DynamoDBRepository) GetSwipedUserIDs(userID string) ([]string, error
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		KeyConditionExpression:    expr.KeyCondition(),
-		ProjectionExpression:      aws.String("SwipedUserID"),
+		ProjectionExpression:      expr.Projection(),
+		// Rebuilding a swipe set relies on seeing every swipe stored before the read
+		ConsistentRead: aws.Bool(true),
 	}
 
-	result, err := repo.Client.Query(queryInput)
+	var swipedUserIDs []string
+	var unmarshalErr error
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			var swipe models.Swipe
+			if unmarshalErr = dynamodbattribute.UnmarshalMap(item, &swipe); unmarshalErr != nil {
+				return false
+			}
+			swipedUserIDs = append(swipedUserIDs, swipe.SwipedUserID)
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
 
-	var swipedUserIDs []string
-	for _, item := range result.Items {
-		var swipe models.Swipe
-		err = dynamodbattribute.UnmarshalMap(item, &swipe)
-		if err != nil {
+	return swipedUserIDs, nil
+}
+
+// ListSwipes returns every swipe userID has made, reading all pages of their swipe history.
+func (repo *DynamoDBRepository) ListSwipes(userID string) ([]models.Swipe, error) {
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(swipesTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+	}
+
+	var swipes []models.Swipe
+	var unmarshalErr error
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageSwipes []models.Swipe
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageSwipes); unmarshalErr != nil {
+			return false
+		}
+		swipes = append(swipes, pageSwipes...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+
+	return swipes, nil
+}
+
+func (repo *DynamoDBRepository) CreateSession(session models.Session) error {
+	av, err := dynamodbattribute.MarshalMap(session)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           aws.String(sessionsTable),
+		ConditionExpression: aws.String("attribute_not_exists(SessionID)"),
+	}
+
+	_, err = repo.Client.PutItem(input)
+	return err
+}
+
+func (repo *DynamoDBRepository) GetSession(sessionID string) (*models.Session, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(sessionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SessionID": {S: aws.String(sessionID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := repo.Client.GetItem(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var session models.Session
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+/*
+RotateSession replaces the refresh token hash of a session and extends its expiry.
+
+The update is conditional on the stored hash still being `oldHash` and the session not being revoked, so two
+concurrent refreshes with the same token cannot both succeed. When the condition fails ErrSessionRotated is returned
+and the caller should treat the presented refresh token as no longer valid.
+*/
+func (repo *DynamoDBRepository) RotateSession(sessionID, oldHash, newHash string, expiresAt int64) error {
+	update := expression.Set(expression.Name("refreshTokenHash"), expression.Value(newHash)).
+		Set(expression.Name("expiresAt"), expression.Value(expiresAt))
+	cond := expression.Name("refreshTokenHash").Equal(expression.Value(oldHash)).
+		And(expression.Name("revoked").Equal(expression.Value(false)))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(sessionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SessionID": {S: aws.String(sessionID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = repo.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrSessionRotated
+	}
+	return err
+}
+
+func (repo *DynamoDBRepository) RevokeSession(sessionID string) error {
+	update := expression.Set(expression.Name("revoked"), expression.Value(true))
+	cond := expression.AttributeExists(expression.Name("SessionID"))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(sessionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SessionID": {S: aws.String(sessionID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = repo.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		// Nothing to revoke
+		return nil
+	}
+	return err
+}
+
+// RevokeUserSessions revokes every session of the user, found through the `UserIndex` GSI of the sessions table.
+func (repo *DynamoDBRepository) RevokeUserSessions(userID string) error {
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	proj := expression.NamesList(expression.Name("SessionID"))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
+	if err != nil {
+		return err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(sessionsTable),
+		IndexName:                 aws.String("UserIndex"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	var sessionIDs []string
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			if v, ok := item["SessionID"]; ok && v.S != nil {
+				sessionIDs = append(sessionIDs, *v.S)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		if err = repo.RevokeSession(sessionID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ListMatches returns a page of the user's matches, most recent first.
+
+The query runs against the `MatchedAtIndex` local secondary index so results are ordered by match time. The cursor is
+the opaque form of DynamoDB's LastEvaluatedKey and is empty once there are no more pages.
+*/
+func (repo *DynamoDBRepository) ListMatches(userID string, limit int, cursor string) (models.MatchPage, error) {
+	var page models.MatchPage
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return page, err
+	}
+
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return page, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(matchesTable),
+		IndexName:                 aws.String("MatchedAtIndex"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	result, err := repo.Client.Query(queryInput)
+	if err != nil {
+		return page, err
+	}
+
+	if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page.Matches); err != nil {
+		return page, err
+	}
+
+	page.NextCursor, err = encodeCursor(result.LastEvaluatedKey)
+	return page, err
+}
+
+// GetMatch returns the user's side of a match, or nil if the user is not part of it.
+func (repo *DynamoDBRepository) GetMatch(userID, matchID string) (*models.Match, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(matchesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID":  {S: aws.String(userID)},
+			"MatchID": {S: aws.String(matchID)},
+		},
+	}
+
+	result, err := repo.Client.GetItem(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var match models.Match
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &match); err != nil {
+		return nil, err
+	}
+
+	return &match, nil
+}
+
+/*
+InsertMessage stores a message, on the condition that the sender's match still exists. Checked in the same write, a
+message cannot land in a conversation that Unmatch has already deleted. ErrMatchNotFound is returned if it has.
+*/
+func (repo *DynamoDBRepository) InsertMessage(message models.Message) error {
+	av, err := dynamodbattribute.MarshalMap(message)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					Item:                av,
+					TableName:           aws.String(messagesTable),
+					ConditionExpression: aws.String("attribute_not_exists(MessageID)"),
+				},
+			},
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName: aws.String(matchesTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID":  {S: aws.String(message.SenderID)},
+						"MatchID": {S: aws.String(message.MatchID)},
+					},
+					ConditionExpression: aws.String("attribute_exists(MatchID)"),
+				},
+			},
+		},
+	})
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 1 &&
+		aws.StringValue(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+		return ErrMatchNotFound
+	}
+	return err
+}
+
+// ListMessages returns a page of a conversation, newest message first, continued with the previous page's cursor.
+func (repo *DynamoDBRepository) ListMessages(matchID string, limit int, cursor string) (models.MessagePage, error) {
+	var page models.MessagePage
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return page, err
+	}
+
+	keyCond := expression.Key("MatchID").Equal(expression.Value(matchID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return page, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(messagesTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(int64(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	result, err := repo.Client.Query(queryInput)
+	if err != nil {
+		return page, err
+	}
+
+	if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page.Messages); err != nil {
+		return page, err
+	}
+
+	page.NextCursor, err = encodeCursor(result.LastEvaluatedKey)
+	return page, err
+}
+
+/*
+Unmatch removes a match the user is part of, together with its conversation. Both match records are deleted and both
+swipe rows are turned into dislikes in a single transaction, so the two users stay out of each other's discover results
+and cannot match again by accident.
+The messages are deleted before the transaction, so a failure part way leaves the match to find them again on the next
+attempt, and once more after it, for messages sent in the meantime. None can be sent once the match is gone.
+
+Returns the user's side of the removed match, or nil if the user is not part of a match with that ID.
+*/
+func (repo *DynamoDBRepository) Unmatch(userID, matchID string) (*models.Match, error) {
+	match, err := repo.GetMatch(userID, matchID)
+	if err != nil || match == nil {
+		return nil, err
+	}
+
+	update := expression.Set(expression.Name("preference"), expression.Value(false)).
+		Set(expression.Name("matched"), expression.Value(false)).
+		Remove(expression.Name("matchId"))
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = repo.deleteMessages(matchID); err != nil {
+		return nil, err
+	}
+
+	var items []*dynamodb.TransactWriteItem
+	for _, pair := range [][2]string{{match.UserID, match.MatchedUserID}, {match.MatchedUserID, match.UserID}} {
+		items = append(items, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(matchesTable),
+				Key: map[string]*dynamodb.AttributeValue{
+					"UserID":  {S: aws.String(pair[0])},
+					"MatchID": {S: aws.String(matchID)},
+				},
+			},
+		}, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(swipesTable),
+				Key: map[string]*dynamodb.AttributeValue{
+					"UserID":       {S: aws.String(pair[0])},
+					"SwipedUserID": {S: aws.String(pair[1])},
+				},
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				UpdateExpression:          expr.Update(),
+			},
+		})
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = repo.deleteMessages(matchID); err != nil {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+/*
+BlockUser stores that userID blocked blockedUserID. The block row is written first, so the swipe and messaging paths
+reject the pair straight away. If the two users were matched the match is then removed, and the blocker's swipe is
+recorded as a dislike so the blocked user stays out of their swipe history based exclusions too.
+
+Returns the MatchID of the removed match, or an empty string if they were not matched.
+*/
+func (repo *DynamoDBRepository) BlockUser(userID, blockedUserID string, blockedAt int64) (string, error) {
+	av, err := dynamodbattribute.MarshalMap(models.Block{UserID: userID, BlockedUserID: blockedUserID, BlockedAt: blockedAt})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = repo.Client.PutItem(&dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String(blocksTable),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var removedMatchID string
+	own, err := repo.getSwipe(userID, blockedUserID)
+	if err != nil {
+		return "", err
+	}
+	if own != nil && own.Matched {
+		if _, err = repo.Unmatch(userID, own.MatchID); err != nil {
+			return "", err
+		}
+		removedMatchID = own.MatchID
+	}
+
+	err = repo.InsertSwipeRecord(models.Swipe{UserID: userID, SwipedUserID: blockedUserID, Preference: false})
+	if err != nil {
+		return "", err
+	}
+
+	return removedMatchID, nil
+}
+
+// IsBlocked reports whether either of the two users has blocked the other.
+func (repo *DynamoDBRepository) IsBlocked(userID, otherUserID string) (bool, error) {
+	input := &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			blocksTable: {
+				Keys: []map[string]*dynamodb.AttributeValue{
+					{"UserID": {S: aws.String(userID)}, "BlockedUserID": {S: aws.String(otherUserID)}},
+					{"UserID": {S: aws.String(otherUserID)}, "BlockedUserID": {S: aws.String(userID)}},
+				},
+				ProjectionExpression: aws.String("UserID"),
+				ConsistentRead:       aws.Bool(true),
+			},
+		},
+	}
+
+	result, err := repo.Client.BatchGetItem(input)
+	if err != nil {
+		return false, err
+	}
+
+	if len(result.Responses[blocksTable]) > 0 {
+		return true, nil
+	}
+	// Keys the batch could not read yet leave the answer open, so fail closed
+	if len(result.UnprocessedKeys) > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+/*
+GetBlockedUserIDs returns every user blocked by userID and every user who blocked userID, reading all pages of both
+the table and its `BlockedUserIndex` GSI.
+*/
+func (repo *DynamoDBRepository) GetBlockedUserIDs(userID string) ([]string, error) {
+	var blockedUserIDs []string
+
+	queries := []struct {
+		index     *string
+		keyName   string
+		otherName string
+	}{
+		{nil, "UserID", "BlockedUserID"},
+		{aws.String("BlockedUserIndex"), "BlockedUserID", "UserID"},
+	}
+
+	for _, q := range queries {
+		keyCond := expression.Key(q.keyName).Equal(expression.Value(userID))
+		proj := expression.NamesList(expression.Name(q.otherName))
+		expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
+		if err != nil {
+			return nil, err
+		}
+
+		queryInput := &dynamodb.QueryInput{
+			TableName:                 aws.String(blocksTable),
+			IndexName:                 q.index,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			KeyConditionExpression:    expr.KeyCondition(),
+			ProjectionExpression:      expr.Projection(),
+		}
+
+		err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+			for _, item := range page.Items {
+				if v, ok := item[q.otherName]; ok && v.S != nil {
+					blockedUserIDs = append(blockedUserIDs, *v.S)
+				}
+			}
+			return true
+		})
+		if err != nil {
 			return nil, err
 		}
-		swipedUserIDs = append(swipedUserIDs, swipe.SwipedUserID)
 	}
 
-	return swipedUserIDs, nil
+	return blockedUserIDs, nil
+}
+
+// isConditionalCheckFailed reports whether a write failed on its condition, on its own or as part of a transaction.
+func isConditionalCheckFailed(err error) bool {
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return hasCancellationCode(canceled, "ConditionalCheckFailed")
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+func (repo *DynamoDBRepository) InsertReport(report models.Report) error {
+	av, err := dynamodbattribute.MarshalMap(report)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           aws.String(reportsTable),
+		ConditionExpression: aws.String("attribute_not_exists(ReportID)"),
+	}
+
+	_, err = repo.Client.PutItem(input)
+	return err
+}
+
+// GetReport returns the report with the given ID, or nil if there is none.
+func (repo *DynamoDBRepository) GetReport(reportID string) (*models.Report, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(reportsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ReportID": {S: aws.String(reportID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+
+	result, err := repo.Client.GetItem(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var report models.Report
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
+/*
+ListReports returns a page of reports with the given status, oldest first, so the moderation queue is worked in the
+order reports came in.
+
+The query runs against the `StatusIndex` GSI. The cursor is the opaque form of DynamoDB's LastEvaluatedKey and is empty
+once there are no more pages.
+*/
+func (repo *DynamoDBRepository) ListReports(status string, limit int, cursor string) (models.ReportPage, error) {
+	var page models.ReportPage
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return page, err
+	}
+
+	keyCond := expression.Key("status").Equal(expression.Value(status))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return page, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(reportsTable),
+		IndexName:                 aws.String("StatusIndex"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		Limit:                     aws.Int64(int64(limit)),
+		ExclusiveStartKey:         startKey,
+	}
+
+	result, err := repo.Client.Query(queryInput)
+	if err != nil {
+		return page, err
+	}
+
+	if err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page.Reports); err != nil {
+		return page, err
+	}
+
+	page.NextCursor, err = encodeCursor(result.LastEvaluatedKey)
+	return page, err
+}
+
+/*
+ResolveReport closes an open report with the moderator's resolution.
+
+The update is conditional on the report still being open, so two moderators cannot resolve the same report. When the
+condition fails ErrReportNotOpen is returned.
+*/
+func (repo *DynamoDBRepository) ResolveReport(reportID, resolution, resolvedBy string, resolvedAt int64) error {
+	update := expression.Set(expression.Name("status"), expression.Value(models.ReportStatusResolved)).
+		Set(expression.Name("resolution"), expression.Value(resolution)).
+		Set(expression.Name("resolvedBy"), expression.Value(resolvedBy)).
+		Set(expression.Name("resolvedAt"), expression.Value(resolvedAt))
+	cond := expression.Name("status").Equal(expression.Value(models.ReportStatusOpen))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(reportsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ReportID": {S: aws.String(reportID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	}
+
+	_, err = repo.Client.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrReportNotOpen
+	}
+	return err
 }
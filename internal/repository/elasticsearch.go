@@ -8,8 +8,10 @@ import (
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/mitchellh/mapstructure"
 	"log"
+	"net/http"
 	"quick-match/internal/models"
 	"strings"
+	"time"
 )
 
 type ElasticSearchRepository struct {
@@ -24,24 +26,64 @@ func NewElasticSearchClient(client *elasticsearch.Client) ElasticSearchRepositor
 
 func CreateElasticSearchUser(user models.UserDetails) models.UserDetailsES {
 	return models.UserDetailsES{
-		UserID: user.UserID,
-		Name:   user.Name,
-		Gender: user.Gender,
-		Age:    user.Age,
+		UserID:    user.UserID,
+		Name:      user.Name,
+		Gender:    user.Gender,
+		Birthdate: user.Birthdate,
 		Location: models.UserLocationES{
 			Lat: user.Latitude,
 			Lon: user.Longitude,
 		},
+		Bio:         user.Bio,
+		Interests:   user.Interests,
+		Photos:      models.PhotoURLsOf(user.Photos),
+		Preferences: user.Preferences,
 	}
 }
 
 type BoolQuery struct {
 	MustNot []interface{} `json:"must_not,omitempty"`
 	Filter  []interface{} `json:"filter,omitempty"`
+	Should  []interface{} `json:"should,omitempty"`
 }
 
+const (
+	usersIndex  = "users"
+	swipesIndex = "swipes"
+)
+
+const (
+	defaultDiscoverPageSize = 20
+	scanPageSize            = 500
+	usersMappingProperties  = `{
+		"properties": {
+			"UserID": { "type": "keyword" },
+			"name": { "type": "text" },
+			"gender": { "type": "keyword" },
+			"birthdate": { "type": "date", "format": "yyyy-MM-dd" },
+			"location": { "type": "geo_point" },
+			"lastActiveAt": { "type": "date", "format": "epoch_second" },
+			"bio": { "type": "text" },
+			"interests": { "type": "keyword" },
+			"interestedIn": { "type": "keyword" },
+			"preferredMinAge": { "type": "integer" },
+			"preferredMaxAge": { "type": "integer" },
+			"photos": { "type": "object", "enabled": false }
+		}
+	}`
+	// Swipe sets are only read back whole by terms lookups, so the IDs do not need to be searchable themselves
+	swipesMappingProperties = `{
+		"properties": {
+			"swipedUserIds": { "type": "keyword", "index": false }
+		}
+	}`
+)
+
 type Query struct {
-	Query struct {
+	Size        int   `json:"size,omitempty"`
+	Sort        []any `json:"sort,omitempty"`
+	SearchAfter []any `json:"search_after,omitempty"`
+	Query       struct {
 		Bool BoolQuery `json:"bool"`
 	} `json:"query"`
 }
@@ -52,23 +94,20 @@ func NewQuery() *Query {
 
 /*
 EnsureElasticsearchSetup checks and ensures the necessary Elasticsearch index setup for user data.
-This method specifically checks if the "users" index exists in the Elasticsearch database. If it does not exist,
-it creates the index with predefined mappings for the user properties such as UserID, name, gender, age, and location.
-These mappings help in optimizing search queries and aggregations on the user data.
+This method specifically checks if the "users" index exists in the Elasticsearch database. If it does not exist, it
+creates the index with predefined mappings for the user properties such as UserID, name, gender, birthdate, location,
+lastActiveAt, bio, interest tags, the user's own discover preferences and their photo URLs, which are stored but not
+searchable. If it already exists, the mappings are applied to it again so fields added since it was created are mapped
+before any document uses them. These mappings help in optimizing search queries and aggregations on the user data.
+The "swipes" index, holding one swipe set per user for excluding swiped users from discover, is set up the same way.
 */
 func (repo *ElasticSearchRepository) EnsureElasticsearchSetup() {
-	indexName := "users"
-	mappings := `{
-		"mappings": {
-			"properties": {
-				"UserID": { "type": "keyword" },
-				"name": { "type": "text" },
-				"gender": { "type": "keyword" },
-				"age": { "type": "integer" },
-				"location": { "type": "geo_point" }
-			}
-		}
-	}`
+	repo.ensureIndex(usersIndex, usersMappingProperties)
+	repo.ensureIndex(swipesIndex, swipesMappingProperties)
+}
+
+func (repo *ElasticSearchRepository) ensureIndex(indexName, properties string) {
+	mappings := `{ "mappings": ` + properties + ` }`
 
 	res, err := repo.EsClient.Indices.Exists([]string{indexName})
 	if err != nil || res.StatusCode == 404 {
@@ -78,11 +117,19 @@ func (repo *ElasticSearchRepository) EnsureElasticsearchSetup() {
 			repo.EsClient.Indices.Create.WithContext(context.Background()),
 		)
 		if err != nil || res.IsError() {
-			log.Fatalf("Failed to create Elasticsearch index: %v", err)
+			log.Fatalf("Failed to create Elasticsearch index %s: %v", indexName, err)
 		}
-		log.Println("Elasticsearch index created successfully")
+		log.Printf("Elasticsearch index %s created successfully", indexName)
 	} else {
-		log.Println("Elasticsearch index already exists")
+		res, err = repo.EsClient.Indices.PutMapping(
+			strings.NewReader(properties),
+			repo.EsClient.Indices.PutMapping.WithIndex(indexName),
+			repo.EsClient.Indices.PutMapping.WithContext(context.Background()),
+		)
+		if err != nil || res.IsError() {
+			log.Fatalf("Failed to update Elasticsearch index %s mappings: %v", indexName, err)
+		}
+		log.Printf("Elasticsearch index %s already exists, mappings updated", indexName)
 	}
 }
 
@@ -93,7 +140,7 @@ func (repo *ElasticSearchRepository) InsertUserES(user models.UserDetailsES) err
 	}
 
 	res, err := repo.EsClient.Index(
-		"users",
+		usersIndex,
 		strings.NewReader(string(userJSON)),
 		repo.EsClient.Index.WithDocumentID(user.UserID),
 		repo.EsClient.Index.WithRefresh("true"),
@@ -114,7 +161,7 @@ func (repo *ElasticSearchRepository) GetUserByID(userID string) (models.UserDeta
 	var user models.UserDetailsES
 
 	res, err := repo.EsClient.Get(
-		"users",
+		usersIndex,
 		userID,
 	)
 	if err != nil {
@@ -140,6 +187,311 @@ func (repo *ElasticSearchRepository) GetUserByID(userID string) (models.UserDeta
 	return user, nil
 }
 
+// SwipeSetExists reports whether the user has a swipe set in the "swipes" index.
+func (repo *ElasticSearchRepository) SwipeSetExists(userID string) (bool, error) {
+	res, err := repo.EsClient.Exists(
+		swipesIndex,
+		userID,
+		repo.EsClient.Exists.WithContext(context.Background()),
+	)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error checking swipe set ID=%s: %s", userID, res.String())
+	}
+}
+
+/*
+ReplaceSwipeSet creates the user's swipe set from their full swipe history. It only creates a missing set: if one was
+created in the meantime, that set is kept. A swipe made after the history was read finds no set to add itself to, so
+callers read the history again afterwards and merge it in with MergeIntoSwipeSet.
+*/
+func (repo *ElasticSearchRepository) ReplaceSwipeSet(userID string, swipedUserIDs []string) error {
+	if swipedUserIDs == nil {
+		swipedUserIDs = []string{}
+	}
+	body, err := json.Marshal(map[string]any{"swipedUserIds": swipedUserIDs})
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.EsClient.Create(
+		swipesIndex,
+		userID,
+		bytes.NewReader(body),
+		repo.EsClient.Create.WithContext(context.Background()),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusConflict {
+		return fmt.Errorf("error creating swipe set ID=%s: %s", userID, res.String())
+	}
+	return nil
+}
+
+/*
+AddToSwipeSet appends swipedUserID to the user's swipe set. A user without a swipe set is left without one, so the set
+is later built from the complete swipe history rather than from this swipe alone.
+*/
+func (repo *ElasticSearchRepository) AddToSwipeSet(userID, swipedUserID string) error {
+	return repo.MergeIntoSwipeSet(userID, []string{swipedUserID})
+}
+
+// MergeIntoSwipeSet appends the IDs missing from the user's swipe set. Like AddToSwipeSet, it never creates a set.
+func (repo *ElasticSearchRepository) MergeIntoSwipeSet(userID string, swipedUserIDs []string) error {
+	if len(swipedUserIDs) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(map[string]any{
+		"script": map[string]any{
+			"source": "boolean added = false; for (id in params.ids) { if (!ctx._source.swipedUserIds.contains(id)) { ctx._source.swipedUserIds.add(id); added = true } } if (!added) { ctx.op = 'none' }",
+			"lang":   "painless",
+			"params": map[string]any{"ids": swipedUserIDs},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.EsClient.Update(
+		swipesIndex,
+		userID,
+		bytes.NewReader(body),
+		repo.EsClient.Update.WithContext(context.Background()),
+		repo.EsClient.Update.WithRetryOnConflict(3),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error updating swipe set ID=%s: %s", userID, res.String())
+	}
+	return nil
+}
+
+// DeleteSwipeSet removes the user's swipe set, so it is rebuilt from the swipe history on their next discover request.
+func (repo *ElasticSearchRepository) DeleteSwipeSet(userID string) error {
+	res, err := repo.EsClient.Delete(
+		swipesIndex,
+		userID,
+		repo.EsClient.Delete.WithContext(context.Background()),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting swipe set ID=%s: %s", userID, res.String())
+	}
+	return nil
+}
+
+/*
+ReindexUserES replaces the user's document in the "users" index with the given one, creating it if it is missing.
+lastActiveAt is only tracked in Elasticsearch, so the indexed value is kept.
+*/
+func (repo *ElasticSearchRepository) ReindexUserES(user models.UserDetailsES) error {
+	body, err := json.Marshal(map[string]any{
+		"script": map[string]any{
+			"source": "def lastActiveAt = ctx._source.lastActiveAt; ctx._source.clear(); ctx._source.putAll(params.user); if (lastActiveAt != null) { ctx._source.lastActiveAt = lastActiveAt }",
+			"lang":   "painless",
+			"params": map[string]any{"user": user},
+		},
+		"upsert": user,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.EsClient.Update(
+		usersIndex,
+		user.UserID,
+		bytes.NewReader(body),
+		repo.EsClient.Update.WithContext(context.Background()),
+		repo.EsClient.Update.WithRetryOnConflict(3),
+		repo.EsClient.Update.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error reindexing document ID=%s: %s", user.UserID, res.String())
+	}
+	return nil
+}
+
+// UpdateLastActive records when the user was last active, used by the "recent" discover sort.
+func (repo *ElasticSearchRepository) UpdateLastActive(userID string, lastActiveAt int64) error {
+	body, err := json.Marshal(map[string]any{
+		"doc": map[string]any{"lastActiveAt": lastActiveAt},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := repo.EsClient.Update(
+		usersIndex,
+		userID,
+		bytes.NewReader(body),
+		repo.EsClient.Update.WithContext(context.Background()),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error updating document ID=%s: %s", userID, res.String())
+	}
+	return nil
+}
+
+// DeleteUserES removes a user from the "users" index. Deleting a user that is not indexed is not an error.
+func (repo *ElasticSearchRepository) DeleteUserES(userID string) error {
+	res, err := repo.EsClient.Delete(
+		usersIndex,
+		userID,
+		repo.EsClient.Delete.WithContext(context.Background()),
+		repo.EsClient.Delete.WithRefresh("true"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting document ID=%s: %s", userID, res.String())
+	}
+	return nil
+}
+
+// GetUsersByIDs fetches several users in one multi-get request. Users that are not indexed are left out of the result.
+func (repo *ElasticSearchRepository) GetUsersByIDs(userIDs []string) (map[string]models.UserDetailsES, error) {
+	users := make(map[string]models.UserDetailsES, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	body, err := json.Marshal(map[string]any{"ids": userIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := repo.EsClient.Mget(
+		bytes.NewReader(body),
+		repo.EsClient.Mget.WithIndex(usersIndex),
+		repo.EsClient.Mget.WithContext(context.Background()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error fetching users: %s", res.String())
+	}
+
+	var r struct {
+		Docs []struct {
+			Found  bool           `json:"found"`
+			Source map[string]any `json:"_source"`
+		} `json:"docs"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing the response body: %s", err)
+	}
+
+	for _, doc := range r.Docs {
+		if !doc.Found {
+			continue
+		}
+		var user models.UserDetailsES
+		if err = mapstructure.Decode(doc.Source, &user); err != nil {
+			return nil, fmt.Errorf("error decoding doc source: %v", err)
+		}
+		users[user.UserID] = user
+	}
+
+	return users, nil
+}
+
+/*
+ScanUsersES calls visit with every document in the "users" index, in UserID order. Documents are fetched in pages with
+search_after, so the scan does not depend on a scroll context staying alive. Scanning stops at the first error
+returned by visit.
+*/
+func (repo *ElasticSearchRepository) ScanUsersES(visit func(user models.UserDetailsES) error) error {
+	var searchAfter []any
+	for {
+		query := NewQuery()
+		query.Size = scanPageSize
+		query.Sort = []any{map[string]any{"UserID": "asc"}}
+		query.SearchAfter = searchAfter
+		body, err := json.Marshal(query)
+		if err != nil {
+			return err
+		}
+
+		res, err := repo.EsClient.Search(
+			repo.EsClient.Search.WithContext(context.Background()),
+			repo.EsClient.Search.WithIndex(usersIndex),
+			repo.EsClient.Search.WithBody(bytes.NewReader(body)),
+		)
+		if err != nil {
+			return err
+		}
+
+		var r struct {
+			Hits struct {
+				Hits []struct {
+					Source map[string]any `json:"_source"`
+					Sort   []any          `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if res.IsError() {
+			res.Body.Close()
+			return fmt.Errorf("error scanning users: %s", res.String())
+		}
+		err = json.NewDecoder(res.Body).Decode(&r)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("error parsing the response body: %s", err)
+		}
+
+		for _, hit := range r.Hits.Hits {
+			var user models.UserDetailsES
+			if err = mapstructure.Decode(hit.Source, &user); err != nil {
+				return fmt.Errorf("error decoding hit source: %v", err)
+			}
+			if err = visit(user); err != nil {
+				return err
+			}
+		}
+
+		if len(r.Hits.Hits) < scanPageSize {
+			return nil
+		}
+		searchAfter = r.Hits.Hits[len(r.Hits.Hits)-1].Sort
+	}
+}
+
 func (q *Query) AddGenderFilter(gender string) {
 	if gender != "" {
 		q.Query.Bool.Filter = append(q.Query.Bool.Filter, map[string]any{
@@ -150,34 +502,81 @@ func (q *Query) AddGenderFilter(gender string) {
 	}
 }
 
+/*
+AddAgeRangeFilter keeps users aged between minAge and maxAge, inclusive. Ages are not indexed, so the range is turned
+into a birthdate range with date math relative to today: someone is at least minAge if born on or before this day
+minAge years ago, and at most maxAge if born after this day maxAge+1 years ago.
+*/
 func (q *Query) AddAgeRangeFilter(minAge, maxAge int) {
 	if maxAge > 0 || minAge > 0 {
-		ageQuery := map[string]any{
-			"range": map[string]any{
-				"age": map[string]interface{}{},
-			},
-		}
+		birthdateRange := map[string]any{}
 		if minAge > 0 {
-			ageQuery["range"].(map[string]any)["age"].(map[string]any)["gte"] = minAge
+			birthdateRange["lte"] = fmt.Sprintf("now-%dy/d", minAge)
 		}
 		if maxAge > 0 {
-			ageQuery["range"].(map[string]any)["age"].(map[string]any)["lte"] = maxAge
+			birthdateRange["gt"] = fmt.Sprintf("now-%dy/d", maxAge+1)
 		}
-		q.Query.Bool.Filter = append(q.Query.Bool.Filter, ageQuery)
+		q.Query.Bool.Filter = append(q.Query.Bool.Filter, map[string]any{
+			"range": map[string]any{
+				"birthdate": birthdateRange,
+			},
+		})
 	}
 }
 
-func (q *Query) AddGeoDistanceFilter(location models.UserLocationES, maxDistanceKM int) {
-	if maxDistanceKM > 0 {
+// AddGeoDistanceFilter keeps users within maxDistance of location, measured in unit ("km" or "mi").
+func (q *Query) AddGeoDistanceFilter(location models.UserLocationES, maxDistance int, unit string) {
+	if maxDistance > 0 {
 		q.Query.Bool.Filter = append(q.Query.Bool.Filter, map[string]any{
 			"geo_distance": map[string]any{
-				"distance": fmt.Sprintf("%dkm", maxDistanceKM),
+				"distance": fmt.Sprintf("%d%s", maxDistance, unit),
 				"location": location,
 			},
 		})
 	}
 }
 
+/*
+AddSort orders the results by the given sort mode and makes the order total by breaking ties on UserID, which
+search_after needs to page without skipping or repeating users:
+- relevance: highest score first, see AddInterestBoost, then nearest to location first.
+- distance: nearest to location first.
+- recent: most recently active first. Users that have never been active come last.
+- age: youngest first, which is the latest birthdate first.
+*/
+func (q *Query) AddSort(sort string, location models.UserLocationES) {
+	switch sort {
+	case models.SortRelevance:
+		q.Sort = append(q.Sort,
+			map[string]any{"_score": map[string]any{"order": "desc"}},
+			map[string]any{"_geo_distance": map[string]any{"location": location, "order": "asc", "unit": "km"}},
+		)
+	case models.SortRecent:
+		q.Sort = append(q.Sort, map[string]any{"lastActiveAt": map[string]any{"order": "desc", "missing": "_last"}})
+	case models.SortAge:
+		q.Sort = append(q.Sort, map[string]any{"birthdate": map[string]any{"order": "desc", "missing": "_last"}})
+	default:
+		q.Sort = append(q.Sort, map[string]any{"_geo_distance": map[string]any{"location": location, "order": "asc", "unit": "km"}})
+	}
+	q.Sort = append(q.Sort, map[string]any{"UserID": map[string]any{"order": "asc"}})
+}
+
+/*
+AddInterestBoost scores every user by the number of the given interests they share, without filtering anyone out.
+Each shared interest is an optional clause with a constant score of 1, so the score is an exact count that does not
+depend on how common an interest is.
+*/
+func (q *Query) AddInterestBoost(interests []string) {
+	for _, interest := range interests {
+		q.Query.Bool.Should = append(q.Query.Bool.Should, map[string]any{
+			"constant_score": map[string]any{
+				"filter": map[string]any{"term": map[string]any{"interests": interest}},
+				"boost":  1,
+			},
+		})
+	}
+}
+
 func (q *Query) AddExclusionFilter(ids []string) {
 	if len(ids) > 0 {
 		q.Query.Bool.MustNot = append(q.Query.Bool.MustNot, map[string]any{
@@ -189,68 +588,170 @@ func (q *Query) AddExclusionFilter(ids []string) {
 	}
 }
 
+/*
+AddMutualPreferenceFilter keeps only candidates that the searcher and the candidate would both want to see:
+- The candidate's gender and age fall within the searcher's stored preferences.
+- The searcher's gender and age fall within the candidate's stored preferences.
+Preferences that are not set, on either side, accept anyone.
+*/
+func (q *Query) AddMutualPreferenceFilter(searcher models.UserDetailsES) {
+	searcherAge := models.AgeFromBirthdate(searcher.Birthdate, time.Now())
+	if len(searcher.InterestedIn) > 0 {
+		q.Query.Bool.Filter = append(q.Query.Bool.Filter, map[string]any{
+			"terms": map[string]any{"gender": searcher.InterestedIn},
+		})
+	}
+	q.AddAgeRangeFilter(searcher.PreferredMinAge, searcher.PreferredMaxAge)
+
+	q.Query.Bool.Filter = append(q.Query.Bool.Filter,
+		acceptsOrUnset("interestedIn", map[string]any{"term": map[string]any{"interestedIn": searcher.Gender}}),
+		acceptsOrUnset("preferredMinAge", map[string]any{"range": map[string]any{"preferredMinAge": map[string]any{"lte": searcherAge}}}),
+		acceptsOrUnset("preferredMaxAge", map[string]any{"range": map[string]any{"preferredMaxAge": map[string]any{"gte": searcherAge}}}),
+	)
+}
+
+// acceptsOrUnset matches documents that satisfy accepts, or that have no value for field at all.
+func acceptsOrUnset(field string, accepts map[string]any) map[string]any {
+	return map[string]any{
+		"bool": map[string]any{
+			"should": []any{
+				accepts,
+				map[string]any{"bool": map[string]any{"must_not": map[string]any{"exists": map[string]any{"field": field}}}},
+			},
+			"minimum_should_match": 1,
+		},
+	}
+}
+
+// AddSwipedExclusionFilter excludes every user in userID's swipe set, looked up by Elasticsearch at query time.
+func (q *Query) AddSwipedExclusionFilter(userID string) {
+	q.Query.Bool.MustNot = append(q.Query.Bool.MustNot, map[string]any{
+		"terms": map[string]any{
+			"UserID": map[string]any{
+				"index": swipesIndex,
+				"id":    userID,
+				"path":  "swipedUserIds",
+			},
+		},
+	})
+}
+
 /*
 SearchUsers performs a filtered search on the user data stored in Elasticsearch based on the given filters:
-currentUserLocation, swipedUserIDs, and discover filters. It constructs a query that excludes users already swiped on,
-matches the specified gender and age range, and is within the maximum distance from the currentUserLocation. Any combination of
-filters can be added. This function returns a list of users that match the specified criteria or an error if the search fails.
+searcher, blockedUserIDs and discover filters. It constructs a query that excludes the searching user, users
+already swiped on and users blocked in either direction, matches the specified gender and age range, keeps only candidates
+whose stored preferences and the searcher's stored preferences accept each other, and is within the maximum distance from
+the searcher's location. Any combination of
+filters can be added. Results are sorted by the requested sort mode, by default ranking candidates that share the most
+interests with the searcher first, and returned one page at a time; the next page is
+fetched with search_after from the cursor of the previous one. This function returns a page of users that match the
+specified criteria or an error if the search fails.
 
 Parameters:
-- currentUserLocation: The geographical location of the current user performing the discovery.
-- swipedUserIDs: A list of user IDs that the current user has already swiped on, to be excluded from the search results.
-- discover: Filters specifying the criteria for the user discovery such as gender preference, age range, and maximum distance.
+- searcher: The indexed details of the current user performing the discovery, including their location and preferences.
+- blockedUserIDs: A list of user IDs that the current user blocked or was blocked by, to be excluded from the search results.
+- discover: Filters specifying the criteria for the user discovery such as gender preference, age range, and maximum distance, along with the page size, sort mode and cursor. Its UserID identifies the searching user.
+
+Users already swiped on are excluded with a terms lookup against the searching user's swipe set in the "swipes" index,
+so the query stays the same size however many swipes there are. Callers make sure the swipe set exists first, see
+SwipeSetExists and ReplaceSwipeSet.
 
 Returns:
-- A slice of UserDetailsES models representing the users who match the search criteria.
+- A DiscoverPage with the users who match the search criteria, the cursor of the next page (empty on the last page) and the total number of matching users.
+- ErrInvalidCursor if the cursor cannot be decoded or belongs to a different sort mode.
 - An error if the search operation fails or if there is an issue parsing the response from Elasticsearch.
 */
-func (repo *ElasticSearchRepository) SearchUsers(currentUserLocation models.UserLocationES, swipedUserIDs []string, discover models.DiscoverFilters) ([]models.UserDetailsES, error) {
+func (repo *ElasticSearchRepository) SearchUsers(searcher models.UserDetailsES, blockedUserIDs []string, discover models.DiscoverFilters) (models.DiscoverPage, error) {
 	var buf bytes.Buffer
+	var page models.DiscoverPage
+	currentUserLocation := searcher.Location
+
+	sort := discover.Sort
+	if sort == "" {
+		sort = models.SortRelevance
+	}
+	searchAfter, err := decodeSearchCursor(discover.Cursor, sort)
+	if err != nil {
+		return page, err
+	}
+
+	size := discover.PageSize
+	if size <= 0 {
+		size = defaultDiscoverPageSize
+	}
 
 	query := NewQuery()
-	query.AddExclusionFilter(swipedUserIDs)
+	query.Size = size
+	query.SearchAfter = searchAfter
+	query.AddSort(sort, currentUserLocation)
+	query.AddExclusionFilter([]string{discover.UserID})
+	query.AddSwipedExclusionFilter(discover.UserID)
+	query.AddExclusionFilter(blockedUserIDs)
 	query.AddGenderFilter(discover.Gender)
 	query.AddAgeRangeFilter(discover.MinAge, discover.MaxAge)
-	query.AddGeoDistanceFilter(currentUserLocation, discover.MaxLocation)
+	unit := discover.Unit
+	if unit == "" {
+		unit = models.UnitKilometers
+	}
+	query.AddGeoDistanceFilter(currentUserLocation, discover.MaxLocation, unit)
+	query.AddMutualPreferenceFilter(searcher)
+	if sort == models.SortRelevance {
+		query.AddInterestBoost(searcher.Interests)
+	}
 
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		return nil, fmt.Errorf("error encoding query: %v", err)
+	if err = json.NewEncoder(&buf).Encode(query); err != nil {
+		return page, fmt.Errorf("error encoding query: %v", err)
 	}
 
 	res, err := repo.EsClient.Search(
 		repo.EsClient.Search.WithContext(context.Background()),
-		repo.EsClient.Search.WithIndex("users"),
+		repo.EsClient.Search.WithIndex(usersIndex),
 		repo.EsClient.Search.WithBody(&buf),
 		repo.EsClient.Search.WithTrackTotalHits(true),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error getting response: %s", err)
+		return page, fmt.Errorf("error getting response: %s", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
 		var e map[string]any
 		if err = json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return nil, fmt.Errorf("error parsing the response body: %s", err)
+			return page, fmt.Errorf("error parsing the response body: %s", err)
 		}
-		return nil, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
+		return page, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
 	}
 
 	var r map[string]any
-	if err = json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return nil, fmt.Errorf("error parsing the response body: %s", err)
+	decoder := json.NewDecoder(res.Body)
+	decoder.UseNumber()
+	if err = decoder.Decode(&r); err != nil {
+		return page, fmt.Errorf("error parsing the response body: %s", err)
 	}
-	fmt.Printf("Successful query. Number of hits: %d\n", int(r["hits"].(map[string]interface{})["total"].(map[string]interface{})["value"].(float64)))
+	total, err := r["hits"].(map[string]any)["total"].(map[string]any)["value"].(json.Number).Int64()
+	if err != nil {
+		return page, fmt.Errorf("error parsing the total hit count: %v", err)
+	}
+	page.Total = int(total)
+	fmt.Printf("Successful query. Number of hits: %d\n", page.Total)
 
-	var users []models.UserDetailsES
-	for _, hit := range r["hits"].(map[string]any)["hits"].([]any) {
+	hits := r["hits"].(map[string]any)["hits"].([]any)
+	for _, hit := range hits {
 		var user models.UserDetailsES
 		hitSource := hit.(map[string]any)["_source"].(map[string]any)
 		if err = mapstructure.Decode(hitSource, &user); err != nil {
-			return nil, fmt.Errorf("error decoding hit source: %v", err)
+			return page, fmt.Errorf("error decoding hit source: %v", err)
 		}
-		users = append(users, user)
+		page.Users = append(page.Users, user)
 	}
 
-	return users, nil
+	// A full page may be followed by another one, which starts after the sort values of its last hit
+	if len(hits) == size {
+		lastSort, _ := hits[len(hits)-1].(map[string]any)["sort"].([]any)
+		if page.NextCursor, err = encodeSearchCursor(sort, lastSort); err != nil {
+			return page, err
+		}
+	}
+
+	return page, nil
 }
@@ -0,0 +1,365 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"quick-match/internal/models"
+	"time"
+)
+
+const tombstonesTable = "quickmatch_tombstones"
+const auditTable = "quickmatch_audit"
+
+// maxBatchWriteItems is the most items DynamoDB accepts in one BatchWriteItem request.
+const maxBatchWriteItems = 25
+
+// maxBatchWriteAttempts is how often batchDelete sends a batch before giving up on its unprocessed items.
+const maxBatchWriteAttempts = 8
+
+/*
+DeleteUser deletes a user's account in one transaction: the user is removed together with their email claim, and a
+tombstone and an outbox entry are written, so the indexer removes them from Elasticsearch as well. The rest of their
+data is erased afterwards, see the erasure package. ErrUserNotFound is returned if the user does not exist.
+*/
+func (repo *DynamoDBRepository) DeleteUser(user models.UserDetails, tombstone models.Tombstone) error {
+	av, err := dynamodbattribute.MarshalMap(tombstone)
+	if err != nil {
+		return err
+	}
+	entry, err := outboxPut(user.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(usersTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID": {S: aws.String(user.UserID)},
+					},
+					ConditionExpression: aws.String("attribute_exists(UserID)"),
+				},
+			},
+			{
+				// Users who signed up before emails were claimed have no claim, which deletes nothing
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(emailsTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"email": {S: aws.String(user.Email)},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(email) OR UserID = :userId"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":userId": {S: aws.String(user.UserID)},
+					},
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(tombstonesTable),
+					Item:      av,
+				},
+			},
+			entry,
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+/*
+ListPendingErasures returns up to limit tombstones of accounts whose erasure started at or before the given time and
+has not finished yet. It scans the sparse `PendingIndex` GSI, which only holds tombstones with pendingSince set, so the
+scan stays cheap however many accounts were deleted.
+*/
+func (repo *DynamoDBRepository) ListPendingErasures(before int64, limit int) ([]models.Tombstone, error) {
+	filter := expression.Name("pendingSince").LessThanEqual(expression.Value(before))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(tombstonesTable),
+		IndexName:                 aws.String("PendingIndex"),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}
+
+	var tombstones []models.Tombstone
+	var decodeErr error
+	err = repo.Client.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageTombstones []models.Tombstone
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageTombstones); decodeErr != nil {
+			return false
+		}
+		tombstones = append(tombstones, pageTombstones...)
+		return len(tombstones) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	if len(tombstones) > limit {
+		tombstones = tombstones[:limit]
+	}
+	return tombstones, nil
+}
+
+// DeleteUserSessions deletes every session of the user, found through the `UserIndex` GSI of the sessions table.
+func (repo *DynamoDBRepository) DeleteUserSessions(userID string) error {
+	keys, err := repo.queryKeys(sessionsTable, aws.String("UserIndex"), "UserID", userID, "SessionID")
+	if err != nil {
+		return err
+	}
+	return repo.batchDelete(sessionsTable, keys)
+}
+
+/*
+DeleteUserSwipes deletes the user's swipes on others, and other users' swipes on them through the `SwipedUserIndex`
+GSI. It returns the IDs of the users whose swipes on them were deleted, whose swipe sets no longer match their history.
+A swipe on themselves is found by both queries, and is only deleted once.
+*/
+func (repo *DynamoDBRepository) DeleteUserSwipes(userID string) ([]string, error) {
+	own, err := repo.queryKeys(swipesTable, nil, "UserID", userID, "UserID", "SwipedUserID")
+	if err != nil {
+		return nil, err
+	}
+	received, err := repo.queryKeys(swipesTable, aws.String("SwipedUserIndex"), "SwipedUserID", userID, "UserID", "SwipedUserID")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = repo.batchDelete(swipesTable, uniqueKeys(append(own, received...), "UserID", "SwipedUserID")); err != nil {
+		return nil, err
+	}
+
+	swiperIDs := make([]string, 0, len(received))
+	for _, key := range received {
+		if swiperID := aws.StringValue(key["UserID"].S); swiperID != userID {
+			swiperIDs = append(swiperIDs, swiperID)
+		}
+	}
+	return swiperIDs, nil
+}
+
+/*
+DeleteUserMatches deletes every match the user is part of, on both sides, together with the messages of those
+conversations. The messages go first, so a failure part way leaves the match to find them again on the next attempt.
+*/
+func (repo *DynamoDBRepository) DeleteUserMatches(userID string) error {
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).Build()
+	if err != nil {
+		return err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(matchesTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+	}
+
+	var matches []models.Match
+	var decodeErr error
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageMatches []models.Match
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageMatches); decodeErr != nil {
+			return false
+		}
+		matches = append(matches, pageMatches...)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+
+	for _, match := range matches {
+		if err = repo.deleteMessages(match.MatchID); err != nil {
+			return err
+		}
+
+		err = repo.batchDelete(matchesTable, []map[string]*dynamodb.AttributeValue{
+			{"UserID": {S: aws.String(match.MatchedUserID)}, "MatchID": {S: aws.String(match.MatchID)}},
+			{"UserID": {S: aws.String(match.UserID)}, "MatchID": {S: aws.String(match.MatchID)}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteMessages deletes every message of a match's conversation.
+func (repo *DynamoDBRepository) deleteMessages(matchID string) error {
+	keys, err := repo.queryKeys(messagesTable, nil, "MatchID", matchID, "MatchID", "MessageID")
+	if err != nil {
+		return err
+	}
+	return repo.batchDelete(messagesTable, keys)
+}
+
+/*
+DeleteUserBlocks deletes the blocks the user made and the blocks made against them, through `BlockedUserIndex`. A block
+of themselves is found by both queries, and is only deleted once.
+*/
+func (repo *DynamoDBRepository) DeleteUserBlocks(userID string) error {
+	made, err := repo.queryKeys(blocksTable, nil, "UserID", userID, "UserID", "BlockedUserID")
+	if err != nil {
+		return err
+	}
+	received, err := repo.queryKeys(blocksTable, aws.String("BlockedUserIndex"), "BlockedUserID", userID, "UserID", "BlockedUserID")
+	if err != nil {
+		return err
+	}
+	return repo.batchDelete(blocksTable, uniqueKeys(append(made, received...), "UserID", "BlockedUserID"))
+}
+
+/*
+CompleteErasure marks the erasure of a deleted account as finished and records the audit entry, in one transaction.
+The tombstone itself is kept. Completing an erasure that was already completed does nothing, so the audit entry is
+only written once.
+*/
+func (repo *DynamoDBRepository) CompleteErasure(tombstone models.Tombstone, entry models.AuditEntry) error {
+	update := expression.Set(expression.Name("erasedAt"), expression.Value(entry.CreatedAt)).
+		Remove(expression.Name("pendingSince")).
+		Remove(expression.Name("photoKeys"))
+	cond := expression.AttributeExists(expression.Name("pendingSince"))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(tombstonesTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID": {S: aws.String(tombstone.UserID)},
+					},
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(auditTable),
+					Item:                av,
+					ConditionExpression: aws.String("attribute_not_exists(AuditID)"),
+				},
+			},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+/*
+queryKeys returns the primary keys of every item whose keyName equals value, in the table or in the given index.
+tableKey names the attributes of the table's primary key, which every index projects.
+*/
+func (repo *DynamoDBRepository) queryKeys(table string, index *string, keyName, value string, tableKey ...string) ([]map[string]*dynamodb.AttributeValue, error) {
+	keyCond := expression.Key(keyName).Equal(expression.Value(value))
+	proj := expression.NamesList(expression.Name(tableKey[0]))
+	for _, name := range tableKey[1:] {
+		proj = proj.AddNames(expression.Name(name))
+	}
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithProjection(proj).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(table),
+		IndexName:                 index,
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ProjectionExpression:      expr.Projection(),
+	}
+
+	var keys []map[string]*dynamodb.AttributeValue
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		keys = append(keys, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+/*
+uniqueKeys drops repeated primary keys, which DynamoDB refuses within one BatchWriteItem request. tableKey names the
+attributes of the table's primary key.
+*/
+func uniqueKeys(keys []map[string]*dynamodb.AttributeValue, tableKey ...string) []map[string]*dynamodb.AttributeValue {
+	seen := make(map[string]bool, len(keys))
+	unique := keys[:0:0]
+	for _, key := range keys {
+		var id string
+		for _, name := range tableKey {
+			id += aws.StringValue(key[name].S) + "\x00"
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+/*
+batchDelete deletes the items with the given primary keys, maxBatchWriteItems at a time. Items DynamoDB leaves
+unprocessed, for example when throttled, are retried with a growing delay, up to maxBatchWriteAttempts times. Items
+still left after that are returned as an error, so the caller can try again later.
+*/
+func (repo *DynamoDBRepository) batchDelete(table string, keys []map[string]*dynamodb.AttributeValue) error {
+	for start := 0; start < len(keys); start += maxBatchWriteItems {
+		var requests []*dynamodb.WriteRequest
+		for _, key := range keys[start:min(start+maxBatchWriteItems, len(keys))] {
+			requests = append(requests, &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}})
+		}
+
+		pending := map[string][]*dynamodb.WriteRequest{table: requests}
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt == maxBatchWriteAttempts {
+				return fmt.Errorf("%d deletes from %s still unprocessed after %d attempts", len(pending[table]), table, attempt)
+			}
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt) * 50 * time.Millisecond)
+			}
+			result, err := repo.Client.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			pending = result.UnprocessedItems
+		}
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+package repository
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"quick-match/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+fakeDynamoDB serves the DynamoDB operations that messaging, unmatching, erasure and export jobs use, from memory, so
+those can be tested without LocalStack. Conditions are only understood as far as those callers use them: attribute_exists and
+attribute_not_exists of the item. Updates are not applied, since the tests never read what they change. With
+throttled set, BatchWriteItem leaves every item unprocessed.
+*/
+type fakeDynamoDB struct {
+	mu        sync.Mutex
+	keys      map[string][]string
+	tables    map[string]map[string]map[string]*dynamodb.AttributeValue
+	throttled bool
+}
+
+var keyConditionPattern = regexp.MustCompile(`^(#\w+) = (:\w+)$`)
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{
+		keys: map[string][]string{
+			matchesTable:  {"UserID", "MatchID"},
+			messagesTable: {"MatchID", "MessageID"},
+			swipesTable:   {"UserID", "SwipedUserID"},
+			blocksTable:   {"UserID", "BlockedUserID"},
+			exportsTable:  {"UserID", "ExportID"},
+		},
+		tables: map[string]map[string]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+func (f *fakeDynamoDB) keyOf(table string, item map[string]*dynamodb.AttributeValue) string {
+	var parts []string
+	for _, name := range f.keys[table] {
+		parts = append(parts, aws.StringValue(item[name].S))
+	}
+	return strings.Join(parts, "|")
+}
+
+func (f *fakeDynamoDB) put(t *testing.T, table string, value any) {
+	item, err := dynamodbattribute.MarshalMap(value)
+	require.NoError(t, err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tables[table] == nil {
+		f.tables[table] = map[string]map[string]*dynamodb.AttributeValue{}
+	}
+	f.tables[table][f.keyOf(table, item)] = item
+}
+
+func (f *fakeDynamoDB) exists(table string, key map[string]*dynamodb.AttributeValue) bool {
+	_, ok := f.tables[table][f.keyOf(table, key)]
+	return ok
+}
+
+// conditionHolds checks a condition on the item with the given key, as far as the fake understands conditions.
+func (f *fakeDynamoDB) conditionHolds(table string, key map[string]*dynamodb.AttributeValue, condition *string) bool {
+	switch {
+	case condition == nil:
+		return true
+	case strings.HasPrefix(*condition, "attribute_not_exists"):
+		return !f.exists(table, key)
+	case strings.HasPrefix(*condition, "attribute_exists"):
+		return f.exists(table, key)
+	}
+	return true
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var output any
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.") {
+	case "GetItem":
+		var input dynamodb.GetItemInput
+		json.NewDecoder(r.Body).Decode(&input)
+		output = &dynamodb.GetItemOutput{Item: f.tables[*input.TableName][f.keyOf(*input.TableName, input.Key)]}
+	case "Query":
+		var input dynamodb.QueryInput
+		json.NewDecoder(r.Body).Decode(&input)
+		output = &dynamodb.QueryOutput{Items: f.query(&input)}
+	case "BatchWriteItem":
+		var input dynamodb.BatchWriteItemInput
+		json.NewDecoder(r.Body).Decode(&input)
+		if f.throttled {
+			output = &dynamodb.BatchWriteItemOutput{UnprocessedItems: input.RequestItems}
+			break
+		}
+		for table, requests := range input.RequestItems {
+			seen := map[string]bool{}
+			for _, request := range requests {
+				key := f.keyOf(table, request.DeleteRequest.Key)
+				if seen[key] {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{
+						"__type":  "com.amazon.coral.validate#ValidationException",
+						"message": "Provided list of item keys contains duplicates",
+					})
+					return
+				}
+				seen[key] = true
+			}
+		}
+		for table, requests := range input.RequestItems {
+			for _, request := range requests {
+				delete(f.tables[table], f.keyOf(table, request.DeleteRequest.Key))
+			}
+		}
+		output = &dynamodb.BatchWriteItemOutput{}
+	case "TransactWriteItems":
+		var input dynamodb.TransactWriteItemsInput
+		json.NewDecoder(r.Body).Decode(&input)
+		if !f.transactWrite(&input) {
+			reasons := make([]map[string]string, len(input.TransactItems))
+			for i, item := range input.TransactItems {
+				reasons[i] = map[string]string{"Code": "None"}
+				if !f.itemConditionHolds(item) {
+					reasons[i]["Code"] = "ConditionalCheckFailed"
+				}
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"__type":              "com.amazonaws.dynamodb.v20120810#TransactionCanceledException",
+				"message":             "Transaction cancelled",
+				"CancellationReasons": reasons,
+			})
+			return
+		}
+		output = &dynamodb.TransactWriteItemsOutput{}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "com.amazonaws.dynamodb.v20120810#UnknownOperationException",
+			"message": "not supported by the fake",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(output)
+}
+
+func (f *fakeDynamoDB) query(input *dynamodb.QueryInput) []map[string]*dynamodb.AttributeValue {
+	match := keyConditionPattern.FindStringSubmatch(aws.StringValue(input.KeyConditionExpression))
+	name := aws.StringValue(input.ExpressionAttributeNames[match[1]])
+	value := aws.StringValue(input.ExpressionAttributeValues[match[2]].S)
+
+	var projection []string
+	if input.ProjectionExpression != nil {
+		for _, placeholder := range strings.Split(*input.ProjectionExpression, ", ") {
+			projection = append(projection, aws.StringValue(input.ExpressionAttributeNames[placeholder]))
+		}
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range f.tables[*input.TableName] {
+		if aws.StringValue(item[name].S) != value {
+			continue
+		}
+		if projection == nil {
+			items = append(items, item)
+			continue
+		}
+		projected := map[string]*dynamodb.AttributeValue{}
+		for _, attribute := range projection {
+			projected[attribute] = item[attribute]
+		}
+		items = append(items, projected)
+	}
+	return items
+}
+
+func (f *fakeDynamoDB) itemConditionHolds(item *dynamodb.TransactWriteItem) bool {
+	switch {
+	case item.Put != nil:
+		return f.conditionHolds(*item.Put.TableName, item.Put.Item, item.Put.ConditionExpression)
+	case item.Delete != nil:
+		return f.conditionHolds(*item.Delete.TableName, item.Delete.Key, item.Delete.ConditionExpression)
+	case item.ConditionCheck != nil:
+		return f.conditionHolds(*item.ConditionCheck.TableName, item.ConditionCheck.Key, item.ConditionCheck.ConditionExpression)
+	}
+	return true
+}
+
+// transactWrite applies the puts and deletes of a transaction if all of its conditions hold, and reports whether they did.
+func (f *fakeDynamoDB) transactWrite(input *dynamodb.TransactWriteItemsInput) bool {
+	for _, item := range input.TransactItems {
+		if !f.itemConditionHolds(item) {
+			return false
+		}
+	}
+	for _, item := range input.TransactItems {
+		switch {
+		case item.Put != nil:
+			table := *item.Put.TableName
+			if f.tables[table] == nil {
+				f.tables[table] = map[string]map[string]*dynamodb.AttributeValue{}
+			}
+			f.tables[table][f.keyOf(table, item.Put.Item)] = item.Put.Item
+		case item.Delete != nil:
+			delete(f.tables[*item.Delete.TableName], f.keyOf(*item.Delete.TableName, item.Delete.Key))
+		}
+	}
+	return true
+}
+
+// messageIDs returns the IDs of the messages stored for a match.
+func (f *fakeDynamoDB) messageIDs(matchID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, item := range f.tables[messagesTable] {
+		if aws.StringValue(item["MatchID"].S) == matchID {
+			ids = append(ids, aws.StringValue(item["MessageID"].S))
+		}
+	}
+	return ids
+}
+
+func (f *fakeDynamoDB) count(table string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tables[table])
+}
+
+// newFakeRepository returns a repository backed by a fakeDynamoDB holding two matches of user1: match1 with user2 and
+// match2 with user3, each with a conversation.
+func newFakeRepository(t *testing.T) (*DynamoDBRepository, *fakeDynamoDB) {
+	fake := newFakeDynamoDB()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("test", "test", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(t, err)
+	repo := NewDynamoDBRepository(dynamodb.New(sess))
+
+	for _, match := range []models.Match{
+		{UserID: "user1", MatchID: "match1", MatchedUserID: "user2"},
+		{UserID: "user2", MatchID: "match1", MatchedUserID: "user1"},
+		{UserID: "user1", MatchID: "match2", MatchedUserID: "user3"},
+		{UserID: "user3", MatchID: "match2", MatchedUserID: "user1"},
+	} {
+		fake.put(t, matchesTable, match)
+	}
+	for _, message := range []models.Message{
+		{MatchID: "match1", MessageID: "m1", SenderID: "user1", Body: "hi"},
+		{MatchID: "match1", MessageID: "m2", SenderID: "user2", Body: "hello"},
+		{MatchID: "match1", MessageID: "m3", SenderID: "user1", Body: "how are you?"},
+		{MatchID: "match2", MessageID: "m4", SenderID: "user3", Body: "hey"},
+	} {
+		fake.put(t, messagesTable, message)
+	}
+
+	return &repo, fake
+}
+
+func TestUnmatchDeletesConversation(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+
+	match, err := repo.Unmatch("user2", "match1")
+	require.NoError(t, err)
+	require.NotNil(t, match)
+
+	assert.Empty(t, fake.messageIDs("match1"))
+	assert.Equal(t, []string{"m4"}, fake.messageIDs("match2"))
+	assert.Equal(t, 2, fake.count(matchesTable))
+}
+
+func TestInsertMessageAfterUnmatch(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+
+	_, err := repo.Unmatch("user1", "match1")
+	require.NoError(t, err)
+
+	err = repo.InsertMessage(models.Message{MatchID: "match1", MessageID: "m5", SenderID: "user2", Body: "wait"})
+	assert.ErrorIs(t, err, ErrMatchNotFound)
+	assert.Empty(t, fake.messageIDs("match1"))
+
+	err = repo.InsertMessage(models.Message{MatchID: "match2", MessageID: "m5", SenderID: "user1", Body: "hey"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"m4", "m5"}, fake.messageIDs("match2"))
+}
+
+// Erasure finds messages through the user's matches, so none may outlive a match that was removed before
+func TestDeleteUserMatchesAfterUnmatch(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+
+	_, err := repo.Unmatch("user1", "match1")
+	require.NoError(t, err)
+
+	require.NoError(t, repo.DeleteUserMatches("user1"))
+
+	assert.Zero(t, fake.count(messagesTable))
+	assert.Zero(t, fake.count(matchesTable))
+}
+
+// A swipe on oneself is found both as a swipe made and as a swipe received, and must not be deleted twice in one batch
+func TestDeleteUserSwipesWithSelfSwipe(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+	for _, swipe := range []models.Swipe{
+		{UserID: "user1", SwipedUserID: "user1", Preference: true},
+		{UserID: "user1", SwipedUserID: "user2", Preference: true},
+		{UserID: "user3", SwipedUserID: "user1", Preference: false},
+		{UserID: "user2", SwipedUserID: "user3", Preference: true},
+	} {
+		fake.put(t, swipesTable, swipe)
+	}
+
+	swiperIDs, err := repo.DeleteUserSwipes("user1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"user3"}, swiperIDs)
+	assert.Equal(t, 1, fake.count(swipesTable))
+}
+
+func TestDeleteUserBlocksWithSelfBlock(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+	for _, block := range []models.Block{
+		{UserID: "user1", BlockedUserID: "user1"},
+		{UserID: "user1", BlockedUserID: "user2"},
+		{UserID: "user3", BlockedUserID: "user1"},
+		{UserID: "user2", BlockedUserID: "user3"},
+	} {
+		fake.put(t, blocksTable, block)
+	}
+
+	require.NoError(t, repo.DeleteUserBlocks("user1"))
+
+	assert.Equal(t, 1, fake.count(blocksTable))
+}
+
+// Erasure is rescheduled on an error, so deletes DynamoDB keeps leaving unprocessed must not be retried forever
+func TestBatchDeleteGivesUpOnUnprocessedItems(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+	fake.put(t, blocksTable, models.Block{UserID: "user1", BlockedUserID: "user2"})
+	fake.throttled = true
+
+	err := repo.DeleteUserBlocks("user1")
+
+	assert.ErrorContains(t, err, "unprocessed")
+	assert.Equal(t, 1, fake.count(blocksTable))
+}
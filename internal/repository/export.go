@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"quick-match/internal/models"
+)
+
+const exportsTable = "quickmatch_exports"
+
+/*
+pendingExportKey is the ExportID of the item marking that a user has an export job pending, which holds that job's ID
+in pendingExportId. It is written together with the job and removed once the job is finished, so a user can never have
+two jobs pending at once. It has no status, so it is never mistaken for a job.
+*/
+const pendingExportKey = "#pending"
+
+func pendingExportMarkerKey(userID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"UserID":   {S: aws.String(userID)},
+		"ExportID": {S: aws.String(pendingExportKey)},
+	}
+}
+
+// InsertExportJob stores a new pending export job. ErrExportPending is returned if the user already has one pending.
+func (repo *DynamoDBRepository) InsertExportJob(job models.ExportJob) error {
+	av, err := dynamodbattribute.MarshalMap(job)
+	if err != nil {
+		return err
+	}
+	marker := pendingExportMarkerKey(job.UserID)
+	marker["pendingExportId"] = &dynamodb.AttributeValue{S: aws.String(job.ExportID)}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(exportsTable),
+					Item:                marker,
+					ConditionExpression: aws.String("attribute_not_exists(ExportID)"),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(exportsTable),
+					Item:                av,
+					ConditionExpression: aws.String("attribute_not_exists(ExportID)"),
+				},
+			},
+		},
+	})
+	var canceled *dynamodb.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 0 &&
+		aws.StringValue(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+		return ErrExportPending
+	}
+	return err
+}
+
+// GetExportJob returns the user's export job with the given ID, or nil if the user has none with that ID.
+func (repo *DynamoDBRepository) GetExportJob(userID, exportID string) (*models.ExportJob, error) {
+	if exportID == pendingExportKey {
+		return nil, nil
+	}
+
+	result, err := repo.Client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(exportsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID":   {S: aws.String(userID)},
+			"ExportID": {S: aws.String(exportID)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var job models.ExportJob
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &job); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// GetPendingExportJob returns one of the user's export jobs that is still being built, or nil if there is none.
+func (repo *DynamoDBRepository) GetPendingExportJob(userID string) (*models.ExportJob, error) {
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	filter := expression.Name("status").Equal(expression.Value(models.ExportStatusPending))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(exportsTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	var job *models.ExportJob
+	var decodeErr error
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(page.Items) == 0 {
+			return true
+		}
+		job = &models.ExportJob{}
+		decodeErr = dynamodbattribute.UnmarshalMap(page.Items[0], job)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return job, nil
+}
+
+/*
+ListDueExportJobs returns up to limit export jobs whose next attempt is due at now. It scans the sparse `PendingIndex`
+GSI, which only holds jobs that still have to be built.
+*/
+func (repo *DynamoDBRepository) ListDueExportJobs(now int64, limit int) ([]models.ExportJob, error) {
+	return repo.scanExportIndex("PendingIndex", "nextAttemptAt", now, limit)
+}
+
+/*
+ListExpiredExportJobs returns up to limit export jobs whose archive expired at now. It scans the sparse
+`ArchiveExpiryIndex` GSI, which only holds jobs whose archive is still stored.
+*/
+func (repo *DynamoDBRepository) ListExpiredExportJobs(now int64, limit int) ([]models.ExportJob, error) {
+	return repo.scanExportIndex("ArchiveExpiryIndex", "archiveExpiresAt", now, limit)
+}
+
+func (repo *DynamoDBRepository) scanExportIndex(index, attribute string, now int64, limit int) ([]models.ExportJob, error) {
+	filter := expression.Name(attribute).LessThanEqual(expression.Value(now))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(exportsTable),
+		IndexName:                 aws.String(index),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	}
+
+	var jobs []models.ExportJob
+	var decodeErr error
+	err = repo.Client.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageJobs []models.ExportJob
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageJobs); decodeErr != nil {
+			return false
+		}
+		jobs = append(jobs, pageJobs...)
+		return len(jobs) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+/*
+CompleteExportJob records that the job's archive is built and stored, as given by the job's CompletedAt, ExpiresAt and
+ArchiveKey. ErrExportNotPending is returned if the job was completed or given up on in the meantime, in which case the
+archive is not referenced by the job.
+*/
+func (repo *DynamoDBRepository) CompleteExportJob(job models.ExportJob) error {
+	update := expression.Set(expression.Name("status"), expression.Value(models.ExportStatusReady)).
+		Set(expression.Name("completedAt"), expression.Value(job.CompletedAt)).
+		Set(expression.Name("expiresAt"), expression.Value(job.ExpiresAt)).
+		Set(expression.Name("archiveKey"), expression.Value(job.ArchiveKey)).
+		Set(expression.Name("archiveExpiresAt"), expression.Value(job.ExpiresAt)).
+		Remove(expression.Name("nextAttemptAt"))
+
+	err := repo.finishExportJob(job, update)
+	if isConditionalCheckFailed(err) {
+		return ErrExportNotPending
+	}
+	return err
+}
+
+// RescheduleExportJob records a failed attempt to build the job's archive and when to try again.
+func (repo *DynamoDBRepository) RescheduleExportJob(job models.ExportJob, nextAttemptAt int64) error {
+	update := expression.Set(expression.Name("attempts"), expression.Value(job.Attempts+1)).
+		Set(expression.Name("nextAttemptAt"), expression.Value(nextAttemptAt))
+
+	err := repo.updateExportJob(job, update, expression.AttributeExists(expression.Name("nextAttemptAt")))
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// FailExportJob gives up on building the job's archive.
+func (repo *DynamoDBRepository) FailExportJob(job models.ExportJob, failedAt int64) error {
+	update := expression.Set(expression.Name("status"), expression.Value(models.ExportStatusFailed)).
+		Set(expression.Name("completedAt"), expression.Value(failedAt)).
+		Remove(expression.Name("nextAttemptAt"))
+
+	err := repo.finishExportJob(job, update)
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// ExpireExportJob records that the job's archive was deleted.
+func (repo *DynamoDBRepository) ExpireExportJob(job models.ExportJob) error {
+	update := expression.Set(expression.Name("status"), expression.Value(models.ExportStatusExpired)).
+		Remove(expression.Name("archiveKey")).
+		Remove(expression.Name("archiveExpiresAt"))
+
+	err := repo.updateExportJob(job, update, expression.AttributeExists(expression.Name("ExportID")))
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// ListUserExportJobs returns all of the user's export jobs, whatever their status.
+func (repo *DynamoDBRepository) ListUserExportJobs(userID string) ([]models.ExportJob, error) {
+	keyCond := expression.Key("UserID").Equal(expression.Value(userID))
+	filter := expression.AttributeExists(expression.Name("status"))
+	expr, err := expression.NewBuilder().WithKeyCondition(keyCond).WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:                 aws.String(exportsTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+		FilterExpression:          expr.Filter(),
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	var jobs []models.ExportJob
+	var decodeErr error
+	err = repo.Client.QueryPages(queryInput, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var pageJobs []models.ExportJob
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageJobs); decodeErr != nil {
+			return false
+		}
+		jobs = append(jobs, pageJobs...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return jobs, nil
+}
+
+// DeleteExportJobs deletes the jobs, and the pending markers of their users.
+func (repo *DynamoDBRepository) DeleteExportJobs(jobs []models.ExportJob) error {
+	var keys []map[string]*dynamodb.AttributeValue
+	markers := make(map[string]bool)
+	for _, job := range jobs {
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			"UserID":   {S: aws.String(job.UserID)},
+			"ExportID": {S: aws.String(job.ExportID)},
+		})
+		if !markers[job.UserID] {
+			markers[job.UserID] = true
+			keys = append(keys, pendingExportMarkerKey(job.UserID))
+		}
+	}
+	return repo.batchDelete(exportsTable, keys)
+}
+
+/*
+finishExportJob applies update to a job that is still pending, and removes the user's pending marker in the same
+transaction so they can request a new export. The marker is removed whatever job it names: while this job is pending it
+can only name this one.
+*/
+func (repo *DynamoDBRepository) finishExportJob(job models.ExportJob, update expression.UpdateBuilder) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(expression.AttributeExists(expression.Name("nextAttemptAt"))).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(exportsTable),
+					Key: map[string]*dynamodb.AttributeValue{
+						"UserID":   {S: aws.String(job.UserID)},
+						"ExportID": {S: aws.String(job.ExportID)},
+					},
+					ExpressionAttributeNames:  expr.Names(),
+					ExpressionAttributeValues: expr.Values(),
+					UpdateExpression:          expr.Update(),
+					ConditionExpression:       expr.Condition(),
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(exportsTable),
+					Key:       pendingExportMarkerKey(job.UserID),
+				},
+			},
+		},
+	})
+	return err
+}
+
+func (repo *DynamoDBRepository) updateExportJob(job models.ExportJob, update expression.UpdateBuilder, cond expression.ConditionBuilder) error {
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(exportsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID":   {S: aws.String(job.UserID)},
+			"ExportID": {S: aws.String(job.ExportID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	return err
+}
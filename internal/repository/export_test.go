@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"quick-match/internal/models"
+	"testing"
+)
+
+func TestInsertExportJobWhilePending(t *testing.T) {
+	repo, fake := newFakeRepository(t)
+	job := func(exportID string) models.ExportJob {
+		return models.ExportJob{UserID: "user1", ExportID: exportID, Status: models.ExportStatusPending, NextAttemptAt: 100}
+	}
+
+	require.NoError(t, repo.InsertExportJob(job("export1")))
+	assert.ErrorIs(t, repo.InsertExportJob(job("export2")), ErrExportPending)
+
+	// Other users have their own marker
+	other := job("export3")
+	other.UserID = "user2"
+	require.NoError(t, repo.InsertExportJob(other))
+
+	// Once the pending job is finished, a new one can be queued
+	require.NoError(t, repo.CompleteExportJob(job("export1")))
+	require.NoError(t, repo.InsertExportJob(job("export2")))
+	require.NoError(t, repo.FailExportJob(job("export2"), 200))
+	require.NoError(t, repo.InsertExportJob(job("export4")))
+
+	// The marker is never served as a job, and goes with the user's jobs
+	marker, err := repo.GetExportJob("user1", pendingExportKey)
+	require.NoError(t, err)
+	assert.Nil(t, marker)
+	require.NoError(t, repo.DeleteExportJobs([]models.ExportJob{job("export1"), job("export2"), job("export4"), other}))
+	assert.Zero(t, fake.count(exportsTable))
+}
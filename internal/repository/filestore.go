@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileBlobStore keeps blobs as files below a directory, for local development. Serve the directory with FileServer.
+type FileBlobStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewFileBlobStore(dir, baseURL string) FileBlobStore {
+	return FileBlobStore{
+		Dir:     dir,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (store *FileBlobStore) Put(key string, data []byte, contentType string) error {
+	path := store.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Written to a temporary file first, so a blob is never served half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads the blob back. ErrBlobNotFound is returned if there is none.
+func (store *FileBlobStore) Get(key string) ([]byte, error) {
+	data, err := os.ReadFile(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+// Delete removes the blob. Deleting a blob that does not exist is not an error.
+func (store *FileBlobStore) Delete(key string) error {
+	err := os.Remove(store.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (store *FileBlobStore) URL(key string) string {
+	return store.BaseURL + "/" + key
+}
+
+// FileServer serves the stored blobs. Directory listings are not served, so blobs can only be fetched by their key.
+func (store *FileBlobStore) FileServer() http.Handler {
+	files := http.FileServer(http.Dir(store.Dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// path maps a key to a file below Dir. Keys are cleaned first, so they cannot point outside of it.
+func (store *FileBlobStore) path(key string) string {
+	return filepath.Join(store.Dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
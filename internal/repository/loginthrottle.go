@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"quick-match/internal/models"
+	"time"
+)
+
+const loginThrottleTable = "quickmatch_login_throttle"
+
+// GetLoginThrottle returns the failed logins counted against key, or nil if there are none.
+func (repo *DynamoDBRepository) GetLoginThrottle(key string) (*models.LoginThrottle, error) {
+	result, err := repo.Client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(loginThrottleTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ThrottleKey": {S: aws.String(key)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	var throttle models.LoginThrottle
+	if err = dynamodbattribute.UnmarshalMap(result.Item, &throttle); err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+/*
+RecordLoginAttempt counts a login attempt against key at now, before its password is checked. seen is the throttle the
+caller read with GetLoginThrottle and decided the attempt on: the write is refused with ErrLoginThrottleChanged if the
+counter changed since then, so concurrent attempts are each decided on the count that includes the ones before them.
+Attempts keep counting until window has passed since the last one, after which the counter starts again from one. The
+TTL removes the item some time later.
+*/
+func (repo *DynamoDBRepository) RecordLoginAttempt(key string, seen *models.LoginThrottle, now int64, window time.Duration) error {
+	expiresAt := now + int64(window.Seconds())
+
+	// Items past their expiry can still be around, since the TTL deletes them lazily
+	if seen == nil || seen.ExpiresAt <= now {
+		av, err := dynamodbattribute.MarshalMap(models.LoginThrottle{
+			Key:           key,
+			Failures:      1,
+			LastFailureAt: now,
+			ExpiresAt:     expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		expr, err := expression.NewBuilder().WithCondition(
+			expression.AttributeNotExists(expression.Name("ThrottleKey")).Or(expression.Name("expiresAt").LessThanEqual(expression.Value(now))),
+		).Build()
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.Client.PutItem(&dynamodb.PutItemInput{
+			TableName:                 aws.String(loginThrottleTable),
+			Item:                      av,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ConditionExpression:       expr.Condition(),
+		})
+		if isConditionalCheckFailed(err) {
+			return ErrLoginThrottleChanged
+		}
+		return err
+	}
+
+	update := expression.Add(expression.Name("failures"), expression.Value(1)).
+		Set(expression.Name("lastFailureAt"), expression.Value(now)).
+		Set(expression.Name("expiresAt"), expression.Value(expiresAt))
+	cond := expression.Name("failures").Equal(expression.Value(seen.Failures)).
+		And(expression.Name("lastFailureAt").Equal(expression.Value(seen.LastFailureAt))).
+		And(expression.Name("expiresAt").GreaterThan(expression.Value(now)))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(loginThrottleTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ThrottleKey": {S: aws.String(key)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if isConditionalCheckFailed(err) {
+		return ErrLoginThrottleChanged
+	}
+	return err
+}
+
+/*
+ReleaseLoginAttempt takes back an attempt counted by RecordLoginAttempt that turned out not to be a failure. Nothing
+is taken back from a counter that expired or was cleared in the meantime.
+*/
+func (repo *DynamoDBRepository) ReleaseLoginAttempt(key string, now int64) error {
+	update := expression.Add(expression.Name("failures"), expression.Value(-1))
+	condition := expression.Name("expiresAt").GreaterThan(expression.Value(now)).
+		And(expression.Name("failures").GreaterThan(expression.Value(0)))
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(condition).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(loginThrottleTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ThrottleKey": {S: aws.String(key)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// ClearLoginFailures resets the failed logins recorded against key.
+func (repo *DynamoDBRepository) ClearLoginFailures(key string) error {
+	_, err := repo.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(loginThrottleTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"ThrottleKey": {S: aws.String(key)},
+		},
+	})
+	return err
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/google/uuid"
+	"quick-match/internal/models"
+	"time"
+)
+
+const outboxTable = "quickmatch_outbox"
+
+// outboxPut is the transaction item that records a change to the user in the outbox, due for indexing straight away.
+func outboxPut(userID string) (*dynamodb.TransactWriteItem, error) {
+	av, err := dynamodbattribute.MarshalMap(models.OutboxEntry{
+		UserID:        userID,
+		ChangeID:      uuid.New().String(),
+		NextAttemptAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: aws.String(outboxTable),
+			Item:      av,
+		},
+	}, nil
+}
+
+/*
+updateUser applies an update to a user and records the change in the outbox in one transaction, so the indexer picks
+up every change that commits. A failed condition of the update is reported like one from UpdateItem, so callers can
+check it with isConditionalCheckFailed.
+*/
+func (repo *DynamoDBRepository) updateUser(userID string, update *dynamodb.Update) error {
+	entry, err := outboxPut(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{{Update: update}, entry},
+	})
+	return err
+}
+
+/*
+ListDueOutboxEntries returns up to limit outbox entries whose next attempt is due at now. The outbox only holds users
+that still have to be indexed, so scanning it stays cheap.
+*/
+func (repo *DynamoDBRepository) ListDueOutboxEntries(now int64, limit int) ([]models.OutboxEntry, error) {
+	filter := expression.Name("nextAttemptAt").LessThanEqual(expression.Value(now))
+	expr, err := expression.NewBuilder().WithFilter(filter).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(outboxTable),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+		ConsistentRead:            aws.Bool(true),
+	}
+
+	var entries []models.OutboxEntry
+	var decodeErr error
+	err = repo.Client.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var pageEntries []models.OutboxEntry
+		if decodeErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageEntries); decodeErr != nil {
+			return false
+		}
+		entries = append(entries, pageEntries...)
+		return len(entries) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// DeleteOutboxEntry removes an entry once its user is indexed. An entry replaced by a newer change is kept.
+func (repo *DynamoDBRepository) DeleteOutboxEntry(entry models.OutboxEntry) error {
+	cond := expression.Name("changeId").Equal(expression.Value(entry.ChangeID))
+	expr, err := expression.NewBuilder().WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(outboxTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(entry.UserID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+/*
+RescheduleOutboxEntry records a failed attempt to index the entry's user and when to try again. An entry replaced by a
+newer change is left alone, since that change is due straight away.
+*/
+func (repo *DynamoDBRepository) RescheduleOutboxEntry(entry models.OutboxEntry, nextAttemptAt int64) error {
+	update := expression.Set(expression.Name("attempts"), expression.Value(entry.Attempts+1)).
+		Set(expression.Name("nextAttemptAt"), expression.Value(nextAttemptAt))
+	cond := expression.Name("changeId").Equal(expression.Value(entry.ChangeID))
+
+	expr, err := expression.NewBuilder().WithUpdate(update).WithCondition(cond).Build()
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(outboxTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"UserID": {S: aws.String(entry.UserID)},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ConditionExpression:       expr.Condition(),
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
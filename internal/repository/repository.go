@@ -1,14 +1,83 @@
 package repository
 
 import (
+	"errors"
 	"quick-match/internal/models"
+	"time"
 )
 
+// ErrSessionRotated is returned when a refresh token no longer matches the one stored for its session.
+var ErrSessionRotated = errors.New("session refresh token was already rotated or revoked")
+
+// ErrAlreadyMatched is returned when a swipe would overwrite a swipe that is part of a match.
+var ErrAlreadyMatched = errors.New("swipe is already part of a match")
+
+// ErrInvalidCursor is returned when a pagination cursor supplied by a client cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// ErrEmailTaken is returned when inserting a user whose email is already registered to another user.
+var ErrEmailTaken = errors.New("email is already registered")
+
+// ErrUserNotFound is returned when an update targets a user that does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrLocationRateLimited is returned when a user's location was changed too recently to be changed again.
+var ErrLocationRateLimited = errors.New("location was updated too recently")
+
+// LocationRateLimitedError is the ErrLocationRateLimited returned by UpdateLocation, along with when the location stored
+// at the time was set.
+type LocationRateLimitedError struct {
+	LocationUpdatedAt int64
+}
+
+func (e *LocationRateLimitedError) Error() string {
+	return ErrLocationRateLimited.Error()
+}
+
+func (e *LocationRateLimitedError) Unwrap() error {
+	return ErrLocationRateLimited
+}
+
+// ErrMatchNotFound is returned when writing to the conversation of a match that no longer exists.
+var ErrMatchNotFound = errors.New("match not found")
+
+// ErrPhotosChanged is returned when a user's photos were changed since they were read.
+var ErrPhotosChanged = errors.New("photos were changed concurrently")
+
+// ErrBlobNotFound is returned when reading a blob that does not exist.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrExportNotPending is returned when completing an export job that is no longer waiting to be built.
+var ErrExportNotPending = errors.New("export job is not pending")
+
+// ErrExportPending is returned when queueing an export job for a user who already has one pending.
+var ErrExportPending = errors.New("export job already pending")
+
+// ErrReportNotOpen is returned when resolving a report that was already resolved.
+var ErrReportNotOpen = errors.New("report is not open")
+
+// ErrLoginThrottleChanged is returned when counting a login attempt against a counter that changed since it was read.
+var ErrLoginThrottleChanged = errors.New("login throttle changed concurrently")
+
 type InsertUserRepo interface {
 	InsertUser(user models.UserDetails) error
 }
 
 type LoginUserRepo interface {
+	GetUserByEmail(email string) (*models.UserDetails, error)
+	UpdatePasswordHash(userID, oldHash, newHash string) error
+	InsertUserRepo
+}
+
+// LoginThrottleRepo counts failed logins per email and per client IP, so /login can slow down password guessing.
+type LoginThrottleRepo interface {
+	GetLoginThrottle(key string) (*models.LoginThrottle, error)
+	RecordLoginAttempt(key string, seen *models.LoginThrottle, now int64, window time.Duration) error
+	ReleaseLoginAttempt(key string, now int64) error
+	ClearLoginFailures(key string) error
+}
+
+type SignupUserRepo interface {
 	GetUserByEmail(email string) (*models.UserDetails, error)
 	InsertUserRepo
 }
@@ -19,7 +88,7 @@ type InsertUserESRepo interface {
 
 type SwipeRepo interface {
 	InsertSwipeRecord(swipe models.Swipe) error
-	CheckSwipeMatch(swipedUserID, currentUserID string) (bool, error)
+	MatchMutualLikes(userID, swipedUserID, matchID string, matchedAt int64) (string, bool, error)
 }
 
 type GetSwipedUserRepo interface {
@@ -28,5 +97,178 @@ type GetSwipedUserRepo interface {
 
 type DiscoverRepo interface {
 	GetUserByID(userID string) (models.UserDetailsES, error)
-	SearchUsers(currentUserLocation models.UserLocationES, swipedUserIDs []string, discover models.DiscoverFilters) ([]models.UserDetailsES, error)
+	SearchUsers(searcher models.UserDetailsES, blockedUserIDs []string, discover models.DiscoverFilters) (models.DiscoverPage, error)
+	SwipeSetExists(userID string) (bool, error)
+	ReplaceSwipeSet(userID string, swipedUserIDs []string) error
+	MergeIntoSwipeSet(userID string, swipedUserIDs []string) error
+}
+
+type DiscoverSettingsRepo interface {
+	GetUserByIDRepo
+	UpdateDiscoverSettings(userID string, settings models.DiscoverSettings) error
+}
+
+type CreateSessionRepo interface {
+	CreateSession(session models.Session) error
+}
+
+type GetSessionRepo interface {
+	GetSession(sessionID string) (*models.Session, error)
+}
+
+type RevokeSessionRepo interface {
+	RevokeSession(sessionID string) error
+}
+
+type RefreshSessionRepo interface {
+	GetSessionRepo
+	RevokeSessionRepo
+	RotateSession(sessionID, oldHash, newHash string, expiresAt int64) error
+}
+
+type ListMatchesRepo interface {
+	ListMatches(userID string, limit int, cursor string) (models.MatchPage, error)
+}
+
+type GetUsersESRepo interface {
+	GetUsersByIDs(userIDs []string) (map[string]models.UserDetailsES, error)
+}
+
+type GetMatchRepo interface {
+	GetMatch(userID, matchID string) (*models.Match, error)
+}
+
+type MessageRepo interface {
+	InsertMessage(message models.Message) error
+	ListMessages(matchID string, limit int, cursor string) (models.MessagePage, error)
+}
+
+type UnmatchRepo interface {
+	Unmatch(userID, matchID string) (*models.Match, error)
+}
+
+type BlockUserRepo interface {
+	BlockUser(userID, blockedUserID string, blockedAt int64) (string, error)
+}
+
+type BlockCheckRepo interface {
+	IsBlocked(userID, otherUserID string) (bool, error)
+}
+
+type BlockedUsersRepo interface {
+	GetBlockedUserIDs(userID string) ([]string, error)
+}
+
+type GetUserByIDRepo interface {
+	GetUserByID(userID string) (*models.UserDetails, error)
+}
+
+type ProfileRepo interface {
+	GetUserByIDRepo
+	UpdateProfile(user models.UserDetails) error
+}
+
+type ReindexUserESRepo interface {
+	ReindexUserES(user models.UserDetailsES) error
+}
+
+type LocationRepo interface {
+	GetUserByIDRepo
+	UpdateLocation(userID string, location models.Userlocation, updatedAt int64, minInterval time.Duration) error
+}
+
+type PhotoRepo interface {
+	GetUserByIDRepo
+	SavePhotos(userID string, photos []models.Photo, version int) error
+}
+
+// BlobStore stores binary objects, such as photos, under a key and serves them from a public URL.
+type BlobStore interface {
+	Put(key string, data []byte, contentType string) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// ArchiveStore keeps private binary objects, such as data exports, which are only read back through the API.
+type ArchiveStore interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+type ReportRepo interface {
+	GetUserByIDRepo
+	InsertReport(report models.Report) error
+}
+
+type ModerationRepo interface {
+	GetReport(reportID string) (*models.Report, error)
+	ListReports(status string, limit int, cursor string) (models.ReportPage, error)
+	ResolveReport(reportID, resolution, resolvedBy string, resolvedAt int64) error
+	SuspendUser(userID string, suspendedAt int64) error
+	RevokeUserSessions(userID string) error
+}
+
+type DeleteUserESRepo interface {
+	DeleteUserES(userID string) error
+}
+
+type LastActiveRepo interface {
+	UpdateLastActive(userID string, lastActiveAt int64) error
+}
+
+type SwipeSetRepo interface {
+	AddToSwipeSet(userID, swipedUserID string) error
+	DeleteSwipeSet(userID string) error
+}
+
+type OutboxRepo interface {
+	ListDueOutboxEntries(now int64, limit int) ([]models.OutboxEntry, error)
+	DeleteOutboxEntry(entry models.OutboxEntry) error
+	RescheduleOutboxEntry(entry models.OutboxEntry, nextAttemptAt int64) error
+}
+
+// IndexUserESRepo writes a user's document in the "users" index, or removes it.
+type IndexUserESRepo interface {
+	ReindexUserESRepo
+	DeleteUserESRepo
+}
+
+// ErasureRepo deletes a user's account and then erases the rest of their data, see the erasure package.
+type ErasureRepo interface {
+	DeleteUser(user models.UserDetails, tombstone models.Tombstone) error
+	ListPendingErasures(before int64, limit int) ([]models.Tombstone, error)
+	DeleteUserSessions(userID string) error
+	DeleteUserSwipes(userID string) ([]string, error)
+	DeleteUserMatches(userID string) error
+	DeleteUserBlocks(userID string) error
+	ListUserExportJobs(userID string) ([]models.ExportJob, error)
+	DeleteExportJobs(jobs []models.ExportJob) error
+	CompleteErasure(tombstone models.Tombstone, entry models.AuditEntry) error
+}
+
+// EraseUserESRepo removes a user's document from the "users" index, and swipe sets from the "swipes" index.
+type EraseUserESRepo interface {
+	DeleteUserESRepo
+	DeleteSwipeSet(userID string) error
+}
+
+type ExportJobRepo interface {
+	InsertExportJob(job models.ExportJob) error
+	GetExportJob(userID, exportID string) (*models.ExportJob, error)
+	GetPendingExportJob(userID string) (*models.ExportJob, error)
+}
+
+// ExporterRepo reads everything stored about a user and tracks the export jobs building it, see the export package.
+type ExporterRepo interface {
+	GetUserByIDRepo
+	ListMatchesRepo
+	ListSwipes(userID string) ([]models.Swipe, error)
+	ListMessages(matchID string, limit int, cursor string) (models.MessagePage, error)
+	ListDueExportJobs(now int64, limit int) ([]models.ExportJob, error)
+	CompleteExportJob(job models.ExportJob) error
+	RescheduleExportJob(job models.ExportJob, nextAttemptAt int64) error
+	FailExportJob(job models.ExportJob, failedAt int64) error
+	ListExpiredExportJobs(now int64, limit int) ([]models.ExportJob, error)
+	ExpireExportJob(job models.ExportJob) error
 }
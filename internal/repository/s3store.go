@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io"
+	"strings"
+)
+
+// S3BlobStore keeps blobs as objects in an S3 bucket. BaseURL is where the bucket's objects are publicly readable.
+type S3BlobStore struct {
+	Client  *s3.S3
+	Bucket  string
+	BaseURL string
+}
+
+func NewS3BlobStore(client *s3.S3, bucket, baseURL string) S3BlobStore {
+	return S3BlobStore{
+		Client:  client,
+		Bucket:  bucket,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (store *S3BlobStore) Put(key string, data []byte, contentType string) error {
+	_, err := store.Client.PutObject(&s3.PutObjectInput{
+		Bucket:       aws.String(store.Bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(data),
+		ContentType:  aws.String(contentType),
+		CacheControl: aws.String("public, max-age=31536000, immutable"),
+	})
+	return err
+}
+
+// Get reads the object back. ErrBlobNotFound is returned if there is none.
+func (store *S3BlobStore) Get(key string) ([]byte, error) {
+	result, err := store.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    aws.String(key),
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	return io.ReadAll(result.Body)
+}
+
+// Delete removes the object. S3 does not report deleting a missing object as an error.
+func (store *S3BlobStore) Delete(key string) error {
+	_, err := store.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (store *S3BlobStore) URL(key string) string {
+	return store.BaseURL + "/" + key
+}
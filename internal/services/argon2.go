@@ -0,0 +1,114 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// ErrMalformedArgon2idHash is returned when comparing against an argon2id hash that cannot be parsed.
+var ErrMalformedArgon2idHash = errors.New("malformed argon2id hash")
+
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams are the second recommended option of RFC 9106, for environments with less memory than 2 GiB.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+/*
+Argon2idPasswordService hashes passwords with argon2id. Hashes are stored in the PHC string format,
+"$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>", so each one carries the parameters it was made with.
+*/
+type Argon2idPasswordService struct {
+	Params Argon2idParams
+}
+
+func NewArgon2idPasswordService(params Argon2idParams) *Argon2idPasswordService {
+	return &Argon2idPasswordService{Params: params}
+}
+
+func (s *Argon2idPasswordService) GenerateHashedPassword(unhashedPassword string) (string, error) {
+	salt := make([]byte, s.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := s.Params
+	key := argon2.IDKey([]byte(unhashedPassword), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CompareHashAndPassword hashes password again with the parameters and salt stored in passwordHashed.
+func (s *Argon2idPasswordService) CompareHashAndPassword(passwordHashed, password string) error {
+	p, salt, key, err := decodeArgon2idHash(passwordHashed)
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports hashes made with other parameters than Params.
+func (s *Argon2idPasswordService) NeedsRehash(passwordHashed string) bool {
+	p, _, _, err := decodeArgon2idHash(passwordHashed)
+	return err != nil || p != s.Params
+}
+
+func (s *Argon2idPasswordService) Recognizes(passwordHashed string) bool {
+	return strings.HasPrefix(passwordHashed, "$argon2id$")
+}
+
+func decodeArgon2idHash(passwordHashed string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	parts := strings.Split(passwordHashed, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+	// argon2 panics on these rather than returning an error
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrMalformedArgon2idHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// testArgon2idParams keep the tests fast. Real hashes use DefaultArgon2idParams.
+var testArgon2idParams = Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	s := NewArgon2idPasswordService(testArgon2idParams)
+
+	hashed, err := s.GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$"))
+	assert.True(t, s.Recognizes(hashed))
+	assert.False(t, s.NeedsRehash(hashed))
+
+	assert.NoError(t, s.CompareHashAndPassword(hashed, "correcthorse"))
+	assert.ErrorIs(t, s.CompareHashAndPassword(hashed, "wronghorse"), ErrPasswordMismatch)
+	assert.ErrorIs(t, s.CompareHashAndPassword(hashed, ""), ErrPasswordMismatch)
+
+	// Every hash gets its own salt
+	other, err := s.GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hashed, other)
+}
+
+// The parameters stored in a hash are used to verify it, so hashes made before a parameter change keep working
+func TestArgon2idVerifiesHashesOfOtherParams(t *testing.T) {
+	old := NewArgon2idPasswordService(testArgon2idParams)
+	hashed, err := old.GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+
+	params := testArgon2idParams
+	params.Iterations = 2
+	current := NewArgon2idPasswordService(params)
+
+	assert.NoError(t, current.CompareHashAndPassword(hashed, "correcthorse"))
+	assert.ErrorIs(t, current.CompareHashAndPassword(hashed, "wronghorse"), ErrPasswordMismatch)
+}
+
+func TestArgon2idMalformedHashes(t *testing.T) {
+	s := NewArgon2idPasswordService(testArgon2idParams)
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	hash := func(version, params, salt, key string) string {
+		return fmt.Sprintf("$argon2id$%s$%s$%s$%s", version, params, salt, key)
+	}
+
+	tests := []struct {
+		name   string
+		hashed string
+	}{
+		{name: "bad version", hashed: hash("v=16", "m=64,t=1,p=1", salt, key)},
+		{name: "missing version", hashed: hash("m=64,t=1,p=1", "m=64,t=1,p=1", salt, key)},
+		{name: "zero iterations", hashed: hash("v=19", "m=64,t=0,p=1", salt, key)},
+		{name: "zero parallelism", hashed: hash("v=19", "m=64,t=1,p=0", salt, key)},
+		{name: "unparsable params", hashed: hash("v=19", "m=64;t=1;p=1", salt, key)},
+		{name: "bad base64 salt", hashed: hash("v=19", "m=64,t=1,p=1", "not*base64", key)},
+		{name: "bad base64 key", hashed: hash("v=19", "m=64,t=1,p=1", salt, "not*base64")},
+		{name: "empty key", hashed: hash("v=19", "m=64,t=1,p=1", salt, "")},
+		{name: "missing parts", hashed: "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{name: "other algorithm", hashed: "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, s.CompareHashAndPassword(tt.hashed, "correcthorse"), ErrMalformedArgon2idHash)
+			assert.True(t, s.NeedsRehash(tt.hashed))
+		})
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	hashed, err := NewArgon2idPasswordService(testArgon2idParams).GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		change   func(*Argon2idParams)
+		expected bool
+	}{
+		{name: "same params", change: func(p *Argon2idParams) {}, expected: false},
+		{name: "more memory", change: func(p *Argon2idParams) { p.Memory = 128 }, expected: true},
+		{name: "more iterations", change: func(p *Argon2idParams) { p.Iterations = 2 }, expected: true},
+		{name: "more parallelism", change: func(p *Argon2idParams) { p.Parallelism = 2 }, expected: true},
+		{name: "longer salt", change: func(p *Argon2idParams) { p.SaltLength = 32 }, expected: true},
+		{name: "longer key", change: func(p *Argon2idParams) { p.KeyLength = 64 }, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testArgon2idParams
+			tt.change(&params)
+			assert.Equal(t, tt.expected, NewArgon2idPasswordService(params).NeedsRehash(hashed))
+		})
+	}
+}
@@ -1,20 +1,20 @@
 package services
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
 
-type PasswordService interface {
-	CompareHashAndPassword(hashedPassword, password string) error
-	GenerateHashedPassword(unhashedPassword string) (string, error)
+type BcryptPasswordService struct {
+	Cost int
 }
 
-type BcryptPasswordService struct{}
-
-func NewBcryptPasswordService() *BcryptPasswordService {
-	return &BcryptPasswordService{}
+func NewBcryptPasswordService(cost int) *BcryptPasswordService {
+	return &BcryptPasswordService{Cost: cost}
 }
 
 func (s *BcryptPasswordService) GenerateHashedPassword(unhashedPassword string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(unhashedPassword), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(unhashedPassword), s.Cost)
 	if err != nil {
 		return "", err
 	}
@@ -28,3 +28,14 @@ func (s *BcryptPasswordService) CompareHashAndPassword(passwordHashed, password
 	}
 	return nil
 }
+
+// NeedsRehash reports hashes made with another cost than Cost.
+func (s *BcryptPasswordService) NeedsRehash(passwordHashed string) bool {
+	cost, err := bcrypt.Cost([]byte(passwordHashed))
+	return err != nil || cost != s.Cost
+}
+
+// Recognizes bcrypt hashes by their "$2a$", "$2b$" or "$2y$" prefix.
+func (s *BcryptPasswordService) Recognizes(passwordHashed string) bool {
+	return strings.HasPrefix(passwordHashed, "$2")
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strconv"
+)
+
+// ErrUnknownPasswordHash is returned when comparing against a hash that none of the configured algorithms produced.
+var ErrUnknownPasswordHash = errors.New("password hash is not in a known format")
+
+// ErrPasswordMismatch is returned when a password does not match its hash.
+var ErrPasswordMismatch = errors.New("password does not match hash")
+
+type PasswordService interface {
+	CompareHashAndPassword(hashedPassword, password string) error
+	GenerateHashedPassword(unhashedPassword string) (string, error)
+	// NeedsRehash reports whether hashedPassword was made with another algorithm or other parameters than new hashes.
+	NeedsRehash(hashedPassword string) bool
+}
+
+// PasswordAlgorithm is a PasswordService for a single hashing algorithm, which recognises the hashes it produced.
+type PasswordAlgorithm interface {
+	PasswordService
+	Recognizes(hashedPassword string) bool
+}
+
+/*
+MultiPasswordService hashes new passwords with its default algorithm, and verifies hashes made by any of its algorithms,
+so users whose password was hashed with an older one can still log in. NeedsRehash reports those hashes, so they can
+be replaced the next time the password is at hand.
+*/
+type MultiPasswordService struct {
+	Default   PasswordAlgorithm
+	Verifiers []PasswordAlgorithm
+}
+
+func NewMultiPasswordService(defaultAlgorithm PasswordAlgorithm, verifiers ...PasswordAlgorithm) *MultiPasswordService {
+	return &MultiPasswordService{
+		Default:   defaultAlgorithm,
+		Verifiers: verifiers,
+	}
+}
+
+/*
+NewPasswordServiceFromEnv returns the PasswordService used by the app: new passwords are hashed with argon2id, and
+bcrypt hashes from before are still verified. ARGON2_MEMORY (in KiB), ARGON2_ITERATIONS and ARGON2_PARALLELISM
+override DefaultArgon2idParams. Changing them makes existing hashes be rehashed on the user's next login.
+*/
+func NewPasswordServiceFromEnv() (*MultiPasswordService, error) {
+	params := DefaultArgon2idParams
+
+	if v := os.Getenv("ARGON2_MEMORY"); v != "" {
+		memory, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || memory < 8 {
+			return nil, fmt.Errorf("invalid ARGON2_MEMORY: %q", v)
+		}
+		params.Memory = uint32(memory)
+	}
+	if v := os.Getenv("ARGON2_ITERATIONS"); v != "" {
+		iterations, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || iterations < 1 {
+			return nil, fmt.Errorf("invalid ARGON2_ITERATIONS: %q", v)
+		}
+		params.Iterations = uint32(iterations)
+	}
+	if v := os.Getenv("ARGON2_PARALLELISM"); v != "" {
+		parallelism, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || parallelism < 1 {
+			return nil, fmt.Errorf("invalid ARGON2_PARALLELISM: %q", v)
+		}
+		params.Parallelism = uint8(parallelism)
+	}
+
+	return NewMultiPasswordService(NewArgon2idPasswordService(params), NewBcryptPasswordService(bcrypt.DefaultCost)), nil
+}
+
+func (s *MultiPasswordService) GenerateHashedPassword(unhashedPassword string) (string, error) {
+	return s.Default.GenerateHashedPassword(unhashedPassword)
+}
+
+func (s *MultiPasswordService) CompareHashAndPassword(hashedPassword, password string) error {
+	if s.Default.Recognizes(hashedPassword) {
+		return s.Default.CompareHashAndPassword(hashedPassword, password)
+	}
+	for _, algorithm := range s.Verifiers {
+		if algorithm.Recognizes(hashedPassword) {
+			return algorithm.CompareHashAndPassword(hashedPassword, password)
+		}
+	}
+	return ErrUnknownPasswordHash
+}
+
+func (s *MultiPasswordService) NeedsRehash(hashedPassword string) bool {
+	return !s.Default.Recognizes(hashedPassword) || s.Default.NeedsRehash(hashedPassword)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestBcryptNeedsRehash(t *testing.T) {
+	hashed, err := NewBcryptPasswordService(bcrypt.MinCost).GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+
+	assert.False(t, NewBcryptPasswordService(bcrypt.MinCost).NeedsRehash(hashed))
+	assert.True(t, NewBcryptPasswordService(bcrypt.MinCost+1).NeedsRehash(hashed))
+	assert.True(t, NewBcryptPasswordService(bcrypt.MinCost).NeedsRehash("not a bcrypt hash"))
+}
+
+func TestMultiPasswordService(t *testing.T) {
+	argon := NewArgon2idPasswordService(testArgon2idParams)
+	bcryptService := NewBcryptPasswordService(bcrypt.MinCost)
+	s := NewMultiPasswordService(argon, bcryptService)
+
+	argonHash, err := argon.GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+	bcryptHash, err := bcryptService.GenerateHashedPassword("correcthorse")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		hashed       string
+		password     string
+		expectedErr  error
+		expectMatch  bool
+		expectRehash bool
+	}{
+		{name: "argon2id hash", hashed: argonHash, password: "correcthorse", expectMatch: true},
+		{name: "argon2id hash with wrong password", hashed: argonHash, password: "wronghorse", expectedErr: ErrPasswordMismatch},
+		{name: "bcrypt hash is verified and rehashed", hashed: bcryptHash, password: "correcthorse", expectMatch: true, expectRehash: true},
+		{name: "bcrypt hash with wrong password", hashed: bcryptHash, password: "wronghorse", expectedErr: bcrypt.ErrMismatchedHashAndPassword, expectRehash: true},
+		{name: "unknown hash", hashed: "$1$plainmd5", password: "correcthorse", expectedErr: ErrUnknownPasswordHash, expectRehash: true},
+		{name: "empty hash", hashed: "", password: "correcthorse", expectedErr: ErrUnknownPasswordHash, expectRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.CompareHashAndPassword(tt.hashed, tt.password)
+			if tt.expectMatch {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+			assert.Equal(t, tt.expectRehash, s.NeedsRehash(tt.hashed))
+		})
+	}
+
+	t.Run("new hashes use the default algorithm", func(t *testing.T) {
+		hashed, err := s.GenerateHashedPassword("correcthorse")
+		require.NoError(t, err)
+		assert.True(t, argon.Recognizes(hashed))
+		assert.False(t, s.NeedsRehash(hashed))
+	})
+
+	t.Run("hashes of other default params are rehashed", func(t *testing.T) {
+		params := testArgon2idParams
+		params.Memory = 128
+		stronger := NewMultiPasswordService(NewArgon2idPasswordService(params), bcryptService)
+
+		assert.NoError(t, stronger.CompareHashAndPassword(argonHash, "correcthorse"))
+		assert.True(t, stronger.NeedsRehash(argonHash))
+	})
+}
+
+func TestNewPasswordServiceFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expected    Argon2idParams
+		expectedErr bool
+	}{
+		{name: "defaults", expected: DefaultArgon2idParams},
+		{
+			name:     "overridden",
+			env:      map[string]string{"ARGON2_MEMORY": "19456", "ARGON2_ITERATIONS": "2", "ARGON2_PARALLELISM": "1"},
+			expected: Argon2idParams{Memory: 19456, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+		{name: "memory too low", env: map[string]string{"ARGON2_MEMORY": "4"}, expectedErr: true},
+		{name: "zero iterations", env: map[string]string{"ARGON2_ITERATIONS": "0"}, expectedErr: true},
+		{name: "parallelism out of range", env: map[string]string{"ARGON2_PARALLELISM": "256"}, expectedErr: true},
+		{name: "not a number", env: map[string]string{"ARGON2_MEMORY": "lots"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"ARGON2_MEMORY", "ARGON2_ITERATIONS", "ARGON2_PARALLELISM"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			s, err := NewPasswordServiceFromEnv()
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, s.Default.(*Argon2idPasswordService).Params)
+			require.Len(t, s.Verifiers, 1)
+			assert.Equal(t, bcrypt.DefaultCost, s.Verifiers[0].(*BcryptPasswordService).Cost)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// maxPhotoPixels caps the decoded size of an upload, so a small file cannot expand into a huge image in memory.
+	maxPhotoPixels = 40_000_000
+	thumbnailSize  = 320
+	jpegQuality    = 90
+)
+
+// ErrUnsupportedPhoto is returned for uploads that are not a JPEG or PNG image.
+var ErrUnsupportedPhoto = errors.New("photo is not a JPEG or PNG image")
+
+// ErrInvalidPhoto is returned for uploads that cannot be decoded, or whose dimensions are out of bounds.
+var ErrInvalidPhoto = errors.New("photo cannot be decoded or is too large")
+
+// ProcessedPhoto is an uploaded photo ready to be stored. Thumbnail is always a JPEG.
+type ProcessedPhoto struct {
+	Image       []byte
+	ContentType string
+	Extension   string
+	Thumbnail   []byte
+}
+
+type PhotoService interface {
+	Process(data []byte) (*ProcessedPhoto, error)
+}
+
+type ImagePhotoService struct{}
+
+func NewImagePhotoService() *ImagePhotoService {
+	return &ImagePhotoService{}
+}
+
+/*
+Process checks an uploaded photo and prepares it for storage.
+The type is sniffed from the content, whatever the client claims it is. Only JPEG and PNG are accepted.
+The image is decoded and encoded again in its own format, which drops any metadata such as EXIF GPS coordinates.
+A thumbnail is generated that fits into thumbnailSize pixels on its longest side.
+*/
+func (s *ImagePhotoService) Process(data []byte) (*ProcessedPhoto, error) {
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return nil, ErrUnsupportedPhoto
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPhotoPixels {
+		return nil, ErrInvalidPhoto
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidPhoto
+	}
+
+	processed := &ProcessedPhoto{ContentType: contentType}
+	var buf bytes.Buffer
+	if contentType == "image/png" {
+		processed.Extension = "png"
+		err = png.Encode(&buf, img)
+	} else {
+		processed.Extension = "jpg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	processed.Image = buf.Bytes()
+
+	var thumb bytes.Buffer
+	if err = jpeg.Encode(&thumb, thumbnail(img, thumbnailSize), &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	processed.Thumbnail = thumb.Bytes()
+
+	return processed, nil
+}
+
+/*
+thumbnail scales img down to fit into size pixels on its longest side, averaging the source pixels that make up each
+thumbnail pixel. Transparent areas are flattened onto white, since JPEG has no alpha channel. Images that already fit
+are only flattened.
+*/
+func thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(1, srcH*size/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*size/srcH), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			// Colors are alpha-premultiplied, so adding the missing coverage as white flattens the pixel
+			white := 0xffff*n - a
+			dst.Set(x, y, color.RGBA64{
+				R: uint16((r + white) / n),
+				G: uint16((g + white) / n),
+				B: uint16((b + white) / n),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
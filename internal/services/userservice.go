@@ -3,14 +3,24 @@ package services
 import (
 	"github.com/brianvoe/gofakeit/v6"
 	"quick-match/internal/models"
+	"time"
 )
 
-func GenerateNewUser() models.UserDetails {
+/*
+GenerateNewUser builds a fake user for seeding development environments. It is not used by the signup flow. The
+password is hashed with the given PasswordService, so generated users log in like real ones.
+*/
+func GenerateNewUser(passwordService PasswordService) (models.UserDetails, error) {
 	gofakeit.Seed(0)
 
+	now := time.Now()
+	birthdate := gofakeit.DateRange(now.AddDate(-50, 0, 0), now.AddDate(-18, 0, 0))
+
 	unhashedPassword := generatePassword()
-	ghp := BcryptPasswordService{}
-	hashedPassword, _ := ghp.GenerateHashedPassword(unhashedPassword)
+	hashedPassword, err := passwordService.GenerateHashedPassword(unhashedPassword)
+	if err != nil {
+		return models.UserDetails{}, err
+	}
 
 	return models.UserDetails{
 		UserID:         gofakeit.UUID(),
@@ -19,12 +29,17 @@ func GenerateNewUser() models.UserDetails {
 		PasswordHashed: hashedPassword,
 		Name:           gofakeit.Name(),
 		Gender:         gofakeit.Gender(),
-		Age:            gofakeit.Number(18, 50),
+		Birthdate:      birthdate.Format(models.BirthdateLayout),
 		Userlocation: models.Userlocation{
 			Latitude:  gofakeit.Latitude(),
 			Longitude: gofakeit.Longitude(),
 		},
-	}
+		Preferences: models.Preferences{
+			InterestedIn:    []string{gofakeit.Gender()},
+			PreferredMinAge: 18,
+			PreferredMaxAge: gofakeit.Number(25, 60),
+		},
+	}, nil
 }
 
 func generatePassword() string {
@@ -5,9 +5,11 @@ provider "aws" {
   skip_credentials_validation = true
   skip_metadata_api_check     = true
   skip_requesting_account_id  = true
+  s3_use_path_style           = true
   endpoints {
     dynamodb = "http://localhost:4566"
     es = "http://localhost:4566"
+    s3 = "http://localhost:4566"
   }
 }
 
@@ -22,11 +24,141 @@ resource "aws_dynamodb_table" "user_table" {
     type = "S"
   }
 
+  attribute {
+    name = "email"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "EmailIndex"
+    hash_key        = "email"
+    projection_type = "ALL"
+    read_capacity   = 1
+    write_capacity  = 1
+  }
+
   tags = {
     Name = "QuickMatchUsers"
   }
 }
 
+# One item per registered email, so a conditional put can keep emails unique
+resource "aws_dynamodb_table" "emails_table" {
+  name         = "quickmatch_emails"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "email"
+
+  attribute {
+    name = "email"
+    type = "S"
+  }
+
+  tags = {
+    Name = "QuickMatchEmails"
+  }
+}
+
+resource "aws_dynamodb_table" "outbox_table" {
+  name         = "quickmatch_outbox"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UserID"
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  tags = {
+    Name = "QuickMatchOutbox"
+  }
+}
+
+# Tombstones of deleted accounts. Only erasures still in progress are in the sparse PendingIndex.
+resource "aws_dynamodb_table" "tombstones_table" {
+  name         = "quickmatch_tombstones"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UserID"
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  attribute {
+    name = "pendingSince"
+    type = "N"
+  }
+
+  global_secondary_index {
+    name            = "PendingIndex"
+    hash_key        = "pendingSince"
+    projection_type = "ALL"
+  }
+
+  tags = {
+    Name = "QuickMatchTombstones"
+  }
+}
+
+resource "aws_dynamodb_table" "audit_table" {
+  name         = "quickmatch_audit"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "AuditID"
+
+  attribute {
+    name = "AuditID"
+    type = "S"
+  }
+
+  tags = {
+    Name = "QuickMatchAudit"
+  }
+}
+
+# Data export jobs. Jobs still to be built are in the sparse PendingIndex, stored archives in ArchiveExpiryIndex.
+resource "aws_dynamodb_table" "exports_table" {
+  name         = "quickmatch_exports"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UserID"
+  range_key    = "ExportID"
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  attribute {
+    name = "ExportID"
+    type = "S"
+  }
+
+  attribute {
+    name = "nextAttemptAt"
+    type = "N"
+  }
+
+  attribute {
+    name = "archiveExpiresAt"
+    type = "N"
+  }
+
+  global_secondary_index {
+    name            = "PendingIndex"
+    hash_key        = "nextAttemptAt"
+    projection_type = "ALL"
+  }
+
+  global_secondary_index {
+    name            = "ArchiveExpiryIndex"
+    hash_key        = "archiveExpiresAt"
+    projection_type = "ALL"
+  }
+
+  tags = {
+    Name = "QuickMatchExports"
+  }
+}
+
 resource "aws_dynamodb_table" "swipes_table" {
   name             = "quickmatch_swipes"
   billing_mode = "PAY_PER_REQUEST"
@@ -55,6 +187,171 @@ resource "aws_dynamodb_table" "swipes_table" {
   }
 }
 
+resource "aws_dynamodb_table" "sessions_table" {
+  name         = "quickmatch_sessions"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "SessionID"
+
+  attribute {
+    name = "SessionID"
+    type = "S"
+  }
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "UserIndex"
+    hash_key        = "UserID"
+    projection_type = "KEYS_ONLY"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  tags = {
+    Name = "QuickMatchSessions"
+  }
+}
+
+# Failed logins per email and per client IP, for the lockout on /login
+resource "aws_dynamodb_table" "login_throttle_table" {
+  name         = "quickmatch_login_throttle"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "ThrottleKey"
+
+  attribute {
+    name = "ThrottleKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  tags = {
+    Name = "QuickMatchLoginThrottle"
+  }
+}
+
+resource "aws_dynamodb_table" "matches_table" {
+  name         = "quickmatch_matches"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UserID"
+  range_key    = "MatchID"
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  attribute {
+    name = "MatchID"
+    type = "S"
+  }
+
+  attribute {
+    name = "matchedAt"
+    type = "N"
+  }
+
+  local_secondary_index {
+    name            = "MatchedAtIndex"
+    range_key       = "matchedAt"
+    projection_type = "ALL"
+  }
+
+  tags = {
+    Name = "QuickMatchMatches"
+  }
+}
+
+resource "aws_dynamodb_table" "messages_table" {
+  name         = "quickmatch_messages"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "MatchID"
+  range_key    = "MessageID"
+
+  attribute {
+    name = "MatchID"
+    type = "S"
+  }
+
+  attribute {
+    name = "MessageID"
+    type = "S"
+  }
+
+  tags = {
+    Name = "QuickMatchMessages"
+  }
+}
+
+resource "aws_dynamodb_table" "blocks_table" {
+  name         = "quickmatch_blocks"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UserID"
+  range_key    = "BlockedUserID"
+
+  attribute {
+    name = "UserID"
+    type = "S"
+  }
+
+  attribute {
+    name = "BlockedUserID"
+    type = "S"
+  }
+
+  global_secondary_index {
+    name            = "BlockedUserIndex"
+    hash_key        = "BlockedUserID"
+    range_key       = "UserID"
+    projection_type = "KEYS_ONLY"
+  }
+
+  tags = {
+    Name = "QuickMatchBlocks"
+  }
+}
+
+resource "aws_dynamodb_table" "reports_table" {
+  name         = "quickmatch_reports"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "ReportID"
+
+  attribute {
+    name = "ReportID"
+    type = "S"
+  }
+
+  attribute {
+    name = "status"
+    type = "S"
+  }
+
+  attribute {
+    name = "createdAt"
+    type = "N"
+  }
+
+  global_secondary_index {
+    name            = "StatusIndex"
+    hash_key        = "status"
+    range_key       = "createdAt"
+    projection_type = "ALL"
+  }
+
+  tags = {
+    Name = "QuickMatchReports"
+  }
+}
+
 resource "aws_elasticsearch_domain" "discover_domain" {
   domain_name           = "quickmatch-discover"
   elasticsearch_version = "7.9"
@@ -73,6 +370,28 @@ resource "aws_elasticsearch_domain" "discover_domain" {
   }
 }
 
+resource "aws_s3_bucket" "photos_bucket" {
+  bucket = "quickmatch-photos"
+
+  tags = {
+    Name = "QuickMatchPhotos"
+  }
+}
+
+# Data exports are only downloaded through the API, so unlike the photos bucket this one is never public
+resource "aws_s3_bucket" "exports_bucket" {
+  bucket = "quickmatch-exports"
 
+  tags = {
+    Name = "QuickMatchExports"
+  }
+}
 
+resource "aws_s3_bucket_public_access_block" "exports_bucket" {
+  bucket = aws_s3_bucket.exports_bucket.id
 
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}